@@ -0,0 +1,168 @@
+package deferred
+
+import (
+	"github.com/QuangTung97/memproxy"
+)
+
+// Result is the value and error of a deferred function
+type Result[T any] struct {
+	Value T
+	Err   error
+}
+
+// once wraps a deferred function to allow calling multiple times,
+// because many of them, e.g. the one returned from item.Item.Get, MUST only be called once
+func once[T any](fn func() (T, error)) func() (T, error) {
+	called := false
+	var result T
+	var err error
+
+	return func() (T, error) {
+		if !called {
+			called = true
+			result, err = fn()
+			fn = nil
+		}
+		return result, err
+	}
+}
+
+// Value creates a deferred function returning the value
+func Value[T any](v T) func() (T, error) {
+	return func() (T, error) {
+		return v, nil
+	}
+}
+
+// Error creates a deferred function returning the error
+func Error[T any](err error) func() (T, error) {
+	return func() (T, error) {
+		var empty T
+		return empty, err
+	}
+}
+
+// Map transforms the value of fn using the function f, error is passed through
+func Map[A any, B any](fn func() (A, error), f func(a A) B) func() (B, error) {
+	return once(func() (B, error) {
+		a, err := fn()
+		if err != nil {
+			var empty B
+			return empty, err
+		}
+		return f(a), nil
+	})
+}
+
+// FlatMap calls f with the value of fn to do the next lookup, after fn is resolved in the session.
+//
+// The session MUST have lower priority than the sessions of the lookups (e.g. from item.Item.LowerSession()).
+// The values of all FlatMap calls in the same round are resolved before calling any f,
+// then all the next lookups are resolved together, hence the lookups are still batched.
+func FlatMap[A any, B any](
+	sess memproxy.Session,
+	fn func() (A, error),
+	f func(a A) func() (B, error),
+) func() (B, error) {
+	var a A
+	var err error
+	var next func() (B, error)
+
+	sess.AddNextCall(memproxy.NewEmptyCallback(func() {
+		a, err = fn()
+		if err != nil {
+			return
+		}
+
+		// calling f in the next round, because resolving fn of other FlatMap calls in this round
+		// would execute the lookups created by f one by one
+		sess.AddNextCall(memproxy.NewEmptyCallback(func() {
+			next = f(a)
+		}))
+	}))
+
+	return once(func() (B, error) {
+		sess.Execute()
+		if err != nil {
+			var empty B
+			return empty, err
+		}
+		return next()
+	})
+}
+
+// Zip combines the results of 2 deferred functions, returns the first error if any
+func Zip[A any, B any](fnA func() (A, error), fnB func() (B, error)) func() (A, B, error) {
+	called := false
+	var a A
+	var b B
+	var err error
+
+	return func() (A, B, error) {
+		if !called {
+			called = true
+			a, b, err = doZip(fnA, fnB)
+		}
+		return a, b, err
+	}
+}
+
+func doZip[A any, B any](fnA func() (A, error), fnB func() (B, error)) (A, B, error) {
+	a, errA := fnA()
+	b, errB := fnB()
+
+	if errA != nil {
+		var emptyB B
+		return a, emptyB, errA
+	}
+	if errB != nil {
+		var emptyA A
+		return emptyA, b, errB
+	}
+	return a, b, nil
+}
+
+// All combines the results of the list of deferred functions, returns the first error if any.
+// All functions are called even when an error occurred, because they MUST be called to release resources
+func All[T any](fnList ...func() (T, error)) func() ([]T, error) {
+	return once(func() ([]T, error) {
+		result := make([]T, 0, len(fnList))
+		var firstErr error
+
+		for _, fn := range fnList {
+			v, err := fn()
+			if err != nil && firstErr == nil {
+				firstErr = err
+			}
+			result = append(result, v)
+		}
+
+		if firstErr != nil {
+			return nil, firstErr
+		}
+		return result, nil
+	})
+}
+
+// Collect combines the results of the list of deferred functions, each with its own error
+func Collect[T any](fnList ...func() (T, error)) func() []Result[T] {
+	called := false
+	var result []Result[T]
+
+	return func() []Result[T] {
+		if called {
+			return result
+		}
+		called = true
+
+		result = make([]Result[T], 0, len(fnList))
+		for _, fn := range fnList {
+			v, err := fn()
+			result = append(result, Result[T]{
+				Value: v,
+				Err:   err,
+			})
+		}
+		return result
+	}
+}
@@ -0,0 +1,198 @@
+package deferred
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/QuangTung97/memproxy/fake"
+	"github.com/QuangTung97/memproxy/item"
+)
+
+type userValue struct {
+	ID        int64  `json:"id"`
+	Name      string `json:"name"`
+	ManagerID int64  `json:"manager_id"`
+}
+
+func (u userValue) Marshal() ([]byte, error) {
+	return json.Marshal(u)
+}
+
+func unmarshalUser(data []byte) (userValue, error) {
+	var u userValue
+	err := json.Unmarshal(data, &u)
+	return u, err
+}
+
+type userKey struct {
+	ID int64
+}
+
+func (k userKey) String() string {
+	return fmt.Sprintf("users:%d", k.ID)
+}
+
+type deferredTest struct {
+	users     map[int64]userValue
+	fillCalls [][]userKey
+
+	item *item.Item[userValue, userKey]
+}
+
+func newDeferredTest() *deferredTest {
+	h := &deferredTest{
+		users: map[int64]userValue{
+			1: {ID: 1, Name: "user01", ManagerID: 3},
+			2: {ID: 2, Name: "user02", ManagerID: 4},
+			3: {ID: 3, Name: "user03"},
+			4: {ID: 4, Name: "user04"},
+		},
+	}
+
+	pipe := fake.New().Pipeline(context.Background())
+
+	h.item = item.New[userValue, userKey](
+		pipe, unmarshalUser,
+		item.NewMultiGetFiller[userValue, userKey](
+			func(ctx context.Context, keys []userKey) ([]userValue, error) {
+				h.fillCalls = append(h.fillCalls, keys)
+				var result []userValue
+				for _, k := range keys {
+					result = append(result, h.users[k.ID])
+				}
+				return result, nil
+			},
+			func(v userValue) userKey {
+				return userKey{ID: v.ID}
+			},
+		),
+	)
+	return h
+}
+
+func (h *deferredTest) getUser(id int64) func() (userValue, error) {
+	return h.item.Get(context.Background(), userKey{ID: id})
+}
+
+func (h *deferredTest) getManager(id int64) func() (userValue, error) {
+	return FlatMap(h.item.LowerSession(), h.getUser(id), func(u userValue) func() (userValue, error) {
+		return h.getUser(u.ManagerID)
+	})
+}
+
+func TestFlatMap(t *testing.T) {
+	t.Run("batching", func(t *testing.T) {
+		h := newDeferredTest()
+
+		fn1 := h.getManager(1)
+		fn2 := h.getManager(2)
+
+		m1, err := fn1()
+		assert.Equal(t, nil, err)
+		assert.Equal(t, h.users[3], m1)
+
+		m2, err := fn2()
+		assert.Equal(t, nil, err)
+		assert.Equal(t, h.users[4], m2)
+
+		assert.Equal(t, [][]userKey{
+			{{ID: 1}, {ID: 2}},
+			{{ID: 3}, {ID: 4}},
+		}, h.fillCalls)
+
+		// call again
+		m1, err = fn1()
+		assert.Equal(t, nil, err)
+		assert.Equal(t, h.users[3], m1)
+	})
+
+	t.Run("error", func(t *testing.T) {
+		h := newDeferredTest()
+
+		fCalls := 0
+		fn := FlatMap(h.item.LowerSession(), Error[userValue](errors.New("get error")),
+			func(u userValue) func() (userValue, error) {
+				fCalls++
+				return Value(u)
+			},
+		)
+
+		v, err := fn()
+		assert.Equal(t, errors.New("get error"), err)
+		assert.Equal(t, userValue{}, v)
+		assert.Equal(t, 0, fCalls)
+	})
+}
+
+func TestMap(t *testing.T) {
+	h := newDeferredTest()
+
+	fn := Map(h.getUser(1), func(u userValue) string {
+		return u.Name
+	})
+
+	name, err := fn()
+	assert.Equal(t, nil, err)
+	assert.Equal(t, "user01", name)
+
+	fn = Map(Error[userValue](errors.New("get error")), func(u userValue) string {
+		return u.Name
+	})
+	name, err = fn()
+	assert.Equal(t, errors.New("get error"), err)
+	assert.Equal(t, "", name)
+}
+
+func TestZip(t *testing.T) {
+	h := newDeferredTest()
+
+	fn := Zip(h.getUser(1), h.getManager(2))
+
+	u, m, err := fn()
+	assert.Equal(t, nil, err)
+	assert.Equal(t, h.users[1], u)
+	assert.Equal(t, h.users[4], m)
+
+	assert.Equal(t, [][]userKey{
+		{{ID: 1}, {ID: 2}},
+		{{ID: 4}},
+	}, h.fillCalls)
+
+	_, _, err = Zip(Value(1), Error[string](errors.New("zip error")))()
+	assert.Equal(t, errors.New("zip error"), err)
+}
+
+func TestAll_And_Collect(t *testing.T) {
+	t.Run("all", func(t *testing.T) {
+		h := newDeferredTest()
+
+		values, err := All(h.getUser(1), h.getManager(1), h.getManager(2))()
+		assert.Equal(t, nil, err)
+		assert.Equal(t, []userValue{h.users[1], h.users[3], h.users[4]}, values)
+
+		assert.Equal(t, [][]userKey{
+			{{ID: 1}, {ID: 2}},
+			{{ID: 3}, {ID: 4}},
+		}, h.fillCalls)
+	})
+
+	t.Run("all-with-error", func(t *testing.T) {
+		values, err := All(Value(1), Error[int](errors.New("error 1")), Error[int](errors.New("error 2")))()
+		assert.Equal(t, errors.New("error 1"), err)
+		assert.Equal(t, []int(nil), values)
+	})
+
+	t.Run("collect", func(t *testing.T) {
+		results := Collect(Value(1), Error[int](errors.New("error 1")), Value(3))()
+		assert.Equal(t, []Result[int]{
+			{Value: 1},
+			{Err: errors.New("error 1")},
+			{Value: 3},
+		}, results)
+	})
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"github.com/QuangTung97/memproxy"
 	mcitem "github.com/QuangTung97/memproxy/item"
+	"github.com/QuangTung97/memproxy/keys"
 	"github.com/QuangTung97/memproxy/proxy"
 	"time"
 )
@@ -27,15 +28,7 @@ func unmarshalUser(data []byte) (User, error) {
 	return u, err
 }
 
-// UserKey ...
-type UserKey struct {
-	ID int64
-}
-
-// String ...
-func (u UserKey) String() string {
-	return fmt.Sprintf("users:%d", u.ID)
-}
+var userKeySchema = keys.NewSchema("users")
 
 func main() {
 	servers := []proxy.SimpleServerConfig{
@@ -85,9 +78,9 @@ func doGetFromCache(
 	*userSeq++
 	id := *userSeq % 11
 
-	userItem := mcitem.New[User, UserKey](
+	userItem := mcitem.New[User, keys.IntKey](
 		pipe, unmarshalUser,
-		func(ctx context.Context, key UserKey) func() (User, error) {
+		func(ctx context.Context, key keys.IntKey) func() (User, error) {
 			fmt.Println("DO Fill with Key:", key)
 			return func() (User, error) {
 				return User{
@@ -98,9 +91,7 @@ func doGetFromCache(
 		},
 	)
 
-	fn := userItem.Get(context.Background(), UserKey{
-		ID: int64(id),
-	})
+	fn := userItem.Get(context.Background(), keys.NewIntKey(userKeySchema, int64(id)))
 	user, err := fn()
 	fmt.Println(user, err)
 }
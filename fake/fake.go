@@ -64,6 +64,13 @@ func (m *Memcache) Pipeline(_ context.Context, _ ...memproxy.PipelineOption) mem
 
 			entry, ok := m.entries[key]
 
+			if options.NoLease && !entry.Valid {
+				resp = memproxy.LeaseGetResponse{
+					Status: memproxy.LeaseGetStatusNotFound,
+				}
+				return
+			}
+
 			if !ok {
 				cas := m.nextCAS()
 				m.entries[key] = Entry{
@@ -54,6 +54,41 @@ func TestPipeline(t *testing.T) {
 		}, resp3)
 	})
 
+	t.Run("lease-get-no-lease", func(t *testing.T) {
+		pipe := newPipelineTest()
+		defer pipe.Finish()
+
+		resp, err := pipe.LeaseGet("KEY01", memproxy.LeaseGetOptions{NoLease: true}).Result()
+		assert.Equal(t, nil, err)
+		assert.Equal(t, memproxy.LeaseGetResponse{
+			Status: memproxy.LeaseGetStatusNotFound,
+		}, resp)
+
+		resp, err = pipe.LeaseGet("KEY01", memproxy.LeaseGetOptions{}).Result()
+		assert.Equal(t, nil, err)
+		assert.Equal(t, memproxy.LeaseGetResponse{
+			Status: memproxy.LeaseGetStatusLeaseGranted,
+			CAS:    1,
+		}, resp)
+
+		resp, err = pipe.LeaseGet("KEY01", memproxy.LeaseGetOptions{NoLease: true}).Result()
+		assert.Equal(t, nil, err)
+		assert.Equal(t, memproxy.LeaseGetResponse{
+			Status: memproxy.LeaseGetStatusNotFound,
+		}, resp)
+
+		_, err = pipe.LeaseSet("KEY01", []byte("data 01"), 1, memproxy.LeaseSetOptions{})()
+		assert.Equal(t, nil, err)
+
+		resp, err = pipe.LeaseGet("KEY01", memproxy.LeaseGetOptions{NoLease: true}).Result()
+		assert.Equal(t, nil, err)
+		assert.Equal(t, memproxy.LeaseGetResponse{
+			Status: memproxy.LeaseGetStatusFound,
+			CAS:    1,
+			Data:   []byte("data 01"),
+		}, resp)
+	})
+
 	t.Run("lease-get-2-different-keys", func(t *testing.T) {
 		pipe := newPipelineTest()
 		defer pipe.Finish()
@@ -0,0 +1,144 @@
+package item
+
+import (
+	"math/rand"
+	"time"
+)
+
+// BackoffPolicy computes the sleep duration before retrying lease get after receiving the Lease Rejected status.
+// Param: retryCount starts from 0 for the first retry.
+// Param: elapsed is the total sleep duration of the previous retries of the same key.
+// Returns ok = false when no more retry should be done.
+type BackoffPolicy func(retryCount int, elapsed time.Duration) (d time.Duration, ok bool)
+
+// NewFixedBackoff returns a BackoffPolicy using the fixed list of durations,
+// the number of retries is the length of that list. The durations must not be negative
+func NewFixedBackoff(durations ...time.Duration) BackoffPolicy {
+	for _, d := range durations {
+		if d < 0 {
+			panic("item: backoff duration must not be negative")
+		}
+	}
+
+	return func(retryCount int, _ time.Duration) (time.Duration, bool) {
+		if retryCount >= len(durations) {
+			return 0, false
+		}
+		return durations[retryCount], true
+	}
+}
+
+type exponentialBackoffConfig struct {
+	baseDuration  time.Duration
+	maxDuration   time.Duration
+	maxRetries    int
+	totalDuration time.Duration
+
+	// random from 0 => n - 1
+	randFunc func(n int64) int64
+}
+
+// ExponentialBackoffOption ...
+type ExponentialBackoffOption func(conf *exponentialBackoffConfig)
+
+// WithBackoffBaseDuration configures the upper bound of the sleep duration of the first retry, default is 2ms
+func WithBackoffBaseDuration(d time.Duration) ExponentialBackoffOption {
+	return func(conf *exponentialBackoffConfig) {
+		conf.baseDuration = d
+	}
+}
+
+// WithBackoffMaxDuration configures the upper bound of the sleep duration of a single retry, default is 40ms
+func WithBackoffMaxDuration(d time.Duration) ExponentialBackoffOption {
+	return func(conf *exponentialBackoffConfig) {
+		conf.maxDuration = d
+	}
+}
+
+// WithBackoffMaxRetries configures the maximum number of retries, default is 6
+func WithBackoffMaxRetries(n int) ExponentialBackoffOption {
+	return func(conf *exponentialBackoffConfig) {
+		conf.maxRetries = n
+	}
+}
+
+// WithBackoffTotalDuration configures the total wait budget of all retries of a key.
+// The last sleep duration is truncated to fit into this budget.
+// default is zero, meaning no limit on the total duration
+func WithBackoffTotalDuration(d time.Duration) ExponentialBackoffOption {
+	return func(conf *exponentialBackoffConfig) {
+		conf.totalDuration = d
+	}
+}
+
+// WithBackoffRandFunc configures the random function used for jitter, for testing purpose
+func WithBackoffRandFunc(randFunc func(n int64) int64) ExponentialBackoffOption {
+	return func(conf *exponentialBackoffConfig) {
+		conf.randFunc = randFunc
+	}
+}
+
+// NewExponentialBackoff returns a BackoffPolicy using exponential backoff with full jitter.
+// The sleep duration of the n-th retry is a random value in [0, min(maxDuration, baseDuration * 2^n)].
+// The randomness prevents many clients from retrying in lockstep after a key is invalidated.
+// The base duration must be positive, the max duration must not be less than the base duration,
+// and the max retries and the total duration must not be negative
+func NewExponentialBackoff(options ...ExponentialBackoffOption) BackoffPolicy {
+	conf := &exponentialBackoffConfig{
+		baseDuration:  2 * time.Millisecond,
+		maxDuration:   40 * time.Millisecond,
+		maxRetries:    6,
+		totalDuration: 0,
+		randFunc:      rand.Int63n,
+	}
+	for _, fn := range options {
+		fn(conf)
+	}
+
+	if conf.baseDuration <= 0 {
+		panic("item: backoff base duration must be positive")
+	}
+	if conf.maxDuration < conf.baseDuration {
+		panic("item: backoff max duration must not be less than base duration")
+	}
+	if conf.maxRetries < 0 {
+		panic("item: backoff max retries must not be negative")
+	}
+	if conf.totalDuration < 0 {
+		panic("item: backoff total duration must not be negative")
+	}
+
+	return func(retryCount int, elapsed time.Duration) (time.Duration, bool) {
+		if retryCount >= conf.maxRetries {
+			return 0, false
+		}
+
+		remaining := conf.totalDuration - elapsed
+		if conf.totalDuration > 0 && remaining <= 0 {
+			return 0, false
+		}
+
+		upper := computeBackoffUpperBound(conf.baseDuration, conf.maxDuration, retryCount)
+		d := time.Duration(conf.randFunc(int64(upper) + 1))
+
+		if conf.totalDuration > 0 && d > remaining {
+			d = remaining
+		}
+		return d, true
+	}
+}
+
+func computeBackoffUpperBound(base time.Duration, maxDuration time.Duration, retryCount int) time.Duration {
+	upper := base
+	for i := 0; i < retryCount; i++ {
+		if upper >= maxDuration {
+			break
+		}
+		upper *= 2
+	}
+
+	if upper > maxDuration {
+		return maxDuration
+	}
+	return upper
+}
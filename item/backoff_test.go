@@ -0,0 +1,135 @@
+package item
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFixedBackoff(t *testing.T) {
+	b := NewFixedBackoff(3*time.Millisecond, 7*time.Millisecond)
+
+	d, ok := b(0, 0)
+	assert.Equal(t, true, ok)
+	assert.Equal(t, 3*time.Millisecond, d)
+
+	d, ok = b(1, 3*time.Millisecond)
+	assert.Equal(t, true, ok)
+	assert.Equal(t, 7*time.Millisecond, d)
+
+	d, ok = b(2, 10*time.Millisecond)
+	assert.Equal(t, false, ok)
+	assert.Equal(t, time.Duration(0), d)
+
+	assert.PanicsWithValue(t, "item: backoff duration must not be negative", func() {
+		NewFixedBackoff(3*time.Millisecond, -time.Millisecond)
+	})
+}
+
+func TestExponentialBackoff(t *testing.T) {
+	t.Run("full-jitter-with-max-value", func(t *testing.T) {
+		var randArgs []int64
+		b := NewExponentialBackoff(
+			WithBackoffBaseDuration(2*time.Millisecond),
+			WithBackoffMaxDuration(10*time.Millisecond),
+			WithBackoffMaxRetries(5),
+			WithBackoffRandFunc(func(n int64) int64 {
+				randArgs = append(randArgs, n)
+				return n - 1
+			}),
+		)
+
+		var durations []time.Duration
+		for i := 0; ; i++ {
+			d, ok := b(i, 0)
+			if !ok {
+				break
+			}
+			durations = append(durations, d)
+		}
+
+		assert.Equal(t, []time.Duration{
+			2 * time.Millisecond,
+			4 * time.Millisecond,
+			8 * time.Millisecond,
+			10 * time.Millisecond,
+			10 * time.Millisecond,
+		}, durations)
+
+		assert.Equal(t, []int64{
+			int64(2*time.Millisecond) + 1,
+			int64(4*time.Millisecond) + 1,
+			int64(8*time.Millisecond) + 1,
+			int64(10*time.Millisecond) + 1,
+			int64(10*time.Millisecond) + 1,
+		}, randArgs)
+	})
+
+	t.Run("with-random-values", func(t *testing.T) {
+		b := NewExponentialBackoff(
+			WithBackoffRandFunc(func(n int64) int64 {
+				return n / 4
+			}),
+		)
+
+		d, ok := b(2, 0)
+		assert.Equal(t, true, ok)
+		assert.Equal(t, time.Duration(int64(8*time.Millisecond+1)/4), d)
+	})
+
+	t.Run("with-total-duration", func(t *testing.T) {
+		b := NewExponentialBackoff(
+			WithBackoffBaseDuration(4*time.Millisecond),
+			WithBackoffTotalDuration(10*time.Millisecond),
+			WithBackoffRandFunc(func(n int64) int64 {
+				return n - 1
+			}),
+		)
+
+		d, ok := b(0, 0)
+		assert.Equal(t, true, ok)
+		assert.Equal(t, 4*time.Millisecond, d)
+
+		d, ok = b(1, 4*time.Millisecond)
+		assert.Equal(t, true, ok)
+		assert.Equal(t, 6*time.Millisecond, d)
+
+		d, ok = b(2, 10*time.Millisecond)
+		assert.Equal(t, false, ok)
+		assert.Equal(t, time.Duration(0), d)
+	})
+
+	t.Run("default-values", func(t *testing.T) {
+		b := NewExponentialBackoff()
+		for i := 0; i < 6; i++ {
+			d, ok := b(i, 0)
+			assert.Equal(t, true, ok)
+			assert.LessOrEqual(t, d, 40*time.Millisecond)
+		}
+
+		_, ok := b(6, 0)
+		assert.Equal(t, false, ok)
+	})
+
+	t.Run("invalid-params", func(t *testing.T) {
+		assert.PanicsWithValue(t, "item: backoff base duration must be positive", func() {
+			NewExponentialBackoff(WithBackoffBaseDuration(0))
+		})
+		assert.PanicsWithValue(t, "item: backoff base duration must be positive", func() {
+			NewExponentialBackoff(WithBackoffBaseDuration(-time.Millisecond))
+		})
+		assert.PanicsWithValue(t, "item: backoff max duration must not be less than base duration", func() {
+			NewExponentialBackoff(
+				WithBackoffBaseDuration(10*time.Millisecond),
+				WithBackoffMaxDuration(time.Millisecond),
+			)
+		})
+		assert.PanicsWithValue(t, "item: backoff max retries must not be negative", func() {
+			NewExponentialBackoff(WithBackoffMaxRetries(-1))
+		})
+		assert.PanicsWithValue(t, "item: backoff total duration must not be negative", func() {
+			NewExponentialBackoff(WithBackoffTotalDuration(-time.Millisecond))
+		})
+	})
+}
@@ -0,0 +1,92 @@
+package item
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Stage is the step of getting an item where an error happened
+type Stage int
+
+const (
+	// StageLeaseGet for errors of lease gets, including ErrExceededRejectRetryLimit and ErrInvalidLeaseGetStatus
+	StageLeaseGet Stage = iota + 1
+
+	// StageUnmarshal for errors of the unmarshaler
+	StageUnmarshal
+
+	// StageFill for errors of the filler, including *FillTimeoutError and *FillLimitError
+	StageFill
+
+	// StageMarshal for errors of Value.Marshal
+	StageMarshal
+
+	// StageLeaseSet for errors of lease sets, ONLY passed to the error logger
+	StageLeaseSet
+)
+
+func (s Stage) String() string {
+	switch s {
+	case StageLeaseGet:
+		return "lease-get"
+	case StageUnmarshal:
+		return "unmarshal"
+	case StageFill:
+		return "fill"
+	case StageMarshal:
+		return "marshal"
+	case StageLeaseSet:
+		return "lease-set"
+	default:
+		return fmt.Sprintf("stage(%d)", int(s))
+	}
+}
+
+// Error wraps the errors returned from Item.Get and passed to the error logger,
+// the underlining error can be checked by errors.Is or errors.As
+type Error struct {
+	Key        string
+	Stage      Stage
+	RetryCount int // number of retried lease gets after rejected
+
+	// Server is the memcached server of the error, only available for errors
+	// implementing the method ServerName() string (e.g. *proxy.ServerError), empty otherwise
+	Server string
+
+	Err error
+}
+
+func (e *Error) Error() string {
+	if e.Server != "" {
+		return fmt.Sprintf(
+			"item: %v error, key: %s, retry count: %d, server: %s: %v",
+			e.Stage, e.Key, e.RetryCount, e.Server, e.Err,
+		)
+	}
+	return fmt.Sprintf("item: %v error, key: %s, retry count: %d: %v", e.Stage, e.Key, e.RetryCount, e.Err)
+}
+
+// Unwrap ...
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+type serverNamedError interface {
+	ServerName() string
+}
+
+func newError(key string, stage Stage, retryCount int, err error) *Error {
+	var server string
+	var serverErr serverNamedError
+	if errors.As(err, &serverErr) {
+		server = serverErr.ServerName()
+	}
+
+	return &Error{
+		Key:        key,
+		Stage:      stage,
+		RetryCount: retryCount,
+		Server:     server,
+		Err:        err,
+	}
+}
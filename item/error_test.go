@@ -0,0 +1,73 @@
+package item
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testServerError struct {
+	err error
+}
+
+func (e *testServerError) Error() string {
+	return "server 3: " + e.err.Error()
+}
+
+func (e *testServerError) Unwrap() error {
+	return e.err
+}
+
+func (*testServerError) ServerName() string {
+	return "3"
+}
+
+func TestError(t *testing.T) {
+	t.Run("normal", func(t *testing.T) {
+		err := newError("key01", StageLeaseGet, 2, ErrExceededRejectRetryLimit)
+		assert.Equal(t, &Error{
+			Key:        "key01",
+			Stage:      StageLeaseGet,
+			RetryCount: 2,
+			Err:        ErrExceededRejectRetryLimit,
+		}, err)
+
+		assert.Equal(t,
+			"item: lease-get error, key: key01, retry count: 2: item: exceeded lease rejected retry limit",
+			err.Error(),
+		)
+		assert.True(t, errors.Is(err, ErrExceededRejectRetryLimit))
+		assert.False(t, errors.Is(err, ErrNotFound))
+	})
+
+	t.Run("with-server", func(t *testing.T) {
+		serverErr := &testServerError{err: newTestError()}
+		err := newError("key01", StageLeaseSet, 0, serverErr)
+
+		assert.Equal(t, &Error{
+			Key:    "key01",
+			Stage:  StageLeaseSet,
+			Server: "3",
+			Err:    serverErr,
+		}, err)
+		assert.Equal(t,
+			"item: lease-set error, key: key01, retry count: 0, server: 3: server 3: test error",
+			err.Error(),
+		)
+	})
+
+	t.Run("wrapped-not-found", func(t *testing.T) {
+		err := newError("key01", StageFill, 0, ErrNotFound)
+		assert.True(t, errors.Is(err, ErrNotFound))
+	})
+}
+
+func TestStage_String(t *testing.T) {
+	assert.Equal(t, "lease-get", StageLeaseGet.String())
+	assert.Equal(t, "unmarshal", StageUnmarshal.String())
+	assert.Equal(t, "fill", StageFill.String())
+	assert.Equal(t, "marshal", StageMarshal.String())
+	assert.Equal(t, "lease-set", StageLeaseSet.String())
+	assert.Equal(t, "stage(0)", Stage(0).String())
+}
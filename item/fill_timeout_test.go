@@ -0,0 +1,201 @@
+package item
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/QuangTung97/memproxy"
+	"github.com/QuangTung97/memproxy/fake"
+)
+
+type fillTimeoutTest struct {
+	pipe memproxy.Pipeline
+
+	release   chan struct{}
+	fillCalls atomic.Int64
+	fillCtx   context.Context
+}
+
+func newFillTimeoutTest() *fillTimeoutTest {
+	return &fillTimeoutTest{
+		pipe:    fake.New().Pipeline(newContext()),
+		release: make(chan struct{}),
+	}
+}
+
+func (f *fillTimeoutTest) hangingFiller(ctx context.Context, key userKey) func() (userValue, error) {
+	f.fillCtx = ctx
+	return func() (userValue, error) {
+		f.fillCalls.Add(1)
+		<-f.release
+		return userValue{Tenant: key.Tenant, Name: key.Name, Age: 31}, nil
+	}
+}
+
+func TestItem_FillTimeout(t *testing.T) {
+	key := userKey{Tenant: "TENANT01", Name: "user01"}
+
+	t.Run("timeout--returns-error--and-release-lease", func(t *testing.T) {
+		f := newFillTimeoutTest()
+
+		var logErrors []error
+		it := New[userValue, userKey](
+			f.pipe, unmarshalUser, f.hangingFiller,
+			WithFillTimeout(20*time.Millisecond),
+			WithErrorLogger(func(err error) {
+				logErrors = append(logErrors, err)
+			}),
+		)
+
+		resp, err := it.Get(newContext(), key)()
+		assert.Equal(t, &Error{
+			Key:   "TENANT01:user01",
+			Stage: StageFill,
+			Err: &FillTimeoutError{
+				Key:     "TENANT01:user01",
+				Timeout: 20 * time.Millisecond,
+			},
+		}, err)
+		assert.True(t, errors.Is(err, ErrFillTimeout))
+		assert.Equal(t, "item: filler timeout after 20ms, key: TENANT01:user01", errors.Unwrap(err).Error())
+		assert.Equal(t, userValue{}, resp)
+		assert.Equal(t, []error{err}, logErrors)
+
+		assert.Equal(t, uint64(1), it.GetStats().FillTimeoutCount)
+		assert.NotNil(t, f.fillCtx.Err())
+
+		close(f.release)
+
+		// lease is released
+		resp, err = New[userValue, userKey](f.pipe, unmarshalUser, f.hangingFiller).Get(newContext(), key)()
+		assert.Equal(t, nil, err)
+		assert.Equal(t, userValue{Tenant: "TENANT01", Name: "user01", Age: 31}, resp)
+		assert.Equal(t, int64(2), f.fillCalls.Load())
+	})
+
+	t.Run("not-timeout--set-value", func(t *testing.T) {
+		f := newFillTimeoutTest()
+		close(f.release)
+
+		it := New[userValue, userKey](
+			f.pipe, unmarshalUser, f.hangingFiller,
+			WithFillTimeout(time.Second),
+		)
+
+		resp, err := it.Get(newContext(), key)()
+		assert.Equal(t, nil, err)
+		assert.Equal(t, userValue{Tenant: "TENANT01", Name: "user01", Age: 31}, resp)
+		assert.Equal(t, uint64(0), it.GetStats().FillTimeoutCount)
+
+		resp, found, err := it.Peek(newContext(), key)()
+		assert.Equal(t, nil, err)
+		assert.Equal(t, true, found)
+		assert.Equal(t, userValue{Tenant: "TENANT01", Name: "user01", Age: 31}, resp)
+	})
+
+	t.Run("timeout--with-fallback-filler", func(t *testing.T) {
+		f := newFillTimeoutTest()
+		defer close(f.release)
+
+		fallbackCalls := 0
+		it := New[userValue, userKey](
+			f.pipe, unmarshalUser, f.hangingFiller,
+			WithFillTimeout(20*time.Millisecond),
+			WithErrorLogger(func(err error) {}),
+		)
+		it.SetFallbackFiller(func(ctx context.Context, key userKey) func() (userValue, error) {
+			return func() (userValue, error) {
+				fallbackCalls++
+				return userValue{Tenant: key.Tenant, Name: key.Name, Age: 21}, nil
+			}
+		})
+
+		resp, err := it.Get(newContext(), key)()
+		assert.Equal(t, nil, err)
+		assert.Equal(t, userValue{Tenant: "TENANT01", Name: "user01", Age: 21}, resp)
+		assert.Equal(t, 1, fallbackCalls)
+
+		stats := it.GetStats()
+		assert.Equal(t, uint64(1), stats.FillTimeoutCount)
+		assert.Equal(t, uint64(1), stats.FallbackFillCount)
+
+		// fallback value is not set
+		_, found, err := it.Peek(newContext(), key)()
+		assert.Equal(t, nil, err)
+		assert.Equal(t, false, found)
+	})
+
+	t.Run("next-fill-waits-for-timed-out-fill", func(t *testing.T) {
+		f := newFillTimeoutTest()
+
+		it := New[userValue, userKey](
+			f.pipe, unmarshalUser, f.hangingFiller,
+			WithFillTimeout(20*time.Millisecond),
+			WithErrorLogger(func(err error) {}),
+		)
+
+		key2 := userKey{Tenant: "TENANT01", Name: "user02"}
+
+		fn1 := it.Get(newContext(), key)
+		fn2 := it.Get(newContext(), key2)
+
+		_, err := fn1()
+		assert.True(t, errors.Is(err, ErrFillTimeout))
+
+		_, err = fn2()
+		assert.True(t, errors.Is(err, ErrFillTimeout))
+
+		// the second filler function is not called while the first one still running
+		assert.Equal(t, int64(1), f.fillCalls.Load())
+		assert.Equal(t, uint64(2), it.GetStats().FillTimeoutCount)
+
+		close(f.release)
+	})
+
+	t.Run("multi-get-filler--not-called-while-timed-out-fill-running", func(t *testing.T) {
+		f := newFillTimeoutTest()
+
+		var multiGetCalls atomic.Int64
+		filler := NewMultiGetFiller[userValue, userKey](
+			func(ctx context.Context, keys []userKey) ([]userValue, error) {
+				multiGetCalls.Add(1)
+				<-f.release
+
+				result := make([]userValue, 0, len(keys))
+				for _, k := range keys {
+					result = append(result, userValue{Tenant: k.Tenant, Name: k.Name, Age: 41})
+				}
+				return result, nil
+			},
+			userValue.GetKey,
+		)
+
+		it := New[userValue, userKey](
+			f.pipe, unmarshalUser, filler,
+			WithFillTimeout(20*time.Millisecond),
+			WithErrorLogger(func(err error) {}),
+		)
+
+		key2 := userKey{Tenant: "TENANT01", Name: "user02"}
+
+		_, err := it.Get(newContext(), key)()
+		assert.True(t, errors.Is(err, ErrFillTimeout))
+
+		_, err = it.Get(newContext(), key2)()
+		assert.True(t, errors.Is(err, ErrFillTimeout))
+		assert.Equal(t, int64(1), multiGetCalls.Load())
+
+		close(f.release)
+
+		it.Reset()
+		resp, err := it.Get(newContext(), key2)()
+		assert.Equal(t, nil, err)
+		assert.Equal(t, userValue{Tenant: "TENANT01", Name: "user02", Age: 41}, resp)
+		assert.Equal(t, int64(2), multiGetCalls.Load())
+	})
+}
@@ -3,6 +3,7 @@ package item
 import (
 	"context"
 	"errors"
+	"fmt"
 	"log"
 	"time"
 	"unsafe"
@@ -30,10 +31,17 @@ type Unmarshaler[T any] func(data []byte) (T, error)
 type Filler[T any, K any] func(ctx context.Context, key K) func() (T, error)
 
 type itemOptions struct {
-	sleepDurations      []time.Duration
+	backoff             BackoffPolicy
+	retryCallback       func(retryCount int, d time.Duration)
 	errorOnRetryLimit   bool
 	fillingOnCacheError bool
 	errorLogger         func(err error)
+
+	fillTimeout time.Duration
+
+	fillLimiter *FillLimiter
+
+	maxValueSize int
 }
 
 // Option ...
@@ -55,7 +63,8 @@ func defaultErrorLogger(err error) {
 
 func computeOptions(options []Option) *itemOptions {
 	opts := &itemOptions{
-		sleepDurations:      DefaultSleepDurations(),
+		backoff:             NewFixedBackoff(DefaultSleepDurations()...),
+		retryCallback:       func(retryCount int, d time.Duration) {},
 		errorOnRetryLimit:   false,
 		fillingOnCacheError: false,
 		errorLogger:         defaultErrorLogger,
@@ -71,12 +80,28 @@ func computeOptions(options []Option) *itemOptions {
 // default is the value of DefaultSleepDurations()
 func WithSleepDurations(durations ...time.Duration) Option {
 	return func(opts *itemOptions) {
-		opts.sleepDurations = durations
+		opts.backoff = NewFixedBackoff(durations...)
+	}
+}
+
+// WithBackoffPolicy configures the sleep durations and number of retries after Lease Get returns Rejected status,
+// similar to WithSleepDurations but allows computing the durations dynamically, e.g. NewExponentialBackoff()
+func WithBackoffPolicy(policy BackoffPolicy) Option {
+	return func(opts *itemOptions) {
+		opts.backoff = policy
+	}
+}
+
+// WithRetryCallback configures the callback that is called before every sleep after Lease Get returns Rejected status,
+// often used for collecting metrics
+func WithRetryCallback(callback func(retryCount int, d time.Duration)) Option {
+	return func(opts *itemOptions) {
+		opts.retryCallback = callback
 	}
 }
 
 // WithEnableErrorOnExceedRetryLimit enables returning error if sleepDurations exceeded
-// when enable = true, and after retried all the durations configured by WithSleepDurations,
+// when enable = true, and after retried all the durations configured by WithSleepDurations or WithBackoffPolicy,
 // the Item.Get will return the error ErrExceededRejectRetryLimit
 // default enable = false
 func WithEnableErrorOnExceedRetryLimit(enable bool) Option {
@@ -93,13 +118,39 @@ func WithEnableFillingOnCacheError(enable bool) Option {
 	}
 }
 
-// WithErrorLogger configures the error logger when there are problems with the memcache client or unmarshalling
+// WithErrorLogger configures the error logger when there are problems with the memcache client or unmarshalling,
+// the errors are wrapped by *Error, containing the key and the stage of the error
 func WithErrorLogger(logger func(err error)) Option {
 	return func(opts *itemOptions) {
 		opts.errorLogger = logger
 	}
 }
 
+// WithFillTimeout configures the maximum duration waiting for the filler,
+// the context passed to the filler will also have this timeout.
+// When timed out, the lease is released by a Delete, and Item.Get returns a *FillTimeoutError,
+// or the value from the filler configured by Item.SetFallbackFiller.
+// The filler function is called in another goroutine when enabled, and after a timeout,
+// the next calls of the filler (and of the filler functions) of the same item will wait for the timed out call
+// to finish, so fillers that are NOT thread safe (e.g. NewMultiGetFiller) can be used,
+// but they must NOT be shared between items.
+// Default zero (no timeout)
+func WithFillTimeout(d time.Duration) Option {
+	return func(opts *itemOptions) {
+		opts.fillTimeout = d
+	}
+}
+
+// WithMaxValueSize configures the maximum size in bytes of the marshalled values set to the memcached servers,
+// should be the item size limit of the memcached servers (default 1MB for memcached).
+// Bigger values are still returned, but NOT set, the lease is released by a Delete instead.
+// Default zero (no limit)
+func WithMaxValueSize(size int) Option {
+	return func(opts *itemOptions) {
+		opts.maxValueSize = size
+	}
+}
+
 // ErrNotFound ONLY be returned from the filler function, to do delete of lease get key in the memcached server
 var ErrNotFound = errors.New("item: not found")
 
@@ -109,6 +160,27 @@ var ErrExceededRejectRetryLimit = errors.New("item: exceeded lease rejected retr
 // ErrInvalidLeaseGetStatus ...
 var ErrInvalidLeaseGetStatus = errors.New("item: invalid lease get response status")
 
+// ErrValueTooLarge passed to the error logger when the value exceeded the size configured by WithMaxValueSize
+var ErrValueTooLarge = errors.New("item: value too large")
+
+// ErrFillTimeout can be used with errors.Is for checking *FillTimeoutError
+var ErrFillTimeout = errors.New("item: filler timeout")
+
+// FillTimeoutError returned when the filler does not return in the duration configured by WithFillTimeout
+type FillTimeoutError struct {
+	Key     string
+	Timeout time.Duration
+}
+
+func (e *FillTimeoutError) Error() string {
+	return fmt.Sprintf("item: filler timeout after %v, key: %s", e.Timeout, e.Key)
+}
+
+// Is ...
+func (*FillTimeoutError) Is(target error) bool {
+	return target == ErrFillTimeout
+}
+
 type multiGetState[T any, K comparable] struct {
 	completed bool
 	keys      []K
@@ -200,9 +272,11 @@ func New[T Value, K Key](
 	filler Filler[T, K],
 	options ...Option,
 ) *Item[T, K] {
+	opts := computeOptions(options)
+
 	return &Item[T, K]{
 		common: itemCommon{
-			options:  computeOptions(options),
+			options:  opts,
 			sess:     pipeline.LowerSession(),
 			pipeline: pipeline,
 		},
@@ -214,11 +288,26 @@ func New[T Value, K Key](
 	}
 }
 
+// SetFallbackFiller configures the filler used after the filler timed out (e.g. getting from a read replica),
+// the value from the fallback filler is returned but NOT set to the memcached server.
+// Only used with WithFillTimeout, MUST be called before Get
+func (i *Item[T, K]) SetFallbackFiller(filler Filler[T, K]) {
+	i.fallbackFiller = filler
+}
+
+// SetVerifier configures the Verifier for cache hits, default nil (no verifying).
+// MUST be called before Get
+func (i *Item[T, K]) SetVerifier(v *Verifier[T, K]) {
+	i.verifier = v
+}
+
 // Item is NOT thread safe and, it contains a cached keys
 // once a key is cached in memory, it will return the same value unless call **Reset**
 type Item[T Value, K Key] struct {
-	unmarshaler Unmarshaler[T]
-	filler      Filler[T, K]
+	unmarshaler    Unmarshaler[T]
+	filler         Filler[T, K]
+	fallbackFiller Filler[T, K]
+	verifier       *Verifier[T, K]
 
 	getKeys map[K]*getResultType[T]
 
@@ -230,6 +319,9 @@ type itemCommon struct {
 	sess     memproxy.Session
 	pipeline memproxy.Pipeline
 	stats    Stats
+
+	// for waiting the timed out fill to finish before calling the next one
+	lastFillDone chan struct{}
 }
 
 func (i *itemCommon) addNextCall(fn func(obj unsafe.Pointer)) {
@@ -254,42 +346,226 @@ type getResultType[T any] struct {
 func (s *GetState[T, K]) handleLeaseGranted(cas uint64) {
 	it := s.getItem()
 
+	limiter := it.common.options.fillLimiter
+	if limiter != nil && !limiter.tryEnqueue() {
+		it.common.stats.FillLimitedCount++
+		s.releaseLeaseWithError(cas, &FillLimitError{
+			Key:           s.common.keyStr,
+			MaxQueuedKeys: limiter.maxQueuedKeys,
+		})
+		return
+	}
+
+	if it.common.options.fillTimeout > 0 {
+		s.handleLeaseGrantedWithTimeout(cas)
+		return
+	}
+
 	fillFn := it.filler(s.common.ctx, s.key)
 
 	it.common.addNextCall(func(_ unsafe.Pointer) {
-		it := s.common.item
+		if err := it.common.acquireFill(s.common.ctx); err != nil {
+			s.releaseLeaseWithError(cas, err)
+			return
+		}
 
 		fillResp, err := fillFn()
+		it.common.releaseFill()
 
-		if err == ErrNotFound {
-			s.setResponse(fillResp)
-			it.pipeline.Delete(s.common.keyStr, memproxy.DeleteOptions{})
-			return
+		s.handleFillResult(cas, fillResp, err)
+	})
+}
+
+func (s *GetState[T, K]) releaseLeaseWithError(cas uint64, err error) {
+	if cas > 0 {
+		s.common.item.pipeline.Delete(s.common.keyStr, memproxy.DeleteOptions{})
+	}
+	s.setResponseError(StageFill, err)
+}
+
+func (s *GetState[T, K]) handleLeaseGrantedWithTimeout(cas uint64) {
+	it := s.getItem()
+
+	ctx, cancel := context.WithTimeout(s.common.ctx, it.common.options.fillTimeout)
+	deadline, _ := ctx.Deadline()
+
+	// the filler is NOT called while the timed out fill is still running, because it may NOT be thread safe
+	if !it.common.waitLastFill(deadline) {
+		cancel()
+		it.common.cancelFill()
+		s.handleFillTimeout(cas)
+		return
+	}
+	fillFn := it.filler(ctx, s.key)
+
+	it.common.addNextCall(func(_ unsafe.Pointer) {
+		var fillResp T
+		var err error
+		ok := false
+
+		// waiting for the fill limiter is also counted in the timeout
+		if it.common.acquireFill(ctx) == nil {
+			fillResp, ok, err = runFillWithDeadline(&it.common, fillFn, deadline)
 		}
+		cancel()
 
-		if err != nil {
-			s.setResponseError(err)
+		if ok {
+			s.handleFillResult(cas, fillResp, err)
 			return
 		}
+		s.handleFillTimeout(cas)
+	})
+}
+
+func (s *GetState[T, K]) handleFillTimeout(cas uint64) {
+	it := s.getItem()
+	it.common.stats.FillTimeoutCount++
+
+	if it.fallbackFiller == nil {
+		s.releaseLeaseWithError(cas, &FillTimeoutError{
+			Key:     s.common.keyStr,
+			Timeout: it.common.options.fillTimeout,
+		})
+		return
+	}
+
+	if cas > 0 {
+		it.common.pipeline.Delete(s.common.keyStr, memproxy.DeleteOptions{})
+	}
+
+	it.common.stats.FallbackFillCount++
+	fallbackFn := it.fallbackFiller(s.common.ctx, s.key)
 
-		data, err := fillResp.Marshal()
-		if err != nil {
-			s.setResponseError(err)
+	it.common.addNextCall(func(_ unsafe.Pointer) {
+		fallbackResp, err := fallbackFn()
+		if err != nil && err != ErrNotFound {
+			s.setResponseError(StageFill, err)
 			return
 		}
+		s.setResponse(fallbackResp)
+	})
+}
+
+// waitLastFill waits for the timed out fill to finish, returns false when deadline exceeded
+func (i *itemCommon) waitLastFill(deadline time.Time) bool {
+	if i.lastFillDone == nil {
+		return true
+	}
+
+	timer := time.NewTimer(time.Until(deadline))
+	defer timer.Stop()
+
+	select {
+	case <-i.lastFillDone:
+		i.lastFillDone = nil
+		return true
+	case <-timer.C:
+		return false
+	}
+}
+
+// runFillWithDeadline calls fillFn in another goroutine, returns ok = false when deadline exceeded.
+// The fill slot acquired by the caller is released after fillFn returned, even when timed out
+func runFillWithDeadline[T any](
+	it *itemCommon, fillFn func() (T, error), deadline time.Time,
+) (T, bool, error) {
+	var empty T
+
+	if !it.waitLastFill(deadline) {
+		it.releaseFill()
+		return empty, false, nil
+	}
+
+	timer := time.NewTimer(time.Until(deadline))
+	defer timer.Stop()
+
+	done := make(chan struct{})
+
+	var result T
+	var err error
+
+	go func() {
+		defer close(done)
+		defer it.releaseFill()
+		result, err = fillFn()
+	}()
+
+	select {
+	case <-done:
+		return result, true, err
+	case <-timer.C:
+		it.lastFillDone = done
+		return empty, false, nil
+	}
+}
+
+func (s *GetState[T, K]) handleFillResult(cas uint64, fillResp T, err error) {
+	it := s.common.item
+
+	if err == ErrNotFound {
 		s.setResponse(fillResp)
+		it.pipeline.Delete(s.common.keyStr, memproxy.DeleteOptions{})
+		return
+	}
 
-		if cas > 0 {
-			_ = it.pipeline.LeaseSet(s.common.keyStr, data, cas, memproxy.LeaseSetOptions{})
-			it.addNextCall(func(obj unsafe.Pointer) {
-				s.common.item.pipeline.Execute()
-			})
-		}
+	if err != nil {
+		s.setResponseError(StageFill, err)
+		return
+	}
+
+	data, err := fillResp.Marshal()
+	if err != nil {
+		s.setResponseError(StageMarshal, err)
+		return
+	}
+	s.setResponse(fillResp)
+
+	if cas > 0 {
+		it.doLeaseSet(s.common.keyStr, data, cas, s.common.retryCount)
+	}
+}
+
+// doLeaseSet sets the value and collects the lease set result in the later rounds of the session
+func (i *itemCommon) doLeaseSet(keyStr string, data []byte, cas uint64, retryCount int) {
+	if i.options.maxValueSize > 0 && len(data) > i.options.maxValueSize {
+		// releasing the lease, otherwise the other clients will be rejected until the lease expired
+		i.stats.ValueTooLargeCount++
+		i.options.errorLogger(newError(keyStr, StageLeaseSet, retryCount, ErrValueTooLarge))
+		i.pipeline.Delete(keyStr, memproxy.DeleteOptions{})
+		return
+	}
+
+	setFn := i.pipeline.LeaseSet(keyStr, data, cas, memproxy.LeaseSetOptions{})
+
+	i.addNextCall(func(_ unsafe.Pointer) {
+		i.pipeline.Execute()
+
+		i.addNextCall(func(_ unsafe.Pointer) {
+			i.handleLeaseSetResult(keyStr, retryCount, setFn)
+		})
 	})
 }
 
+func (i *itemCommon) handleLeaseSetResult(
+	keyStr string, retryCount int,
+	setFn func() (memproxy.LeaseSetResponse, error),
+) {
+	resp, err := setFn()
+	if err != nil {
+		i.stats.LeaseSetError++
+		i.options.errorLogger(newError(keyStr, StageLeaseSet, retryCount, err))
+		return
+	}
+
+	if resp.Status == memproxy.LeaseSetStatusStored {
+		i.stats.LeaseSetStoredCount++
+	} else {
+		i.stats.LeaseSetNotStoredCount++
+	}
+}
+
 type getStateMethods interface {
-	setResponseError(err error)
+	setResponseError(stage Stage, err error)
 	doFillFunc(cas uint64)
 	unmarshalAndSet(data []byte)
 }
@@ -300,8 +576,9 @@ type getStateCommon struct {
 	itemRoot unsafe.Pointer
 	item     *itemCommon
 
-	retryCount int
-	keyStr     string
+	retryCount    int
+	sleptDuration time.Duration
+	keyStr        string
 
 	leaseGetResult memproxy.LeaseGetResult
 
@@ -323,20 +600,26 @@ func (s *GetState[T, K]) unmarshalAndSet(data []byte) {
 	it := s.getItem()
 	resp, err := it.unmarshaler(data)
 
+	if it.verifier != nil {
+		it.verifier.sampleHit(s.key, data)
+	}
+
 	memcache.ReleaseGetResponseData(data)
 
 	if err != nil {
-		s.setResponseError(err)
+		s.setResponseError(StageUnmarshal, err)
 		return
 	}
 	s.setResponse(resp)
 }
 
-func (s *GetState[T, K]) setResponseError(err error) {
+func (s *GetState[T, K]) setResponseError(stage Stage, err error) {
 	it := s.getItem()
 
-	s.common.item.options.errorLogger(err)
-	it.getKeys[s.key].err = err
+	wrapped := newError(s.common.keyStr, stage, s.common.retryCount, err)
+
+	s.common.item.options.errorLogger(wrapped)
+	it.getKeys[s.key].err = wrapped
 }
 
 func (s *GetState[T, K]) setResponse(resp T) {
@@ -352,10 +635,10 @@ func (s *GetState[T, K]) doFillFunc(cas uint64) {
 func (s *getStateCommon) handleCacheError(err error) {
 	s.item.stats.LeaseGetError++
 	if s.item.options.fillingOnCacheError {
-		s.item.options.errorLogger(err)
+		s.item.options.errorLogger(newError(s.keyStr, StageLeaseGet, s.retryCount, err))
 		s.methods.doFillFunc(0)
 	} else {
-		s.methods.setResponseError(err)
+		s.methods.setResponseError(StageLeaseGet, err)
 	}
 }
 
@@ -376,14 +659,16 @@ func (s *getStateCommon) nextFunc() {
 
 	s.leaseGetResult = nil
 
+	it := s.item
+
 	if err != nil {
+		it.increaseRetryHistogram(s.retryCount)
 		s.handleCacheError(err)
 		return
 	}
 
-	it := s.item
-
 	if leaseGetResp.Status == memproxy.LeaseGetStatusFound {
+		it.increaseRetryHistogram(s.retryCount)
 		it.stats.HitCount++
 		it.stats.TotalBytesRecv += uint64(len(leaseGetResp.Data))
 
@@ -392,6 +677,7 @@ func (s *getStateCommon) nextFunc() {
 	}
 
 	if leaseGetResp.Status == memproxy.LeaseGetStatusLeaseGranted {
+		it.increaseRetryHistogram(s.retryCount)
 		s.methods.doFillFunc(leaseGetResp.CAS)
 		return
 	}
@@ -399,9 +685,13 @@ func (s *getStateCommon) nextFunc() {
 	if leaseGetResp.Status == memproxy.LeaseGetStatusLeaseRejected {
 		it.increaseRejectedCount(s.retryCount)
 
-		if s.retryCount < len(it.options.sleepDurations) {
-			it.addDelayedCall(it.options.sleepDurations[s.retryCount], func(_ unsafe.Pointer) {
+		sleepDuration, ok := it.options.backoff(s.retryCount, s.sleptDuration)
+		if ok {
+			it.options.retryCallback(s.retryCount, sleepDuration)
+
+			it.addDelayedCall(sleepDuration, func(_ unsafe.Pointer) {
 				s.retryCount++
+				s.sleptDuration += sleepDuration
 
 				s.leaseGetResult = it.pipeline.LeaseGet(s.keyStr, memproxy.LeaseGetOptions{})
 				it.sess.AddNextCall(s.newNextCallback())
@@ -409,15 +699,18 @@ func (s *getStateCommon) nextFunc() {
 			return
 		}
 
+		it.increaseRetryHistogram(s.retryCount)
+
 		if !it.options.errorOnRetryLimit {
 			s.methods.doFillFunc(leaseGetResp.CAS)
 			return
 		}
 
-		s.methods.setResponseError(ErrExceededRejectRetryLimit)
+		s.methods.setResponseError(StageLeaseGet, ErrExceededRejectRetryLimit)
 		return
 	}
 
+	it.increaseRetryHistogram(s.retryCount)
 	s.handleCacheError(ErrInvalidLeaseGetStatus)
 }
 
@@ -507,6 +800,13 @@ func (i *itemCommon) increaseRejectedCount(retryCount int) {
 	}
 }
 
+func (i *itemCommon) increaseRetryHistogram(retryCount int) {
+	if retryCount >= RetryHistogramSize {
+		retryCount = RetryHistogramSize - 1
+	}
+	i.stats.RetryHistogram[retryCount]++
+}
+
 // LowerSession ...
 func (i *Item[T, K]) LowerSession() memproxy.Session {
 	return i.common.sess.GetLower()
@@ -524,14 +824,35 @@ type Stats struct {
 
 	LeaseGetError uint64 // lease get error count
 
+	LeaseSetStoredCount    uint64 // number of filled values stored to the memcached servers
+	LeaseSetNotStoredCount uint64 // number of filled values NOT stored, because the lease is deleted or changed
+	LeaseSetError          uint64 // lease set error count
+	ValueTooLargeCount     uint64 // number of filled values NOT stored because exceeded the max value size
+
 	FirstRejectedCount  uint64
 	SecondRejectedCount uint64
 	ThirdRejectedCount  uint64
 	TotalRejectedCount  uint64
 
+	// RetryHistogram counts the number of lease get retries of every finished key,
+	// RetryHistogram[n] is the number of keys that finished after exactly n retries,
+	// the last element also includes the keys with more retries
+	RetryHistogram [RetryHistogramSize]uint64
+
 	TotalBytesRecv uint64
+
+	FillTimeoutCount  uint64 // number of fills timed out, configured by WithFillTimeout
+	FillLimitedCount  uint64 // number of fills rejected by the limiter configured by WithFillLimiter
+	FallbackFillCount uint64 // number of fills using the filler configured by Item.SetFallbackFiller
+
+	PeekHitCount  uint64 // number of Peek calls found the key
+	PeekMissCount uint64 // number of Peek calls NOT found the key
+	RefreshCount  uint64 // number of Refresh calls filled the key
 }
 
+// RetryHistogramSize is the number of elements of Stats.RetryHistogram
+const RetryHistogramSize = 16
+
 // GetStats ...
 func (i *Item[T, K]) GetStats() Stats {
 	return i.common.stats
@@ -154,7 +154,7 @@ func newItemTestWithSleepDurations(
 		return i.fillFunc(ctx, key)
 	}
 
-	options = append(options, WithSleepDurations(sleepDurations...))
+	options = append([]Option{WithSleepDurations(sleepDurations...)}, options...)
 	i.item = New(pipe, unmarshalUser, userFiller, options...)
 
 	// stubbing
@@ -194,13 +194,17 @@ func (i *itemTest) stubLeaseGetMulti(respList ...memproxy.LeaseGetResponse) {
 }
 
 func (i *itemTest) stubLeaseSet() {
+	i.stubLeaseSetResp(memproxy.LeaseSetResponse{}, nil)
+}
+
+func (i *itemTest) stubLeaseSetResp(resp memproxy.LeaseSetResponse, err error) {
 	i.pipe.LeaseSetFunc = func(
 		key string, data []byte, cas uint64, options memproxy.LeaseSetOptions,
 	) func() (memproxy.LeaseSetResponse, error) {
 		i.appendAction(leaseSetAction(key))
 		return func() (memproxy.LeaseSetResponse, error) {
 			i.appendAction(leaseSetFuncAction(key))
-			return memproxy.LeaseSetResponse{}, nil
+			return resp, err
 		}
 	}
 }
@@ -288,7 +292,11 @@ func TestItem(t *testing.T) {
 		})
 
 		resp, err := fn()
-		assert.Equal(t, newTestError(), err)
+		assert.Equal(t, &Error{
+			Key:   "TENANT01:USER01",
+			Stage: StageLeaseGet,
+			Err:   newTestError(),
+		}, err)
 		assert.Equal(t, userValue{}, resp)
 	})
 
@@ -400,6 +408,87 @@ func TestItem(t *testing.T) {
 	})
 }
 
+func TestItem__LeaseSet_Outcomes(t *testing.T) {
+	user := userValue{
+		Tenant: "TENANT01",
+		Name:   "USER01",
+		Age:    88,
+	}
+
+	newTest := func(options ...Option) *itemTest {
+		i := newItemTest(options...)
+		i.stubLeaseGet(memproxy.LeaseGetResponse{
+			Status: memproxy.LeaseGetStatusLeaseGranted,
+			CAS:    8231,
+		}, nil)
+		i.stubFillMulti(user)
+		return i
+	}
+
+	t.Run("stored", func(t *testing.T) {
+		i := newTest()
+		i.stubLeaseSetResp(memproxy.LeaseSetResponse{Status: memproxy.LeaseSetStatusStored}, nil)
+
+		resp, err := i.item.Get(newContext(), user.GetKey())()
+		assert.Equal(t, nil, err)
+		assert.Equal(t, user, resp)
+
+		stats := i.item.GetStats()
+		assert.Equal(t, uint64(1), stats.LeaseSetStoredCount)
+		assert.Equal(t, uint64(0), stats.LeaseSetNotStoredCount)
+		assert.Equal(t, uint64(0), stats.LeaseSetError)
+
+		assert.Equal(t, []string{
+			leaseGetAction(user.GetKey().String()),
+			leaseGetFuncAction(user.GetKey().String()),
+			fillAction(user.GetKey().String()),
+			fillFuncAction(user.GetKey().String()),
+			leaseSetAction(user.GetKey().String()),
+			executeAction(),
+			leaseSetFuncAction(user.GetKey().String()),
+		}, i.actions)
+	})
+
+	t.Run("not-stored", func(t *testing.T) {
+		i := newTest()
+		i.stubLeaseSetResp(memproxy.LeaseSetResponse{Status: memproxy.LeaseSetStatusNotStored}, nil)
+
+		resp, err := i.item.Get(newContext(), user.GetKey())()
+		assert.Equal(t, nil, err)
+		assert.Equal(t, user, resp)
+
+		stats := i.item.GetStats()
+		assert.Equal(t, uint64(0), stats.LeaseSetStoredCount)
+		assert.Equal(t, uint64(1), stats.LeaseSetNotStoredCount)
+		assert.Equal(t, uint64(0), stats.LeaseSetError)
+	})
+
+	t.Run("error", func(t *testing.T) {
+		var logErrors []error
+		i := newTest(WithErrorLogger(func(err error) {
+			logErrors = append(logErrors, err)
+		}))
+		i.stubLeaseSetResp(memproxy.LeaseSetResponse{}, errors.New("lease set error"))
+
+		resp, err := i.item.Get(newContext(), user.GetKey())()
+		assert.Equal(t, nil, err)
+		assert.Equal(t, user, resp)
+
+		stats := i.item.GetStats()
+		assert.Equal(t, uint64(0), stats.LeaseSetStoredCount)
+		assert.Equal(t, uint64(0), stats.LeaseSetNotStoredCount)
+		assert.Equal(t, uint64(1), stats.LeaseSetError)
+
+		assert.Equal(t, []error{
+			&Error{
+				Key:   "TENANT01:USER01",
+				Stage: StageLeaseSet,
+				Err:   errors.New("lease set error"),
+			},
+		}, logErrors)
+	})
+}
+
 func TestItem__LeaseRejected__Do_Sleep(t *testing.T) {
 	t.Run("lease-rejected-second-lease-get-found", func(t *testing.T) {
 		i := newItemTestWithSleepDurations([]time.Duration{
@@ -509,6 +598,84 @@ func TestItem__LeaseRejected__Do_Sleep(t *testing.T) {
 		assert.Equal(t, uint64(1), stats.SecondRejectedCount)
 		assert.Equal(t, uint64(1), stats.ThirdRejectedCount)
 		assert.Equal(t, uint64(3), stats.TotalRejectedCount)
+
+		var histogram [RetryHistogramSize]uint64
+		histogram[3] = 1
+		assert.Equal(t, histogram, stats.RetryHistogram)
+	})
+
+	t.Run("lease-rejected-with-backoff-policy", func(t *testing.T) {
+		type retryCall struct {
+			retryCount int
+			elapsed    time.Duration
+		}
+		var policyCalls []retryCall
+		var callbackCalls []retryCall
+
+		i := newItemTest(
+			WithBackoffPolicy(func(retryCount int, elapsed time.Duration) (time.Duration, bool) {
+				policyCalls = append(policyCalls, retryCall{retryCount: retryCount, elapsed: elapsed})
+				if retryCount >= 2 {
+					return 0, false
+				}
+				return time.Duration(retryCount+1) * 5 * time.Millisecond, true
+			}),
+			WithRetryCallback(func(retryCount int, d time.Duration) {
+				callbackCalls = append(callbackCalls, retryCall{retryCount: retryCount, elapsed: d})
+			}),
+			WithEnableErrorOnExceedRetryLimit(true),
+		)
+
+		i.stubLeaseGetMulti(
+			memproxy.LeaseGetResponse{
+				Status: memproxy.LeaseGetStatusLeaseRejected,
+			},
+			memproxy.LeaseGetResponse{
+				Status: memproxy.LeaseGetStatusLeaseRejected,
+			},
+			memproxy.LeaseGetResponse{
+				Status: memproxy.LeaseGetStatusLeaseRejected,
+			},
+		)
+
+		fn := i.item.Get(newContext(), userKey{
+			Tenant: "TENANT01",
+			Name:   "USER01",
+		})
+
+		result, err := fn()
+		assert.Equal(t, &Error{
+			Key:        "TENANT01:USER01",
+			Stage:      StageLeaseGet,
+			RetryCount: 2,
+			Err:        ErrExceededRejectRetryLimit,
+		}, err)
+		assert.Equal(t, userValue{}, result)
+
+		assert.Equal(t, 3, len(i.pipe.LeaseGetCalls()))
+
+		assert.Equal(t, []time.Duration{
+			5 * time.Millisecond,
+			10 * time.Millisecond,
+		}, i.delayCalls)
+
+		assert.Equal(t, []retryCall{
+			{retryCount: 0, elapsed: 0},
+			{retryCount: 1, elapsed: 5 * time.Millisecond},
+			{retryCount: 2, elapsed: 15 * time.Millisecond},
+		}, policyCalls)
+
+		assert.Equal(t, []retryCall{
+			{retryCount: 0, elapsed: 5 * time.Millisecond},
+			{retryCount: 1, elapsed: 10 * time.Millisecond},
+		}, callbackCalls)
+
+		stats := i.item.GetStats()
+		assert.Equal(t, uint64(3), stats.TotalRejectedCount)
+
+		var histogram [RetryHistogramSize]uint64
+		histogram[2] = 1
+		assert.Equal(t, histogram, stats.RetryHistogram)
 	})
 
 	t.Run("lease-rejected-exceed-max-number-of-times--returns-error", func(t *testing.T) {
@@ -539,7 +706,7 @@ func TestItem__LeaseRejected__Do_Sleep(t *testing.T) {
 		})
 
 		result, err := fn()
-		assert.Equal(t, ErrExceededRejectRetryLimit, err)
+		assert.True(t, errors.Is(err, ErrExceededRejectRetryLimit))
 		assert.Equal(t, userValue{}, result)
 
 		calls := i.pipe.LeaseGetCalls()
@@ -577,7 +744,7 @@ func TestItem__LeaseRejected__Do_Sleep(t *testing.T) {
 		})
 
 		result, err := fn()
-		assert.Equal(t, ErrInvalidLeaseGetStatus, err)
+		assert.True(t, errors.Is(err, ErrInvalidLeaseGetStatus))
 		assert.Equal(t, userValue{}, result)
 
 		calls := i.pipe.LeaseGetCalls()
@@ -680,7 +847,7 @@ func TestItem__LeaseRejected__Do_Sleep(t *testing.T) {
 		})
 
 		result, err := fn()
-		assert.Equal(t, errors.New("lease get error"), err)
+		assert.Equal(t, errors.New("lease get error"), errors.Unwrap(err))
 		assert.Equal(t, userValue{}, result)
 
 		calls := i.pipe.LeaseGetCalls()
@@ -737,7 +904,11 @@ func TestItem__LeaseRejected__Do_Sleep(t *testing.T) {
 
 		assert.Equal(t, 0, len(i.pipe.LeaseSetCalls()))
 
-		assert.Equal(t, errors.New("lease get error"), logErr)
+		assert.Equal(t, &Error{
+			Key:   "TENANT01:USER01",
+			Stage: StageLeaseGet,
+			Err:   errors.New("lease get error"),
+		}, logErr)
 
 		stats := i.item.GetStats()
 		assert.Equal(t, uint64(0), stats.HitCount)
@@ -774,7 +945,11 @@ func TestItem__LeaseRejected__Do_Sleep(t *testing.T) {
 		})
 
 		result, err := fn()
-		assert.Equal(t, errors.New("fill error"), err)
+		assert.Equal(t, &Error{
+			Key:   "TENANT01:USER01",
+			Stage: StageFill,
+			Err:   errors.New("fill error"),
+		}, err)
 		assert.Equal(t, userValue{}, result)
 
 		calls := i.pipe.LeaseGetCalls()
@@ -783,7 +958,7 @@ func TestItem__LeaseRejected__Do_Sleep(t *testing.T) {
 
 		assert.Equal(t, 0, len(i.pipe.LeaseSetCalls()))
 
-		assert.Equal(t, errors.New("fill error"), logErr)
+		assert.Equal(t, err, logErr)
 	})
 }
 
@@ -856,6 +1031,9 @@ func TestItem__Multi(t *testing.T) {
 
 			executeAction(),
 			executeAction(),
+
+			leaseSetFuncAction(user1.GetKey().String()),
+			leaseSetFuncAction(user2.GetKey().String()),
 		}, i.actions)
 
 		// Check Stats
@@ -914,6 +1092,8 @@ func TestItem__Multi(t *testing.T) {
 			leaseSetAction(user1.GetKey().String()),
 
 			executeAction(),
+
+			leaseSetFuncAction(user1.GetKey().String()),
 		}, i.actions)
 
 		stats := i.item.GetStats()
@@ -1048,7 +1228,7 @@ func TestItem_GetMulti(t *testing.T) {
 			{Tenant: "TENANT01", Name: "USER01"},
 		})
 		users, err := fn()
-		assert.Equal(t, errors.New("lease get error"), err)
+		assert.Equal(t, errors.New("lease get error"), errors.Unwrap(err))
 		assert.Equal(t, []userValue(nil), users)
 
 		calls := i.pipe.LeaseGetCalls()
@@ -1340,6 +1520,58 @@ func TestItem_WithFakePipeline(t *testing.T) {
 	assert.Equal(t, 1, fillCalls)
 }
 
+func TestItem_MaxValueSize(t *testing.T) {
+	pipe := fake.New().Pipeline(newContext())
+
+	user := userValue{
+		Tenant: "TENANT01",
+		Name:   "user01",
+		Age:    22,
+	}
+
+	var logErrors []error
+	fillCalls := 0
+
+	newItem := func() *Item[userValue, userKey] {
+		return New[userValue, userKey](
+			pipe, unmarshalUser,
+			func(ctx context.Context, key userKey) func() (userValue, error) {
+				return func() (userValue, error) {
+					fillCalls++
+					return user, nil
+				}
+			},
+			WithMaxValueSize(20),
+			WithSleepDurations(),
+			WithEnableErrorOnExceedRetryLimit(true),
+			WithErrorLogger(func(err error) {
+				logErrors = append(logErrors, err)
+			}),
+		)
+	}
+
+	it := newItem()
+
+	resp, err := it.Get(newContext(), user.GetKey())()
+	assert.Equal(t, nil, err)
+	assert.Equal(t, user, resp)
+
+	assert.Equal(t, uint64(1), it.GetStats().ValueTooLargeCount)
+	assert.Equal(t, []error{
+		&Error{
+			Key:   "TENANT01:user01",
+			Stage: StageLeaseSet,
+			Err:   ErrValueTooLarge,
+		},
+	}, logErrors)
+
+	// lease is released, not rejected
+	resp, err = newItem().Get(newContext(), user.GetKey())()
+	assert.Equal(t, nil, err)
+	assert.Equal(t, user, resp)
+	assert.Equal(t, 2, fillCalls)
+}
+
 func TestSizeOfStateCommon(t *testing.T) {
-	assert.Equal(t, uintptr(88), unsafe.Sizeof(getStateCommon{}))
+	assert.Equal(t, uintptr(96), unsafe.Sizeof(getStateCommon{}))
 }
@@ -0,0 +1,168 @@
+package item
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ErrFillLimitExceeded can be used with errors.Is for checking *FillLimitError
+var ErrFillLimitExceeded = errors.New("item: fill limit exceeded")
+
+// FillLimitError returned when the number of keys waiting for filling exceeded the limit of the FillLimiter
+type FillLimitError struct {
+	Key           string
+	MaxQueuedKeys int
+}
+
+func (e *FillLimitError) Error() string {
+	return fmt.Sprintf("item: fill limit exceeded, max queued keys: %d, key: %s", e.MaxQueuedKeys, e.Key)
+}
+
+// Is ...
+func (*FillLimitError) Is(target error) bool {
+	return target == ErrFillLimitExceeded
+}
+
+// FillLimiter limits the number of concurrent calls to the filler functions
+// and the number of keys waiting for filling, often is a process-wide object shared between items.
+// A filler function call of NewMultiGetFiller runs the whole batch in one concurrent slot,
+// the other keys of the batch only hold a slot for getting the result from the batch.
+// FillLimiter is thread safe
+type FillLimiter struct {
+	slots         chan struct{}
+	maxQueuedKeys int
+
+	mut          sync.Mutex
+	queuedKeys   int
+	waitingFills int
+}
+
+// NewFillLimiter creates a FillLimiter, used with the option WithFillLimiter.
+// Param: maxConcurrentFills is the maximum number of filler function calls running at the same time.
+// Param: maxQueuedKeys is the maximum number of keys waiting or being filled,
+// the fills of new keys are rejected with a *FillLimitError after exceeded.
+// Both params must be positive
+func NewFillLimiter(maxConcurrentFills int, maxQueuedKeys int) *FillLimiter {
+	if maxConcurrentFills <= 0 {
+		panic("item: max concurrent fills must be positive")
+	}
+	if maxQueuedKeys <= 0 {
+		panic("item: max queued keys must be positive")
+	}
+	return &FillLimiter{
+		slots:         make(chan struct{}, maxConcurrentFills),
+		maxQueuedKeys: maxQueuedKeys,
+	}
+}
+
+// WithFillLimiter configures the FillLimiter for the filler, default nil (no limit).
+// The lease is released by a Delete when the fill is rejected
+func WithFillLimiter(limiter *FillLimiter) Option {
+	return func(opts *itemOptions) {
+		opts.fillLimiter = limiter
+	}
+}
+
+func (l *FillLimiter) tryEnqueue() bool {
+	l.mut.Lock()
+	defer l.mut.Unlock()
+
+	if l.queuedKeys >= l.maxQueuedKeys {
+		return false
+	}
+	l.queuedKeys++
+	return true
+}
+
+func (l *FillLimiter) dequeue() {
+	l.mut.Lock()
+	l.queuedKeys--
+	l.mut.Unlock()
+}
+
+func (l *FillLimiter) setWaiting(delta int) {
+	l.mut.Lock()
+	l.waitingFills += delta
+	l.mut.Unlock()
+}
+
+func (l *FillLimiter) acquire(ctx context.Context) error {
+	select {
+	case l.slots <- struct{}{}:
+		return nil
+	default:
+	}
+
+	l.setWaiting(1)
+	defer l.setWaiting(-1)
+
+	select {
+	case l.slots <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (l *FillLimiter) release() {
+	<-l.slots
+}
+
+// QueueDepth returns the number of keys waiting or being filled
+func (l *FillLimiter) QueueDepth() int {
+	l.mut.Lock()
+	defer l.mut.Unlock()
+	return l.queuedKeys
+}
+
+// FillLimiterStats ...
+type FillLimiterStats struct {
+	QueuedKeys   int // number of keys waiting or being filled
+	WaitingFills int // number of filler function calls waiting for a slot
+	RunningFills int // number of filler function calls running
+}
+
+// GetStats ...
+func (l *FillLimiter) GetStats() FillLimiterStats {
+	l.mut.Lock()
+	defer l.mut.Unlock()
+
+	return FillLimiterStats{
+		QueuedKeys:   l.queuedKeys,
+		WaitingFills: l.waitingFills,
+		RunningFills: len(l.slots),
+	}
+}
+
+func (i *itemCommon) acquireFill(ctx context.Context) error {
+	limiter := i.options.fillLimiter
+	if limiter == nil {
+		return nil
+	}
+
+	if err := limiter.acquire(ctx); err != nil {
+		limiter.dequeue()
+		return err
+	}
+	return nil
+}
+
+func (i *itemCommon) releaseFill() {
+	limiter := i.options.fillLimiter
+	if limiter == nil {
+		return
+	}
+	limiter.release()
+	limiter.dequeue()
+}
+
+// cancelFill removes the key enqueued by handleLeaseGranted when the fill is not started
+func (i *itemCommon) cancelFill() {
+	limiter := i.options.fillLimiter
+	if limiter == nil {
+		return
+	}
+	limiter.dequeue()
+}
@@ -0,0 +1,211 @@
+package item
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/QuangTung97/memproxy/fake"
+)
+
+type limiterTest struct {
+	it      *Item[userValue, userKey]
+	limiter *FillLimiter
+
+	fillKeys [][]userKey
+}
+
+func newLimiterTest(maxConcurrent int, maxQueued int) *limiterTest {
+	l := &limiterTest{
+		limiter: NewFillLimiter(maxConcurrent, maxQueued),
+	}
+
+	filler := NewMultiGetFiller[userValue, userKey](
+		func(ctx context.Context, keys []userKey) ([]userValue, error) {
+			l.fillKeys = append(l.fillKeys, keys)
+
+			var result []userValue
+			for _, k := range keys {
+				result = append(result, userValue{Tenant: k.Tenant, Name: k.Name, Age: 20})
+			}
+			return result, nil
+		},
+		userValue.GetKey,
+	)
+
+	pipe := fake.New().Pipeline(newContext())
+	l.it = New[userValue, userKey](
+		pipe, unmarshalUser, filler,
+		WithFillLimiter(l.limiter),
+		WithErrorLogger(func(err error) {}),
+	)
+	return l
+}
+
+func TestItem_FillLimiter(t *testing.T) {
+	key1 := userKey{Tenant: "TENANT01", Name: "user01"}
+	key2 := userKey{Tenant: "TENANT01", Name: "user02"}
+	key3 := userKey{Tenant: "TENANT01", Name: "user03"}
+
+	t.Run("batching-fills", func(t *testing.T) {
+		l := newLimiterTest(1, 10)
+
+		values, err := l.it.GetMulti(newContext(), []userKey{key1, key2, key3})()
+		assert.Equal(t, nil, err)
+		assert.Equal(t, 3, len(values))
+
+		assert.Equal(t, [][]userKey{{key1, key2, key3}}, l.fillKeys)
+		assert.Equal(t, FillLimiterStats{}, l.limiter.GetStats())
+	})
+
+	t.Run("exceeded-max-queued-keys", func(t *testing.T) {
+		l := newLimiterTest(1, 2)
+
+		fn1 := l.it.Get(newContext(), key1)
+		fn2 := l.it.Get(newContext(), key2)
+		fn3 := l.it.Get(newContext(), key3)
+
+		_, err := fn1()
+		assert.Equal(t, nil, err)
+
+		_, err = fn2()
+		assert.Equal(t, nil, err)
+
+		_, err = fn3()
+		assert.Equal(t, &FillLimitError{
+			Key:           "TENANT01:user03",
+			MaxQueuedKeys: 2,
+		}, errors.Unwrap(err))
+		assert.True(t, errors.Is(err, ErrFillLimitExceeded))
+		assert.Equal(t,
+			"item: fill limit exceeded, max queued keys: 2, key: TENANT01:user03",
+			errors.Unwrap(err).Error(),
+		)
+
+		assert.Equal(t, [][]userKey{{key1, key2}}, l.fillKeys)
+		assert.Equal(t, uint64(1), l.it.GetStats().FillLimitedCount)
+		assert.Equal(t, 0, l.limiter.QueueDepth())
+
+		// lease is released
+		l.it.Reset()
+		value, err := l.it.Get(newContext(), key3)()
+		assert.Equal(t, nil, err)
+		assert.Equal(t, userValue{Tenant: "TENANT01", Name: "user03", Age: 20}, value)
+	})
+
+	t.Run("wait-for-running-fills", func(t *testing.T) {
+		l := newLimiterTest(1, 10)
+
+		assert.Equal(t, nil, l.limiter.acquire(context.Background()))
+
+		done := make(chan error)
+		go func() {
+			_, err := l.it.Get(newContext(), key1)()
+			done <- err
+		}()
+
+		assert.Eventually(t, func() bool {
+			return l.limiter.GetStats() == FillLimiterStats{
+				QueuedKeys:   1,
+				WaitingFills: 1,
+				RunningFills: 1,
+			}
+		}, time.Second, time.Millisecond)
+
+		l.limiter.release()
+		assert.Equal(t, nil, <-done)
+
+		assert.Equal(t, FillLimiterStats{}, l.limiter.GetStats())
+	})
+
+	t.Run("context-cancelled-while-waiting", func(t *testing.T) {
+		l := newLimiterTest(1, 10)
+
+		assert.Equal(t, nil, l.limiter.acquire(context.Background()))
+		defer l.limiter.release()
+
+		ctx, cancel := context.WithCancel(newContext())
+		cancel()
+
+		_, err := l.it.Get(ctx, key1)()
+		assert.True(t, errors.Is(err, context.Canceled))
+		assert.Equal(t, 0, len(l.fillKeys))
+		assert.Equal(t, 0, l.limiter.QueueDepth())
+	})
+
+	t.Run("timed-out-fill--holds-slot-until-filler-returned", func(t *testing.T) {
+		limiter := NewFillLimiter(1, 10)
+		release := make(chan struct{})
+
+		it := New[userValue, userKey](
+			fake.New().Pipeline(newContext()), unmarshalUser,
+			func(ctx context.Context, key userKey) func() (userValue, error) {
+				return func() (userValue, error) {
+					<-release
+					return userValue{Tenant: key.Tenant, Name: key.Name}, nil
+				}
+			},
+			WithFillLimiter(limiter),
+			WithFillTimeout(20*time.Millisecond),
+			WithErrorLogger(func(err error) {}),
+		)
+
+		_, err := it.Get(newContext(), key1)()
+		assert.True(t, errors.Is(err, ErrFillTimeout))
+		assert.Equal(t, FillLimiterStats{
+			QueuedKeys:   1,
+			RunningFills: 1,
+		}, limiter.GetStats())
+
+		close(release)
+
+		assert.Eventually(t, func() bool {
+			return limiter.GetStats() == FillLimiterStats{}
+		}, time.Second, time.Millisecond)
+	})
+
+	t.Run("timed-out-while-waiting-last-fill--dequeued", func(t *testing.T) {
+		limiter := NewFillLimiter(1, 10)
+		release := make(chan struct{})
+
+		it := New[userValue, userKey](
+			fake.New().Pipeline(newContext()), unmarshalUser,
+			func(ctx context.Context, key userKey) func() (userValue, error) {
+				return func() (userValue, error) {
+					<-release
+					return userValue{Tenant: key.Tenant, Name: key.Name}, nil
+				}
+			},
+			WithFillLimiter(limiter),
+			WithFillTimeout(20*time.Millisecond),
+			WithErrorLogger(func(err error) {}),
+		)
+
+		_, err := it.Get(newContext(), key1)()
+		assert.True(t, errors.Is(err, ErrFillTimeout))
+
+		// the previous fill is still running
+		_, err = it.Get(newContext(), key2)()
+		assert.True(t, errors.Is(err, ErrFillTimeout))
+		assert.Equal(t, 1, limiter.QueueDepth())
+
+		close(release)
+
+		assert.Eventually(t, func() bool {
+			return limiter.QueueDepth() == 0
+		}, time.Second, time.Millisecond)
+		assert.Equal(t, FillLimiterStats{}, limiter.GetStats())
+	})
+
+	t.Run("invalid-params", func(t *testing.T) {
+		assert.PanicsWithValue(t, "item: max concurrent fills must be positive", func() {
+			NewFillLimiter(0, 10)
+		})
+		assert.PanicsWithValue(t, "item: max queued keys must be positive", func() {
+			NewFillLimiter(1, 0)
+		})
+	})
+}
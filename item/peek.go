@@ -0,0 +1,171 @@
+package item
+
+import (
+	"context"
+
+	"github.com/QuangTung97/go-memcache/memcache"
+
+	"github.com/QuangTung97/memproxy"
+)
+
+// Peek gets the cached value of the key without calling the filler or acquiring a lease.
+// The returned bool is false when the key is not found in the memcached server.
+// Peek does NOT use or update the in-memory cached values of Get
+func (i *Item[T, K]) Peek(_ context.Context, key K) func() (T, bool, error) {
+	keyStr := key.String()
+	leaseGetResult := i.common.pipeline.LeaseGet(keyStr, memproxy.LeaseGetOptions{NoLease: true})
+
+	var result T
+	var found bool
+	var err error
+
+	i.common.sess.AddNextCall(memproxy.NewEmptyCallback(func() {
+		resp, getErr := leaseGetResult.Result()
+		if getErr != nil {
+			i.common.stats.LeaseGetError++
+			err = i.common.logError(keyStr, StageLeaseGet, getErr)
+			return
+		}
+
+		if resp.Status != memproxy.LeaseGetStatusFound {
+			i.common.stats.PeekMissCount++
+			return
+		}
+
+		i.common.stats.PeekHitCount++
+		i.common.stats.TotalBytesRecv += uint64(len(resp.Data))
+
+		value, unmarshalErr := i.unmarshaler(resp.Data)
+		memcache.ReleaseGetResponseData(resp.Data)
+		if unmarshalErr != nil {
+			err = i.common.logError(keyStr, StageUnmarshal, unmarshalErr)
+			return
+		}
+		result = value
+		found = true
+	}))
+
+	return func() (T, bool, error) {
+		i.common.sess.Execute()
+		if err != nil {
+			var empty T
+			return empty, false, err
+		}
+		return result, found, nil
+	}
+}
+
+// Refresh always gets the value from the filler and sets it back to the memcached server,
+// often used in admin tools and cache warmers.
+// The lease is acquired before filling, so the value will not override a value set by a newer filling.
+// Because leases are only granted for missing keys, an existing value is deleted before acquiring the lease,
+// and is set back when the filling failed.
+// Refresh does NOT use or update the in-memory cached values of Get
+func (i *Item[T, K]) Refresh(ctx context.Context, key K) func() (T, error) {
+	keyStr := key.String()
+	leaseGetResult := i.common.pipeline.LeaseGet(keyStr, memproxy.LeaseGetOptions{})
+
+	var result T
+	var err error
+
+	doFill := func(cas uint64, oldData []byte) {
+		i.common.stats.RefreshCount++
+		fillFn := i.filler(ctx, key)
+
+		i.common.sess.AddNextCall(memproxy.NewEmptyCallback(func() {
+			result, err = i.handleRefreshFill(keyStr, cas, oldData, fillFn)
+		}))
+	}
+
+	i.common.sess.AddNextCall(memproxy.NewEmptyCallback(func() {
+		resp, getErr := leaseGetResult.Result()
+		if getErr != nil || resp.Status != memproxy.LeaseGetStatusFound {
+			doFill(i.getRefreshLease(keyStr, resp, getErr), nil)
+			return
+		}
+
+		oldData := append([]byte{}, resp.Data...)
+		memcache.ReleaseGetResponseData(resp.Data)
+
+		i.common.pipeline.Delete(keyStr, memproxy.DeleteOptions{})
+		newLeaseResult := i.common.pipeline.LeaseGet(keyStr, memproxy.LeaseGetOptions{})
+
+		i.common.sess.AddNextCall(memproxy.NewEmptyCallback(func() {
+			resp, getErr := newLeaseResult.Result()
+			doFill(i.getRefreshLease(keyStr, resp, getErr), oldData)
+		}))
+	}))
+
+	return func() (T, error) {
+		i.common.sess.Execute()
+		return result, err
+	}
+}
+
+// getRefreshLease returns the cas of the granted lease, or zero when the lease is not granted
+func (i *Item[T, K]) getRefreshLease(keyStr string, resp memproxy.LeaseGetResponse, err error) uint64 {
+	if err != nil {
+		i.common.stats.LeaseGetError++
+		_ = i.common.logError(keyStr, StageLeaseGet, err)
+		return 0
+	}
+
+	switch resp.Status {
+	case memproxy.LeaseGetStatusLeaseGranted:
+		return resp.CAS
+	case memproxy.LeaseGetStatusFound:
+		memcache.ReleaseGetResponseData(resp.Data)
+	default:
+	}
+	return 0
+}
+
+func (i *Item[T, K]) handleRefreshFill(
+	keyStr string, cas uint64, oldData []byte,
+	fillFn func() (T, error),
+) (T, error) {
+	fillResp, err := fillFn()
+
+	if err == ErrNotFound {
+		if cas > 0 {
+			i.common.pipeline.Delete(keyStr, memproxy.DeleteOptions{})
+		}
+		return fillResp, nil
+	}
+
+	if err != nil {
+		i.restoreRefreshValue(keyStr, cas, oldData)
+		var empty T
+		return empty, i.common.logError(keyStr, StageFill, err)
+	}
+
+	data, err := fillResp.Marshal()
+	if err != nil {
+		i.restoreRefreshValue(keyStr, cas, oldData)
+		var empty T
+		return empty, i.common.logError(keyStr, StageMarshal, err)
+	}
+
+	if cas > 0 {
+		i.common.doLeaseSet(keyStr, data, cas, 0)
+	}
+	return fillResp, nil
+}
+
+// restoreRefreshValue sets back the value deleted by Refresh, or releases the lease if there was no value
+func (i *Item[T, K]) restoreRefreshValue(keyStr string, cas uint64, oldData []byte) {
+	if cas == 0 {
+		return
+	}
+	if oldData == nil {
+		i.common.pipeline.Delete(keyStr, memproxy.DeleteOptions{})
+		return
+	}
+	i.common.doLeaseSet(keyStr, oldData, cas, 0)
+}
+
+func (i *itemCommon) logError(keyStr string, stage Stage, err error) error {
+	wrapped := newError(keyStr, stage, 0, err)
+	i.options.errorLogger(wrapped)
+	return wrapped
+}
@@ -0,0 +1,244 @@
+package item
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/QuangTung97/memproxy"
+	"github.com/QuangTung97/memproxy/fake"
+)
+
+type peekTest struct {
+	pipe memproxy.Pipeline
+	it   *Item[userValue, userKey]
+
+	users     map[userKey]userValue
+	fillCalls int
+	fillKeys  [][]userKey
+	fillErr   error
+}
+
+func newPeekTest() *peekTest {
+	mc := fake.New()
+
+	p := &peekTest{
+		pipe:  mc.Pipeline(newContext()),
+		users: map[userKey]userValue{},
+	}
+
+	filler := NewMultiGetFiller[userValue, userKey](
+		func(ctx context.Context, keys []userKey) ([]userValue, error) {
+			p.fillCalls++
+			p.fillKeys = append(p.fillKeys, keys)
+			if p.fillErr != nil {
+				return nil, p.fillErr
+			}
+
+			var result []userValue
+			for _, k := range keys {
+				u, ok := p.users[k]
+				if ok {
+					result = append(result, u)
+				}
+			}
+			return result, nil
+		},
+		userValue.GetKey,
+		WithMultiGetEnableDeleteOnNotFound(true),
+	)
+
+	p.it = New[userValue, userKey](p.pipe, unmarshalUser, filler)
+	return p
+}
+
+func (p *peekTest) addUser(u userValue) {
+	p.users[u.GetKey()] = u
+}
+
+func TestItem_Peek(t *testing.T) {
+	key1 := userKey{Tenant: "TENANT01", Name: "user01"}
+	key2 := userKey{Tenant: "TENANT01", Name: "user02"}
+
+	user1 := userValue{Tenant: "TENANT01", Name: "user01", Age: 21}
+	user2 := userValue{Tenant: "TENANT01", Name: "user02", Age: 22}
+
+	t.Run("not-found--not-call-filler", func(t *testing.T) {
+		p := newPeekTest()
+		p.addUser(user1)
+
+		resp, found, err := p.it.Peek(newContext(), key1)()
+		assert.Equal(t, nil, err)
+		assert.Equal(t, false, found)
+		assert.Equal(t, userValue{}, resp)
+
+		assert.Equal(t, 0, p.fillCalls)
+		assert.Equal(t, uint64(1), p.it.GetStats().PeekMissCount)
+
+		// lease is not acquired by peek
+		resp, err = p.it.Get(newContext(), key1)()
+		assert.Equal(t, nil, err)
+		assert.Equal(t, user1, resp)
+		assert.Equal(t, 1, p.fillCalls)
+	})
+
+	t.Run("found-after-get", func(t *testing.T) {
+		p := newPeekTest()
+		p.addUser(user1)
+
+		_, err := p.it.Get(newContext(), key1)()
+		assert.Equal(t, nil, err)
+
+		resp, found, err := p.it.Peek(newContext(), key1)()
+		assert.Equal(t, nil, err)
+		assert.Equal(t, true, found)
+		assert.Equal(t, user1, resp)
+
+		assert.Equal(t, 1, p.fillCalls)
+		assert.Equal(t, uint64(1), p.it.GetStats().PeekHitCount)
+	})
+
+	t.Run("multiple-keys", func(t *testing.T) {
+		p := newPeekTest()
+		p.addUser(user1)
+		p.addUser(user2)
+
+		_, err := p.it.Get(newContext(), key2)()
+		assert.Equal(t, nil, err)
+
+		fn1 := p.it.Peek(newContext(), key1)
+		fn2 := p.it.Peek(newContext(), key2)
+
+		_, found, err := fn1()
+		assert.Equal(t, nil, err)
+		assert.Equal(t, false, found)
+
+		resp, found, err := fn2()
+		assert.Equal(t, nil, err)
+		assert.Equal(t, true, found)
+		assert.Equal(t, user2, resp)
+
+		stats := p.it.GetStats()
+		assert.Equal(t, uint64(1), stats.PeekHitCount)
+		assert.Equal(t, uint64(1), stats.PeekMissCount)
+	})
+}
+
+func TestItem_Refresh(t *testing.T) {
+	key1 := userKey{Tenant: "TENANT01", Name: "user01"}
+	key2 := userKey{Tenant: "TENANT01", Name: "user02"}
+
+	user1 := userValue{Tenant: "TENANT01", Name: "user01", Age: 21}
+	user2 := userValue{Tenant: "TENANT01", Name: "user02", Age: 22}
+
+	t.Run("not-in-cache--fill-and-set", func(t *testing.T) {
+		p := newPeekTest()
+		p.addUser(user1)
+
+		resp, err := p.it.Refresh(newContext(), key1)()
+		assert.Equal(t, nil, err)
+		assert.Equal(t, user1, resp)
+		assert.Equal(t, 1, p.fillCalls)
+
+		resp, found, err := p.it.Peek(newContext(), key1)()
+		assert.Equal(t, nil, err)
+		assert.Equal(t, true, found)
+		assert.Equal(t, user1, resp)
+
+		assert.Equal(t, uint64(1), p.it.GetStats().RefreshCount)
+	})
+
+	t.Run("override-existing-value", func(t *testing.T) {
+		p := newPeekTest()
+		p.addUser(user1)
+
+		_, err := p.it.Get(newContext(), key1)()
+		assert.Equal(t, nil, err)
+
+		newUser := user1
+		newUser.Age = 31
+		p.addUser(newUser)
+
+		resp, err := p.it.Refresh(newContext(), key1)()
+		assert.Equal(t, nil, err)
+		assert.Equal(t, newUser, resp)
+		assert.Equal(t, 2, p.fillCalls)
+
+		resp, found, err := p.it.Peek(newContext(), key1)()
+		assert.Equal(t, nil, err)
+		assert.Equal(t, true, found)
+		assert.Equal(t, newUser, resp)
+	})
+
+	t.Run("multiple-keys--batching-fills", func(t *testing.T) {
+		p := newPeekTest()
+		p.addUser(user1)
+		p.addUser(user2)
+
+		fn1 := p.it.Refresh(newContext(), key1)
+		fn2 := p.it.Refresh(newContext(), key2)
+
+		resp, err := fn1()
+		assert.Equal(t, nil, err)
+		assert.Equal(t, user1, resp)
+
+		resp, err = fn2()
+		assert.Equal(t, nil, err)
+		assert.Equal(t, user2, resp)
+
+		assert.Equal(t, [][]userKey{{key1, key2}}, p.fillKeys)
+	})
+
+	t.Run("fill-error--keep-existing-value", func(t *testing.T) {
+		p := newPeekTest()
+		p.addUser(user1)
+
+		_, err := p.it.Get(newContext(), key1)()
+		assert.Equal(t, nil, err)
+
+		p.fillErr = errors.New("fill error")
+
+		resp, err := p.it.Refresh(newContext(), key1)()
+		assert.Equal(t, newError(key1.String(), StageFill, 0, p.fillErr), err)
+		assert.Equal(t, userValue{}, resp)
+
+		resp, found, err := p.it.Peek(newContext(), key1)()
+		assert.Equal(t, nil, err)
+		assert.Equal(t, true, found)
+		assert.Equal(t, user1, resp)
+	})
+
+	t.Run("fill-error--release-lease", func(t *testing.T) {
+		p := newPeekTest()
+		p.addUser(user1)
+		p.fillErr = errors.New("fill error")
+
+		_, err := p.it.Refresh(newContext(), key1)()
+		assert.Equal(t, newError(key1.String(), StageFill, 0, p.fillErr), err)
+
+		p.fillErr = nil
+		resp, err := p.it.Get(newContext(), key1)()
+		assert.Equal(t, nil, err)
+		assert.Equal(t, user1, resp)
+	})
+
+	t.Run("fill-not-found--delete-key", func(t *testing.T) {
+		p := newPeekTest()
+
+		resp, err := p.it.Refresh(newContext(), key1)()
+		assert.Equal(t, nil, err)
+		assert.Equal(t, userValue{}, resp)
+
+		_, found, err := p.it.Peek(newContext(), key1)()
+		assert.Equal(t, nil, err)
+		assert.Equal(t, false, found)
+
+		// lease is released
+		p.addUser(user1)
+		resp, err = p.it.Get(newContext(), key1)()
+		assert.Equal(t, nil, err)
+		assert.Equal(t, user1, resp)
+	})
+}
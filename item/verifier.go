@@ -0,0 +1,315 @@
+package item
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+
+	"github.com/QuangTung97/memproxy"
+)
+
+// VerifyMismatch is a cached value different from the value of the filler
+type VerifyMismatch struct {
+	Key        string
+	CachedData []byte
+	SourceData []byte // nil when the filler returned ErrNotFound
+}
+
+type verifierConfig struct {
+	sampleRate float64
+	randFunc   func() float64
+	queueSize  int
+	batchSize  int
+
+	mismatchCallback func(m VerifyMismatch)
+	errorLogger      func(err error)
+
+	deleteClient memproxy.Memcache
+}
+
+// VerifierOption ...
+type VerifierOption func(conf *verifierConfig)
+
+// WithVerifierSampleRate configures the fraction of cache hits to be verified, default 0.01 (1%)
+func WithVerifierSampleRate(rate float64) VerifierOption {
+	return func(conf *verifierConfig) {
+		conf.sampleRate = rate
+	}
+}
+
+// WithVerifierRandFunc configures the random function returning values in [0.0, 1.0), MUST be thread safe
+func WithVerifierRandFunc(randFunc func() float64) VerifierOption {
+	return func(conf *verifierConfig) {
+		conf.randFunc = randFunc
+	}
+}
+
+// WithVerifierQueueSize configures the maximum number of sampled hits waiting for verifying,
+// sampled hits are dropped after the queue is full, default 1024
+func WithVerifierQueueSize(size int) VerifierOption {
+	return func(conf *verifierConfig) {
+		conf.queueSize = size
+	}
+}
+
+// WithVerifierMismatchCallback configures the callback for reporting mismatches, MUST be thread safe,
+// default is logging the mismatches
+func WithVerifierMismatchCallback(callback func(m VerifyMismatch)) VerifierOption {
+	return func(conf *verifierConfig) {
+		conf.mismatchCallback = callback
+	}
+}
+
+// WithVerifierErrorLogger configures the error logger for the errors of the filler and deleting keys
+func WithVerifierErrorLogger(logger func(err error)) VerifierOption {
+	return func(conf *verifierConfig) {
+		conf.errorLogger = logger
+	}
+}
+
+// WithVerifierAutoDelete enables deleting the mismatched keys using the client
+func WithVerifierAutoDelete(client memproxy.Memcache) VerifierOption {
+	return func(conf *verifierConfig) {
+		conf.deleteClient = client
+	}
+}
+
+func defaultMismatchCallback(m VerifyMismatch) {
+	log.Printf("[ERROR] item: verify mismatch, key: %s, cached: %q, source: %q\n", m.Key, m.CachedData, m.SourceData)
+}
+
+func defaultVerifierErrorLogger(err error) {
+	log.Println("[ERROR] item: verify error:", err)
+}
+
+func computeVerifierConfig(options []VerifierOption) *verifierConfig {
+	conf := &verifierConfig{
+		sampleRate: 0.01,
+		randFunc:   rand.Float64,
+		queueSize:  1024,
+		batchSize:  100,
+
+		mismatchCallback: defaultMismatchCallback,
+		errorLogger:      defaultVerifierErrorLogger,
+	}
+	for _, fn := range options {
+		fn(conf)
+	}
+	return conf
+}
+
+type verifyJob[K Key] struct {
+	key  K
+	data []byte
+}
+
+// Verifier compares a sample of cache hits with the values from the filler in a background goroutine,
+// for detecting bugs in invalidating keys. Used with Item.SetVerifier.
+// Verifier is thread safe, and can be shared between items of the same types
+type Verifier[T Value, K Key] struct {
+	conf   *verifierConfig
+	filler Filler[T, K]
+
+	// protecting jobs from being sent after closed
+	mut    sync.RWMutex
+	closed bool
+
+	jobs chan verifyJob[K]
+	wg   sync.WaitGroup
+
+	stats verifierStats
+}
+
+type verifierStats struct {
+	sampled  atomic.Uint64
+	dropped  atomic.Uint64
+	verified atomic.Uint64
+	mismatch atomic.Uint64
+	errors   atomic.Uint64
+}
+
+// NewVerifier creates a Verifier and starts its background goroutine.
+// Param: filler is only called in the background goroutine,
+// and MUST NOT be the same filler object used by items (e.g. create another one using NewMultiGetFiller),
+// because fillers are not required to be thread safe.
+// Mismatches can happen when a value is being updated, before the key is invalidated
+func NewVerifier[T Value, K Key](filler Filler[T, K], options ...VerifierOption) *Verifier[T, K] {
+	conf := computeVerifierConfig(options)
+
+	v := &Verifier[T, K]{
+		conf:   conf,
+		filler: filler,
+		jobs:   make(chan verifyJob[K], conf.queueSize),
+	}
+
+	v.wg.Add(1)
+	go func() {
+		defer v.wg.Done()
+		v.run()
+	}()
+
+	return v
+}
+
+func (v *Verifier[T, K]) sampleHit(key K, data []byte) {
+	if v.conf.randFunc() >= v.conf.sampleRate {
+		return
+	}
+
+	job := verifyJob[K]{
+		key:  key,
+		data: append([]byte(nil), data...),
+	}
+
+	v.mut.RLock()
+	defer v.mut.RUnlock()
+
+	if v.closed {
+		v.stats.dropped.Add(1)
+		return
+	}
+
+	select {
+	case v.jobs <- job:
+		v.stats.sampled.Add(1)
+	default:
+		v.stats.dropped.Add(1)
+	}
+}
+
+func (v *Verifier[T, K]) run() {
+	for {
+		job, ok := <-v.jobs
+		if !ok {
+			return
+		}
+
+		jobs := []verifyJob[K]{job}
+		closed := false
+
+	DrainLoop:
+		for len(jobs) < v.conf.batchSize {
+			select {
+			case job, ok := <-v.jobs:
+				if !ok {
+					closed = true
+					break DrainLoop
+				}
+				jobs = append(jobs, job)
+			default:
+				break DrainLoop
+			}
+		}
+
+		v.verifyJobs(jobs)
+
+		if closed {
+			return
+		}
+	}
+}
+
+func (v *Verifier[T, K]) verifyJobs(jobs []verifyJob[K]) {
+	ctx := context.Background()
+
+	fnList := make([]func() (T, error), 0, len(jobs))
+	for _, job := range jobs {
+		fnList = append(fnList, v.filler(ctx, job.key))
+	}
+
+	var mismatchKeys []string
+	for index, fn := range fnList {
+		job := jobs[index]
+
+		sourceData, err := v.getSourceData(fn)
+		if err != nil {
+			v.stats.errors.Add(1)
+			v.conf.errorLogger(err)
+			continue
+		}
+
+		v.stats.verified.Add(1)
+
+		if sourceData != nil && bytes.Equal(sourceData, job.data) {
+			continue
+		}
+
+		v.stats.mismatch.Add(1)
+		keyStr := job.key.String()
+
+		v.conf.mismatchCallback(VerifyMismatch{
+			Key:        keyStr,
+			CachedData: job.data,
+			SourceData: sourceData,
+		})
+		mismatchKeys = append(mismatchKeys, keyStr)
+	}
+
+	if v.conf.deleteClient != nil && len(mismatchKeys) > 0 {
+		v.deleteKeys(ctx, mismatchKeys)
+	}
+}
+
+func (*Verifier[T, K]) getSourceData(fn func() (T, error)) ([]byte, error) {
+	value, err := fn()
+	if err == ErrNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return value.Marshal()
+}
+
+func (v *Verifier[T, K]) deleteKeys(ctx context.Context, keys []string) {
+	pipe := v.conf.deleteClient.Pipeline(ctx)
+	defer pipe.Finish()
+
+	fnList := make([]func() (memproxy.DeleteResponse, error), 0, len(keys))
+	for _, key := range keys {
+		fnList = append(fnList, pipe.Delete(key, memproxy.DeleteOptions{}))
+	}
+
+	for _, fn := range fnList {
+		_, err := fn()
+		if err != nil {
+			v.conf.errorLogger(err)
+		}
+	}
+}
+
+// Shutdown stops the background goroutine, after verified all the sampled hits.
+// The cache hits sampled after Shutdown are dropped
+func (v *Verifier[T, K]) Shutdown() {
+	v.mut.Lock()
+	if !v.closed {
+		v.closed = true
+		close(v.jobs)
+	}
+	v.mut.Unlock()
+
+	v.wg.Wait()
+}
+
+// VerifierStats ...
+type VerifierStats struct {
+	SampledCount  uint64 // number of cache hits sampled for verifying
+	DroppedCount  uint64 // number of sampled cache hits dropped because the queue is full or shut down
+	VerifiedCount uint64 // number of cache hits compared with the values from the filler
+	MismatchCount uint64 // number of cache hits different from the values from the filler
+	ErrorCount    uint64 // number of errors when calling the filler
+}
+
+// GetVerifierStats ...
+func (v *Verifier[T, K]) GetVerifierStats() VerifierStats {
+	return VerifierStats{
+		SampledCount:  v.stats.sampled.Load(),
+		DroppedCount:  v.stats.dropped.Load(),
+		VerifiedCount: v.stats.verified.Load(),
+		MismatchCount: v.stats.mismatch.Load(),
+		ErrorCount:    v.stats.errors.Load(),
+	}
+}
@@ -0,0 +1,273 @@
+package item
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/QuangTung97/memproxy"
+	"github.com/QuangTung97/memproxy/fake"
+)
+
+type verifierTest struct {
+	mc   *fake.Memcache
+	pipe memproxy.Pipeline
+
+	mut        sync.Mutex
+	users      map[userKey]userValue
+	fillErr    error
+	mismatches []VerifyMismatch
+	errors     []error
+}
+
+func newVerifierTest() *verifierTest {
+	v := &verifierTest{
+		mc:    fake.New(),
+		users: map[userKey]userValue{},
+	}
+	v.pipe = v.mc.Pipeline(newContext())
+	return v
+}
+
+func (v *verifierTest) setUser(u userValue) {
+	v.mut.Lock()
+	v.users[u.GetKey()] = u
+	v.mut.Unlock()
+}
+
+func (v *verifierTest) newFiller() Filler[userValue, userKey] {
+	return NewMultiGetFiller[userValue, userKey](
+		func(ctx context.Context, keys []userKey) ([]userValue, error) {
+			v.mut.Lock()
+			defer v.mut.Unlock()
+
+			if v.fillErr != nil {
+				return nil, v.fillErr
+			}
+
+			var result []userValue
+			for _, k := range keys {
+				u, ok := v.users[k]
+				if ok {
+					result = append(result, u)
+				}
+			}
+			return result, nil
+		},
+		userValue.GetKey,
+		WithMultiGetEnableDeleteOnNotFound(true),
+	)
+}
+
+func (v *verifierTest) newVerifier(options ...VerifierOption) *Verifier[userValue, userKey] {
+	options = append([]VerifierOption{
+		WithVerifierSampleRate(1),
+		WithVerifierMismatchCallback(func(m VerifyMismatch) {
+			v.mut.Lock()
+			v.mismatches = append(v.mismatches, m)
+			v.mut.Unlock()
+		}),
+		WithVerifierErrorLogger(func(err error) {
+			v.mut.Lock()
+			v.errors = append(v.errors, err)
+			v.mut.Unlock()
+		}),
+	}, options...)
+	return NewVerifier[userValue, userKey](v.newFiller(), options...)
+}
+
+func (v *verifierTest) newItem(verifier *Verifier[userValue, userKey]) *Item[userValue, userKey] {
+	it := New[userValue, userKey](v.pipe, unmarshalUser, v.newFiller())
+	it.SetVerifier(verifier)
+	return it
+}
+
+func TestVerifier(t *testing.T) {
+	user1 := userValue{Tenant: "TENANT01", Name: "user01", Age: 21}
+	key1 := user1.GetKey()
+
+	t.Run("matched", func(t *testing.T) {
+		v := newVerifierTest()
+		v.setUser(user1)
+
+		verifier := v.newVerifier()
+
+		// fill, not a hit
+		_, err := v.newItem(verifier).Get(newContext(), key1)()
+		assert.Equal(t, nil, err)
+
+		_, err = v.newItem(verifier).Get(newContext(), key1)()
+		assert.Equal(t, nil, err)
+
+		verifier.Shutdown()
+
+		assert.Equal(t, VerifierStats{
+			SampledCount:  1,
+			VerifiedCount: 1,
+		}, verifier.GetVerifierStats())
+		assert.Equal(t, 0, len(v.mismatches))
+	})
+
+	t.Run("mismatched--not-delete", func(t *testing.T) {
+		v := newVerifierTest()
+		v.setUser(user1)
+
+		verifier := v.newVerifier()
+
+		_, err := v.newItem(verifier).Get(newContext(), key1)()
+		assert.Equal(t, nil, err)
+
+		newUser := user1
+		newUser.Age = 31
+		v.setUser(newUser)
+
+		value, err := v.newItem(verifier).Get(newContext(), key1)()
+		assert.Equal(t, nil, err)
+		assert.Equal(t, user1, value)
+
+		verifier.Shutdown()
+
+		assert.Equal(t, VerifierStats{
+			SampledCount:  1,
+			VerifiedCount: 1,
+			MismatchCount: 1,
+		}, verifier.GetVerifierStats())
+		assert.Equal(t, []VerifyMismatch{
+			{
+				Key:        "TENANT01:user01",
+				CachedData: []byte(`{"tenant":"TENANT01","name":"user01","age":21}`),
+				SourceData: []byte(`{"tenant":"TENANT01","name":"user01","age":31}`),
+			},
+		}, v.mismatches)
+
+		value, err = v.newItem(nil).Get(newContext(), key1)()
+		assert.Equal(t, nil, err)
+		assert.Equal(t, user1, value)
+	})
+
+	t.Run("mismatched--auto-delete", func(t *testing.T) {
+		v := newVerifierTest()
+		v.setUser(user1)
+
+		verifier := v.newVerifier(WithVerifierAutoDelete(v.mc))
+
+		_, err := v.newItem(verifier).Get(newContext(), key1)()
+		assert.Equal(t, nil, err)
+
+		newUser := user1
+		newUser.Age = 31
+		v.setUser(newUser)
+
+		_, err = v.newItem(verifier).Get(newContext(), key1)()
+		assert.Equal(t, nil, err)
+
+		verifier.Shutdown()
+
+		value, err := v.newItem(nil).Get(newContext(), key1)()
+		assert.Equal(t, nil, err)
+		assert.Equal(t, newUser, value)
+	})
+
+	t.Run("source-not-found", func(t *testing.T) {
+		v := newVerifierTest()
+		v.setUser(user1)
+
+		verifier := v.newVerifier()
+
+		_, err := v.newItem(verifier).Get(newContext(), key1)()
+		assert.Equal(t, nil, err)
+
+		v.mut.Lock()
+		delete(v.users, key1)
+		v.mut.Unlock()
+
+		_, err = v.newItem(verifier).Get(newContext(), key1)()
+		assert.Equal(t, nil, err)
+
+		verifier.Shutdown()
+
+		assert.Equal(t, []VerifyMismatch{
+			{
+				Key:        "TENANT01:user01",
+				CachedData: []byte(`{"tenant":"TENANT01","name":"user01","age":21}`),
+			},
+		}, v.mismatches)
+	})
+
+	t.Run("filler-error", func(t *testing.T) {
+		v := newVerifierTest()
+		v.setUser(user1)
+
+		verifier := v.newVerifier()
+
+		_, err := v.newItem(verifier).Get(newContext(), key1)()
+		assert.Equal(t, nil, err)
+
+		fillErr := errors.New("fill error")
+		v.mut.Lock()
+		v.fillErr = fillErr
+		v.mut.Unlock()
+
+		_, err = v.newItem(verifier).Get(newContext(), key1)()
+		assert.Equal(t, nil, err)
+
+		verifier.Shutdown()
+
+		assert.Equal(t, VerifierStats{
+			SampledCount: 1,
+			ErrorCount:   1,
+		}, verifier.GetVerifierStats())
+		assert.Equal(t, []error{fillErr}, v.errors)
+	})
+
+	t.Run("not-sampled", func(t *testing.T) {
+		v := newVerifierTest()
+		v.setUser(user1)
+
+		verifier := v.newVerifier(
+			WithVerifierSampleRate(0.3),
+			WithVerifierRandFunc(func() float64 { return 0.3 }),
+		)
+
+		_, err := v.newItem(verifier).Get(newContext(), key1)()
+		assert.Equal(t, nil, err)
+
+		_, err = v.newItem(verifier).Get(newContext(), key1)()
+		assert.Equal(t, nil, err)
+
+		verifier.Shutdown()
+
+		assert.Equal(t, VerifierStats{}, verifier.GetVerifierStats())
+	})
+
+	t.Run("sampled-after-shutdown--dropped", func(t *testing.T) {
+		v := newVerifierTest()
+		v.setUser(user1)
+
+		verifier := v.newVerifier()
+
+		_, err := v.newItem(verifier).Get(newContext(), key1)()
+		assert.Equal(t, nil, err)
+
+		var wg sync.WaitGroup
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			verifier.Shutdown()
+		}()
+
+		for n := 0; n < 10; n++ {
+			_, err = v.newItem(verifier).Get(newContext(), key1)()
+			assert.Equal(t, nil, err)
+		}
+		wg.Wait()
+		verifier.Shutdown()
+
+		stats := verifier.GetVerifierStats()
+		assert.Equal(t, uint64(10), stats.SampledCount+stats.DroppedCount)
+		assert.Equal(t, stats.SampledCount, stats.VerifiedCount)
+	})
+}
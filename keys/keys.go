@@ -0,0 +1,205 @@
+package keys
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Separator is the separator between the segments of keys
+const Separator = ":"
+
+// ErrInvalidKey returned when parsing keys not matched with the Schema
+var ErrInvalidKey = errors.New("keys: invalid key")
+
+// Schema is the common part of the keys of the same kind: the prefix, the schema version
+// and the config for using the keys as mmap.RootKey.
+// Schema is comparable and can be used as a field of keys
+type Schema struct {
+	prefix           string
+	version          uint32
+	avgBucketSizeLog uint8
+}
+
+// Option ...
+type Option func(s *Schema)
+
+// WithVersion adds the version segment (e.g. users:v2:123) to the keys,
+// increasing the version is an easy way to invalidate all the keys when the value format changed,
+// default zero (no version segment)
+func WithVersion(version uint32) Option {
+	return func(s *Schema) {
+		s.version = version
+	}
+}
+
+// WithAvgBucketSizeLog configures the value returned from the method AvgBucketSizeLog of the keys,
+// for using the keys as mmap.RootKey, values should be between [0, 8], default zero
+func WithAvgBucketSizeLog(sizeLog uint8) Option {
+	return func(s *Schema) {
+		s.avgBucketSizeLog = sizeLog
+	}
+}
+
+// NewSchema creates a Schema, prefix MUST NOT be empty or contain the Separator
+func NewSchema(prefix string, options ...Option) Schema {
+	if prefix == "" || strings.Contains(prefix, Separator) {
+		panic("keys: invalid schema prefix")
+	}
+
+	s := Schema{
+		prefix: prefix,
+	}
+	for _, fn := range options {
+		fn(&s)
+	}
+	return s
+}
+
+// Prefix ...
+func (s Schema) Prefix() string {
+	return s.prefix
+}
+
+// Version ...
+func (s Schema) Version() uint32 {
+	return s.version
+}
+
+func (s Schema) head() string {
+	if s.version == 0 {
+		return s.prefix + Separator
+	}
+	return s.prefix + Separator + "v" + strconv.FormatUint(uint64(s.version), 10) + Separator
+}
+
+func (s Schema) buildKey(parts ...string) string {
+	var buf strings.Builder
+	_, _ = buf.WriteString(s.head())
+	for i, p := range parts {
+		if i > 0 {
+			_, _ = buf.WriteString(Separator)
+		}
+		_, _ = buf.WriteString(p)
+	}
+	return buf.String()
+}
+
+// splitKey returns the segments after the prefix and version
+func (s Schema) splitKey(key string, numParts int) ([]string, error) {
+	head := s.head()
+	if !strings.HasPrefix(key, head) {
+		return nil, fmt.Errorf("%w: %q not matched with prefix %q", ErrInvalidKey, key, head)
+	}
+
+	parts := strings.Split(key[len(head):], Separator)
+	if len(parts) != numParts {
+		return nil, fmt.Errorf("%w: %q expected %d segments after prefix", ErrInvalidKey, key, numParts)
+	}
+	return parts, nil
+}
+
+// Part is the constraint of the segments of composite keys
+type Part interface {
+	string | int | int64 | uint64
+}
+
+func formatPart[P Part](p P) string {
+	switch v := any(p).(type) {
+	case string:
+		return escape(v)
+	case int:
+		return strconv.Itoa(v)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	default:
+		return strconv.FormatUint(any(p).(uint64), 10)
+	}
+}
+
+func parsePart[P Part](s string) (P, error) {
+	var result P
+	var err error
+
+	switch ptr := any(&result).(type) {
+	case *string:
+		*ptr, err = unescape(s)
+	case *int:
+		*ptr, err = strconv.Atoi(s)
+	case *int64:
+		*ptr, err = strconv.ParseInt(s, 10, 64)
+	case *uint64:
+		*ptr, err = strconv.ParseUint(s, 10, 64)
+	}
+
+	// rejects the segments not returned from formatPart (e.g. "+5", "007" or "%41"), so a key has only one string
+	if err == nil && formatPart(result) != s {
+		err = errors.New("not in canonical form")
+	}
+
+	if err != nil {
+		var empty P
+		return empty, fmt.Errorf("%w: invalid segment %q: %v", ErrInvalidKey, s, err)
+	}
+	return result, nil
+}
+
+// escape replaces the characters not allowed in memcached keys, the Separator and '%' with %XX
+func escape(s string) string {
+	needEscape := false
+	for i := 0; i < len(s); i++ {
+		if shouldEscape(s[i]) {
+			needEscape = true
+			break
+		}
+	}
+	if !needEscape {
+		return s
+	}
+
+	const hexChars = "0123456789ABCDEF"
+
+	var buf strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if shouldEscape(c) {
+			_ = buf.WriteByte('%')
+			_ = buf.WriteByte(hexChars[c>>4])
+			_ = buf.WriteByte(hexChars[c&0xf])
+			continue
+		}
+		_ = buf.WriteByte(c)
+	}
+	return buf.String()
+}
+
+func shouldEscape(c byte) bool {
+	return c <= ' ' || c == 0x7f || c == '%' || c == Separator[0]
+}
+
+func unescape(s string) (string, error) {
+	if !strings.Contains(s, "%") {
+		return s, nil
+	}
+
+	var buf strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c != '%' {
+			_ = buf.WriteByte(c)
+			continue
+		}
+
+		if i+2 >= len(s) {
+			return "", errors.New("incomplete escape sequence")
+		}
+		v, err := strconv.ParseUint(s[i+1:i+3], 16, 8)
+		if err != nil {
+			return "", err
+		}
+		_ = buf.WriteByte(byte(v))
+		i += 2
+	}
+	return buf.String(), nil
+}
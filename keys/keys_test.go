@@ -0,0 +1,164 @@
+package keys
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/QuangTung97/memproxy/mmap"
+)
+
+func assertRootKey[K mmap.RootKey](K) {
+}
+
+func TestKeys_Implement_RootKey(*testing.T) {
+	assertRootKey(IntKey{})
+	assertRootKey(StringKey{})
+	assertRootKey(CompositeKey2[string, int64]{})
+	assertRootKey(CompositeKey3[string, string, uint64]{})
+}
+
+func TestIntKey(t *testing.T) {
+	t.Run("normal", func(t *testing.T) {
+		s := NewSchema("users")
+		k := NewIntKey(s, 123)
+		assert.Equal(t, "users:123", k.String())
+		assert.Equal(t, uint8(0), k.AvgBucketSizeLog())
+
+		parsed, err := ParseIntKey(s, k.String())
+		assert.Equal(t, nil, err)
+		assert.Equal(t, k, parsed)
+	})
+
+	t.Run("with version and bucket size log", func(t *testing.T) {
+		s := NewSchema("users", WithVersion(2), WithAvgBucketSizeLog(3))
+		k := NewIntKey(s, -5)
+		assert.Equal(t, "users:v2:-5", k.String())
+		assert.Equal(t, uint8(3), k.AvgBucketSizeLog())
+
+		parsed, err := ParseIntKey(s, k.String())
+		assert.Equal(t, nil, err)
+		assert.Equal(t, k, parsed)
+	})
+
+	t.Run("parse with different version", func(t *testing.T) {
+		s := NewSchema("users", WithVersion(2))
+		_, err := ParseIntKey(s, "users:v1:123")
+		assert.True(t, errors.Is(err, ErrInvalidKey))
+
+		_, err = ParseIntKey(s, "users:123")
+		assert.True(t, errors.Is(err, ErrInvalidKey))
+	})
+
+	t.Run("parse invalid", func(t *testing.T) {
+		s := NewSchema("users")
+
+		_, err := ParseIntKey(s, "products:123")
+		assert.True(t, errors.Is(err, ErrInvalidKey))
+
+		_, err = ParseIntKey(s, "users:abc")
+		assert.True(t, errors.Is(err, ErrInvalidKey))
+
+		_, err = ParseIntKey(s, "users:1:2")
+		assert.True(t, errors.Is(err, ErrInvalidKey))
+	})
+
+	t.Run("parse not canonical", func(t *testing.T) {
+		s := NewSchema("users")
+
+		_, err := ParseIntKey(s, "users:+5")
+		assert.True(t, errors.Is(err, ErrInvalidKey))
+
+		_, err = ParseIntKey(s, "users:007")
+		assert.True(t, errors.Is(err, ErrInvalidKey))
+
+		_, err = ParseIntKey(s, "users:-0")
+		assert.True(t, errors.Is(err, ErrInvalidKey))
+
+		_, err = ParseCompositeKey2[int, uint64](s, "users:1:+2")
+		assert.True(t, errors.Is(err, ErrInvalidKey))
+
+		k, err := ParseIntKey(s, "users:-5")
+		assert.Equal(t, nil, err)
+		assert.Equal(t, NewIntKey(s, -5), k)
+	})
+}
+
+func TestStringKey(t *testing.T) {
+	t.Run("normal", func(t *testing.T) {
+		s := NewSchema("users")
+		k := NewStringKey(s, "john")
+		assert.Equal(t, "users:john", k.String())
+
+		parsed, err := ParseStringKey(s, k.String())
+		assert.Equal(t, nil, err)
+		assert.Equal(t, k, parsed)
+	})
+
+	t.Run("escape special characters", func(t *testing.T) {
+		s := NewSchema("users")
+		k := NewStringKey(s, "a:b c%d\n")
+		assert.Equal(t, "users:a%3Ab%20c%25d%0A", k.String())
+
+		parsed, err := ParseStringKey(s, k.String())
+		assert.Equal(t, nil, err)
+		assert.Equal(t, k, parsed)
+	})
+
+	t.Run("parse invalid escape", func(t *testing.T) {
+		s := NewSchema("users")
+
+		_, err := ParseStringKey(s, "users:abc%2")
+		assert.True(t, errors.Is(err, ErrInvalidKey))
+
+		_, err = ParseStringKey(s, "users:abc%ZZ")
+		assert.True(t, errors.Is(err, ErrInvalidKey))
+
+		// not escaped by String
+		_, err = ParseStringKey(s, "users:abc%41")
+		assert.True(t, errors.Is(err, ErrInvalidKey))
+	})
+}
+
+func TestCompositeKey(t *testing.T) {
+	t.Run("two segments", func(t *testing.T) {
+		s := NewSchema("orders", WithVersion(1), WithAvgBucketSizeLog(2))
+		k := NewCompositeKey2(s, "TENANT01", int64(123))
+		assert.Equal(t, "orders:v1:TENANT01:123", k.String())
+		assert.Equal(t, uint8(2), k.AvgBucketSizeLog())
+
+		parsed, err := ParseCompositeKey2[string, int64](s, k.String())
+		assert.Equal(t, nil, err)
+		assert.Equal(t, k, parsed)
+	})
+
+	t.Run("three segments", func(t *testing.T) {
+		s := NewSchema("stocks")
+		k := NewCompositeKey3(s, "TENANT:01", 7, uint64(99))
+		assert.Equal(t, "stocks:TENANT%3A01:7:99", k.String())
+
+		parsed, err := ParseCompositeKey3[string, int, uint64](s, k.String())
+		assert.Equal(t, nil, err)
+		assert.Equal(t, k, parsed)
+	})
+
+	t.Run("parse wrong number of segments", func(t *testing.T) {
+		s := NewSchema("stocks")
+
+		_, err := ParseCompositeKey3[string, int, uint64](s, "stocks:A:1")
+		assert.True(t, errors.Is(err, ErrInvalidKey))
+
+		_, err = ParseCompositeKey2[string, uint64](s, "stocks:A:-1")
+		assert.True(t, errors.Is(err, ErrInvalidKey))
+	})
+}
+
+func TestNewSchema_Invalid_Prefix(t *testing.T) {
+	assert.Panics(t, func() {
+		NewSchema("")
+	})
+	assert.Panics(t, func() {
+		NewSchema("users:v1")
+	})
+}
@@ -0,0 +1,180 @@
+package keys
+
+// IntKey is a key with an integer id, e.g. users:123
+type IntKey struct {
+	Schema Schema
+	ID     int64
+}
+
+// NewIntKey ...
+func NewIntKey(schema Schema, id int64) IntKey {
+	return IntKey{
+		Schema: schema,
+		ID:     id,
+	}
+}
+
+// String ...
+func (k IntKey) String() string {
+	return k.Schema.buildKey(formatPart(k.ID))
+}
+
+// AvgBucketSizeLog implements mmap.RootKey
+func (k IntKey) AvgBucketSizeLog() uint8 {
+	return k.Schema.avgBucketSizeLog
+}
+
+// ParseIntKey parses the string returned from IntKey.String with the same schema
+func ParseIntKey(schema Schema, key string) (IntKey, error) {
+	parts, err := schema.splitKey(key, 1)
+	if err != nil {
+		return IntKey{}, err
+	}
+
+	id, err := parsePart[int64](parts[0])
+	if err != nil {
+		return IntKey{}, err
+	}
+	return NewIntKey(schema, id), nil
+}
+
+// StringKey is a key with a string id, e.g. users:john
+type StringKey struct {
+	Schema Schema
+	ID     string
+}
+
+// NewStringKey ...
+func NewStringKey(schema Schema, id string) StringKey {
+	return StringKey{
+		Schema: schema,
+		ID:     id,
+	}
+}
+
+// String ...
+func (k StringKey) String() string {
+	return k.Schema.buildKey(formatPart(k.ID))
+}
+
+// AvgBucketSizeLog implements mmap.RootKey
+func (k StringKey) AvgBucketSizeLog() uint8 {
+	return k.Schema.avgBucketSizeLog
+}
+
+// ParseStringKey parses the string returned from StringKey.String with the same schema
+func ParseStringKey(schema Schema, key string) (StringKey, error) {
+	parts, err := schema.splitKey(key, 1)
+	if err != nil {
+		return StringKey{}, err
+	}
+
+	id, err := parsePart[string](parts[0])
+	if err != nil {
+		return StringKey{}, err
+	}
+	return NewStringKey(schema, id), nil
+}
+
+// CompositeKey2 is a key with 2 segments, e.g. orders:TENANT01:123
+type CompositeKey2[A Part, B Part] struct {
+	Schema Schema
+	First  A
+	Second B
+}
+
+// NewCompositeKey2 ...
+func NewCompositeKey2[A Part, B Part](schema Schema, first A, second B) CompositeKey2[A, B] {
+	return CompositeKey2[A, B]{
+		Schema: schema,
+		First:  first,
+		Second: second,
+	}
+}
+
+// String ...
+func (k CompositeKey2[A, B]) String() string {
+	return k.Schema.buildKey(formatPart(k.First), formatPart(k.Second))
+}
+
+// AvgBucketSizeLog implements mmap.RootKey
+func (k CompositeKey2[A, B]) AvgBucketSizeLog() uint8 {
+	return k.Schema.avgBucketSizeLog
+}
+
+// ParseCompositeKey2 parses the string returned from CompositeKey2.String with the same schema
+func ParseCompositeKey2[A Part, B Part](schema Schema, key string) (CompositeKey2[A, B], error) {
+	parts, err := schema.splitKey(key, 2)
+	if err != nil {
+		return CompositeKey2[A, B]{}, err
+	}
+
+	first, err := parsePart[A](parts[0])
+	if err != nil {
+		return CompositeKey2[A, B]{}, err
+	}
+
+	second, err := parsePart[B](parts[1])
+	if err != nil {
+		return CompositeKey2[A, B]{}, err
+	}
+
+	return NewCompositeKey2(schema, first, second), nil
+}
+
+// CompositeKey3 is a key with 3 segments, e.g. stocks:TENANT01:SKU01:LOC01
+type CompositeKey3[A Part, B Part, C Part] struct {
+	Schema Schema
+	First  A
+	Second B
+	Third  C
+}
+
+// NewCompositeKey3 ...
+func NewCompositeKey3[A Part, B Part, C Part](schema Schema, first A, second B, third C) CompositeKey3[A, B, C] {
+	return CompositeKey3[A, B, C]{
+		Schema: schema,
+		First:  first,
+		Second: second,
+		Third:  third,
+	}
+}
+
+// String ...
+//
+//revive:disable-next-line:confusing-naming
+func (k CompositeKey3[A, B, C]) String() string {
+	return k.Schema.buildKey(formatPart(k.First), formatPart(k.Second), formatPart(k.Third))
+}
+
+// AvgBucketSizeLog implements mmap.RootKey
+//
+//revive:disable-next-line:confusing-naming
+func (k CompositeKey3[A, B, C]) AvgBucketSizeLog() uint8 {
+	return k.Schema.avgBucketSizeLog
+}
+
+// ParseCompositeKey3 parses the string returned from CompositeKey3.String with the same schema
+func ParseCompositeKey3[A Part, B Part, C Part](schema Schema, key string) (CompositeKey3[A, B, C], error) {
+	parts, err := schema.splitKey(key, 3)
+	if err != nil {
+		return CompositeKey3[A, B, C]{}, err
+	}
+
+	first, err := parsePart[A](parts[0])
+	if err != nil {
+		return CompositeKey3[A, B, C]{}, err
+	}
+
+	second, err := parsePart[B](parts[1])
+	if err != nil {
+		return CompositeKey3[A, B, C]{}, err
+	}
+
+	third, err := parsePart[C](parts[2])
+	if err != nil {
+		return CompositeKey3[A, B, C]{}, err
+	}
+
+	return NewCompositeKey3(schema, first, second, third), nil
+}
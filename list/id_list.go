@@ -0,0 +1,84 @@
+package list
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+
+	"github.com/QuangTung97/memproxy/item"
+)
+
+// IDList is the value stored in the memcached server for each query
+type IDList[P item.Value] struct {
+	IDs []P
+}
+
+func putLength(buf *bytes.Buffer, length int) {
+	var lenBytes [binary.MaxVarintLen64]byte
+
+	n := binary.PutUvarint(lenBytes[:], uint64(length))
+	_, _ = buf.Write(lenBytes[:n])
+}
+
+// Marshal ...
+func (l IDList[P]) Marshal() ([]byte, error) {
+	var buf bytes.Buffer
+
+	putLength(&buf, len(l.IDs))
+
+	for _, id := range l.IDs {
+		data, err := id.Marshal()
+		if err != nil {
+			return nil, err
+		}
+
+		putLength(&buf, len(data))
+		_, _ = buf.Write(data)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// NewIDListUnmarshaler ...
+func NewIDListUnmarshaler[P item.Value](
+	unmarshaler item.Unmarshaler[P],
+) func(data []byte) (IDList[P], error) {
+	return func(data []byte) (IDList[P], error) {
+		numIDs, n := binary.Uvarint(data)
+		if n <= 0 {
+			return IDList[P]{}, errors.New("list: invalid number of ids")
+		}
+		data = data[n:]
+
+		// each id has at least one byte for its length
+		if numIDs > uint64(len(data)) {
+			return IDList[P]{}, errors.New("list: number of ids exceeds data size")
+		}
+
+		ids := make([]P, 0, numIDs)
+
+		for i := uint64(0); i < numIDs; i++ {
+			numBytes, n := binary.Uvarint(data)
+			if n <= 0 {
+				return IDList[P]{}, errors.New("list: invalid length number of id")
+			}
+			data = data[n:]
+
+			if numBytes > uint64(len(data)) {
+				return IDList[P]{}, errors.New("list: invalid id data")
+			}
+
+			id, err := unmarshaler(data[:numBytes])
+			if err != nil {
+				return IDList[P]{}, err
+			}
+			ids = append(ids, id)
+
+			data = data[numBytes:]
+		}
+
+		return IDList[P]{
+			IDs: ids,
+		}, nil
+	}
+}
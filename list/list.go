@@ -0,0 +1,213 @@
+package list
+
+import (
+	"context"
+	"errors"
+
+	"github.com/QuangTung97/memproxy"
+	"github.com/QuangTung97/memproxy/item"
+)
+
+// ID is the constraint of the element ids, is also the key of the entity item
+type ID interface {
+	item.Key
+	item.Value
+}
+
+// Filler for getting the ordered list of ids of a query from the backing store (e.g. Database)
+type Filler[Q any, P any] func(ctx context.Context, query Q) func() ([]P, error)
+
+// Cursor points to the position after an element in the list, the zero value is the beginning of the list
+type Cursor[P comparable] struct {
+	Valid bool
+	After P
+}
+
+// Page ...
+type Page[T any, P comparable] struct {
+	Values []T
+
+	// Next is the cursor of the next page, Next.Valid = false when there is no more page
+	Next Cursor[P]
+}
+
+// ErrCursorNotFound returned when the element of the cursor is no longer in the list
+var ErrCursorNotFound = errors.New("list: cursor not found")
+
+type listConfig struct {
+	itemOptions []item.Option
+}
+
+// Option ...
+type Option func(conf *listConfig)
+
+// WithItemOptions configures the options of the item storing the lists of ids
+func WithItemOptions(options ...item.Option) Option {
+	return func(conf *listConfig) {
+		conf.itemOptions = options
+	}
+}
+
+// List caches the ordered lists of ids per query (e.g. latest orders of a customer),
+// and gets the entities from an entity item.
+// List is NOT thread safe
+type List[T item.Value, Q item.Key, P ID] struct {
+	sess memproxy.Session
+
+	ids      *item.Item[IDList[P], Q]
+	entities *item.Item[T, P]
+}
+
+// New creates a List.
+// Param: entities is the item for getting entities by ids, and SHOULD use the same pipeline.
+// Param: unmarshaler is for unmarshalling the id type.
+// Param: filler is for getting the whole ordered list of ids of a query
+func New[T item.Value, Q item.Key, P ID](
+	pipeline memproxy.Pipeline,
+	entities *item.Item[T, P],
+	unmarshaler item.Unmarshaler[P],
+	filler Filler[Q, P],
+	options ...Option,
+) *List[T, Q, P] {
+	conf := &listConfig{}
+	for _, fn := range options {
+		fn(conf)
+	}
+
+	listFiller := func(ctx context.Context, query Q) func() (IDList[P], error) {
+		fn := filler(ctx, query)
+		return func() (IDList[P], error) {
+			ids, err := fn()
+			if err != nil {
+				return IDList[P]{}, err
+			}
+			return IDList[P]{IDs: ids}, nil
+		}
+	}
+
+	ids := item.New[IDList[P], Q](
+		pipeline, NewIDListUnmarshaler(unmarshaler), listFiller, conf.itemOptions...,
+	)
+
+	return &List[T, Q, P]{
+		sess: ids.LowerSession(),
+
+		ids:      ids,
+		entities: entities,
+	}
+}
+
+// GetIDs returns the whole ordered list of ids of the query
+func (l *List[T, Q, P]) GetIDs(ctx context.Context, query Q) func() ([]P, error) {
+	fn := l.ids.Get(ctx, query)
+	return func() ([]P, error) {
+		list, err := fn()
+		return list.IDs, err
+	}
+}
+
+// GetPage returns at most limit entities after the cursor, limit <= 0 means no limit
+func (l *List[T, Q, P]) GetPage(
+	ctx context.Context, query Q,
+	cursor Cursor[P], limit int,
+) func() (Page[T, P], error) {
+	listState := l.ids.GetFast(ctx, query)
+
+	var result Page[T, P]
+	var err error
+
+	l.sess.AddNextCall(memproxy.NewEmptyCallback(func() {
+		list, getErr := listState.Result()
+		if getErr != nil {
+			err = getErr
+			return
+		}
+
+		pageIDs, next, pageErr := computePage(list.IDs, cursor, limit)
+		if pageErr != nil {
+			err = pageErr
+			return
+		}
+		result.Next = next
+
+		// calling GetMulti in the next round, because calling Result() of other lists
+		// in this round will execute the lease gets of the entity item one by one
+		l.sess.AddNextCall(memproxy.NewEmptyCallback(func() {
+			entitiesFn := l.entities.GetMulti(ctx, pageIDs)
+
+			l.sess.AddNextCall(memproxy.NewEmptyCallback(func() {
+				result.Values, err = entitiesFn()
+			}))
+		}))
+	}))
+
+	return func() (Page[T, P], error) {
+		l.sess.Execute()
+		if err != nil {
+			return Page[T, P]{}, err
+		}
+		return result, nil
+	}
+}
+
+func computePage[P comparable](ids []P, cursor Cursor[P], limit int) ([]P, Cursor[P], error) {
+	begin := 0
+	if cursor.Valid {
+		found := false
+		for index, id := range ids {
+			if id == cursor.After {
+				begin = index + 1
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, Cursor[P]{}, ErrCursorNotFound
+		}
+	}
+
+	end := begin + limit
+	if limit <= 0 || end >= len(ids) {
+		return ids[begin:], Cursor[P]{}, nil
+	}
+
+	return ids[begin:end], Cursor[P]{
+		Valid: true,
+		After: ids[end-1],
+	}, nil
+}
+
+// LowerSession ...
+func (l *List[T, Q, P]) LowerSession() memproxy.Session {
+	return l.sess.GetLower()
+}
+
+// Reset clear in-memory cached values
+func (l *List[T, Q, P]) Reset() {
+	l.ids.Reset()
+}
+
+// GetItemStats returns the stats of the item storing the lists of ids
+func (l *List[T, Q, P]) GetItemStats() item.Stats {
+	return l.ids.GetStats()
+}
+
+// Invalidate deletes the cached lists of the queries,
+// should be called for all the queries affected when an element is added to or removed from the backing store
+func Invalidate[Q item.Key](pipeline memproxy.Pipeline, queries ...Q) func() error {
+	fnList := make([]func() (memproxy.DeleteResponse, error), 0, len(queries))
+	for _, q := range queries {
+		fnList = append(fnList, pipeline.Delete(q.String(), memproxy.DeleteOptions{}))
+	}
+
+	return func() error {
+		var lastErr error
+		for _, fn := range fnList {
+			_, err := fn()
+			if err != nil {
+				lastErr = err
+			}
+		}
+		return lastErr
+	}
+}
@@ -0,0 +1,268 @@
+package list
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/QuangTung97/memproxy"
+	"github.com/QuangTung97/memproxy/fake"
+	"github.com/QuangTung97/memproxy/item"
+)
+
+type orderValue struct {
+	ID         int64  `json:"id"`
+	CustomerID int64  `json:"customer_id"`
+	Status     string `json:"status"`
+}
+
+func (o orderValue) Marshal() ([]byte, error) {
+	return json.Marshal(o)
+}
+
+func unmarshalOrder(data []byte) (orderValue, error) {
+	var o orderValue
+	err := json.Unmarshal(data, &o)
+	return o, err
+}
+
+type orderKey struct {
+	ID int64
+}
+
+func (k orderKey) String() string {
+	return fmt.Sprintf("orders:%d", k.ID)
+}
+
+func (k orderKey) Marshal() ([]byte, error) {
+	return []byte(strconv.FormatInt(k.ID, 10)), nil
+}
+
+func unmarshalOrderKey(data []byte) (orderKey, error) {
+	id, err := strconv.ParseInt(string(data), 10, 64)
+	return orderKey{ID: id}, err
+}
+
+type customerOrdersQuery struct {
+	CustomerID int64
+}
+
+func (q customerOrdersQuery) String() string {
+	return fmt.Sprintf("orders:customer:%d", q.CustomerID)
+}
+
+type listTest struct {
+	mc *fake.Memcache
+
+	orders []orderValue
+
+	entityFills [][]orderKey
+	listFills   []customerOrdersQuery
+}
+
+func newListTest() *listTest {
+	return &listTest{
+		mc: fake.New(),
+	}
+}
+
+func (h *listTest) newList(pipe memproxy.Pipeline) *List[orderValue, customerOrdersQuery, orderKey] {
+	entities := item.New[orderValue, orderKey](
+		pipe, unmarshalOrder,
+		item.NewMultiGetFiller[orderValue, orderKey](
+			func(ctx context.Context, keys []orderKey) ([]orderValue, error) {
+				h.entityFills = append(h.entityFills, keys)
+				var result []orderValue
+				for _, k := range keys {
+					for _, o := range h.orders {
+						if o.ID == k.ID {
+							result = append(result, o)
+						}
+					}
+				}
+				return result, nil
+			},
+			func(v orderValue) orderKey {
+				return orderKey{ID: v.ID}
+			},
+		),
+	)
+
+	filler := func(ctx context.Context, query customerOrdersQuery) func() ([]orderKey, error) {
+		h.listFills = append(h.listFills, query)
+		return func() ([]orderKey, error) {
+			var result []orderKey
+			for i := len(h.orders) - 1; i >= 0; i-- {
+				o := h.orders[i]
+				if o.CustomerID == query.CustomerID {
+					result = append(result, orderKey{ID: o.ID})
+				}
+			}
+			return result, nil
+		}
+	}
+
+	return New[orderValue, customerOrdersQuery, orderKey](pipe, entities, unmarshalOrderKey, filler)
+}
+
+func (h *listTest) getPage(
+	query customerOrdersQuery, cursor Cursor[orderKey], limit int,
+) (Page[orderValue, orderKey], error) {
+	pipe := h.mc.Pipeline(context.Background())
+	defer pipe.Finish()
+
+	return h.newList(pipe).GetPage(context.Background(), query, cursor, limit)()
+}
+
+func TestList_GetPage(t *testing.T) {
+	t.Run("paging", func(t *testing.T) {
+		h := newListTest()
+
+		o1 := orderValue{ID: 11, CustomerID: 1, Status: "created"}
+		o2 := orderValue{ID: 12, CustomerID: 2, Status: "created"}
+		o3 := orderValue{ID: 13, CustomerID: 1, Status: "paid"}
+		o4 := orderValue{ID: 14, CustomerID: 1, Status: "paid"}
+		h.orders = []orderValue{o1, o2, o3, o4}
+
+		query := customerOrdersQuery{CustomerID: 1}
+
+		page, err := h.getPage(query, Cursor[orderKey]{}, 2)
+		assert.Equal(t, nil, err)
+		assert.Equal(t, Page[orderValue, orderKey]{
+			Values: []orderValue{o4, o3},
+			Next:   Cursor[orderKey]{Valid: true, After: orderKey{ID: 13}},
+		}, page)
+
+		page, err = h.getPage(query, page.Next, 2)
+		assert.Equal(t, nil, err)
+		assert.Equal(t, Page[orderValue, orderKey]{
+			Values: []orderValue{o1},
+		}, page)
+
+		assert.Equal(t, []customerOrdersQuery{query}, h.listFills)
+		assert.Equal(t, [][]orderKey{
+			{{ID: 14}, {ID: 13}},
+			{{ID: 11}},
+		}, h.entityFills)
+
+		// Get From Cache
+		page, err = h.getPage(query, Cursor[orderKey]{}, 0)
+		assert.Equal(t, nil, err)
+		assert.Equal(t, Page[orderValue, orderKey]{
+			Values: []orderValue{o4, o3, o1},
+		}, page)
+		assert.Equal(t, 1, len(h.listFills))
+		assert.Equal(t, 2, len(h.entityFills))
+	})
+
+	t.Run("cursor-not-found", func(t *testing.T) {
+		h := newListTest()
+
+		h.orders = []orderValue{{ID: 11, CustomerID: 1}}
+
+		page, err := h.getPage(customerOrdersQuery{CustomerID: 1}, Cursor[orderKey]{
+			Valid: true,
+			After: orderKey{ID: 12},
+		}, 2)
+		assert.Equal(t, ErrCursorNotFound, err)
+		assert.Equal(t, Page[orderValue, orderKey]{}, page)
+	})
+
+	t.Run("multiple-queries-batching", func(t *testing.T) {
+		h := newListTest()
+
+		o1 := orderValue{ID: 11, CustomerID: 1}
+		o2 := orderValue{ID: 12, CustomerID: 2}
+		o3 := orderValue{ID: 13, CustomerID: 1}
+		h.orders = []orderValue{o1, o2, o3}
+
+		pipe := h.mc.Pipeline(context.Background())
+		l := h.newList(pipe)
+
+		fn1 := l.GetPage(context.Background(), customerOrdersQuery{CustomerID: 1}, Cursor[orderKey]{}, 10)
+		fn2 := l.GetPage(context.Background(), customerOrdersQuery{CustomerID: 2}, Cursor[orderKey]{}, 10)
+
+		page1, err := fn1()
+		assert.Equal(t, nil, err)
+		assert.Equal(t, []orderValue{o3, o1}, page1.Values)
+
+		page2, err := fn2()
+		assert.Equal(t, nil, err)
+		assert.Equal(t, []orderValue{o2}, page2.Values)
+
+		pipe.Finish()
+
+		assert.Equal(t, [][]orderKey{
+			{{ID: 13}, {ID: 11}, {ID: 12}},
+		}, h.entityFills)
+	})
+
+	t.Run("invalidate-after-adding", func(t *testing.T) {
+		h := newListTest()
+
+		o1 := orderValue{ID: 11, CustomerID: 1}
+		h.orders = []orderValue{o1}
+
+		query := customerOrdersQuery{CustomerID: 1}
+
+		page, err := h.getPage(query, Cursor[orderKey]{}, 10)
+		assert.Equal(t, nil, err)
+		assert.Equal(t, []orderValue{o1}, page.Values)
+
+		o2 := orderValue{ID: 12, CustomerID: 1}
+		h.orders = append(h.orders, o2)
+
+		pipe := h.mc.Pipeline(context.Background())
+		err = Invalidate(pipe, query)()
+		pipe.Finish()
+		assert.Equal(t, nil, err)
+
+		page, err = h.getPage(query, Cursor[orderKey]{}, 10)
+		assert.Equal(t, nil, err)
+		assert.Equal(t, []orderValue{o2, o1}, page.Values)
+		assert.Equal(t, 2, len(h.listFills))
+	})
+}
+
+func TestIDList_Marshal(t *testing.T) {
+	l := IDList[orderKey]{
+		IDs: []orderKey{{ID: 11}, {ID: 1234}},
+	}
+
+	data, err := l.Marshal()
+	assert.Equal(t, nil, err)
+
+	result, err := NewIDListUnmarshaler(unmarshalOrderKey)(data)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, l, result)
+}
+
+func TestIDList_Unmarshal_Invalid(t *testing.T) {
+	unmarshal := NewIDListUnmarshaler(unmarshalOrderKey)
+
+	data, err := IDList[orderKey]{IDs: []orderKey{{ID: 11}}}.Marshal()
+	assert.Equal(t, nil, err)
+
+	_, err = unmarshal(nil)
+	assert.Equal(t, errors.New("list: invalid number of ids"), err)
+
+	// huge number of ids
+	_, err = unmarshal(binary.AppendUvarint(nil, math.MaxUint64))
+	assert.Equal(t, errors.New("list: number of ids exceeds data size"), err)
+
+	// huge length of id
+	hugeLen := binary.AppendUvarint(binary.AppendUvarint(nil, 1), math.MaxUint64)
+	_, err = unmarshal(append(hugeLen, 'a'))
+	assert.Equal(t, errors.New("list: invalid id data"), err)
+
+	// truncated
+	_, err = unmarshal(data[:len(data)-1])
+	assert.Equal(t, errors.New("list: invalid id data"), err)
+}
@@ -95,6 +95,8 @@ type Session interface {
 
 // LeaseGetOptions lease get options
 type LeaseGetOptions struct {
+	// NoLease when true, will NOT create a lease for missed keys, and return LeaseGetStatusNotFound instead
+	NoLease bool
 }
 
 // LeaseGetStatus status of lease get
@@ -109,6 +111,9 @@ const (
 
 	// LeaseGetStatusLeaseRejected lease rejected
 	LeaseGetStatusLeaseRejected
+
+	// LeaseGetStatusNotFound key not found, only returned when LeaseGetOptions.NoLease = true
+	LeaseGetStatusNotFound
 )
 
 // LeaseGetResponse lease get response
@@ -60,6 +60,11 @@ func New[T Value, R RootKey, K Key](
 ) *Map[T, R, K] {
 	conf := computeMapConfig(options)
 
+	itemOptions := conf.itemOptions
+	if conf.maxValueSize > 0 {
+		itemOptions = append(itemOptions[:len(itemOptions):len(itemOptions)], item.WithMaxValueSize(conf.maxValueSize))
+	}
+
 	bucketFiller := func(ctx context.Context, key BucketKey[R]) func() (Bucket[T], error) {
 		fn := filler(ctx, key.RootKey, key.GetHashRange())
 		return func() (Bucket[T], error) {
@@ -78,7 +83,7 @@ func New[T Value, R RootKey, K Key](
 			pipeline,
 			NewBucketUnmarshaler(unmarshaler),
 			bucketFiller,
-			conf.itemOptions...,
+			itemOptions...,
 		),
 		getKeyFunc: getKeyFunc,
 		separator:  conf.separator,
@@ -197,6 +197,58 @@ func TestMap(t *testing.T) {
 		assert.Equal(t, "p/stocks/SKU01:0:", calls[0].Key)
 	})
 
+	t.Run("bucket exceeded max value size, delete instead of set", func(t *testing.T) {
+		m := newMapTest(
+			WithMaxValueSize(16),
+			WithItemOptions(item.WithErrorLogger(func(err error) {})),
+		)
+
+		hash1 := newHash(0x1122, 2)
+		stock1 := stockLocation{
+			Sku:      sku1,
+			Location: loc1,
+			Hash:     hash1,
+			Quantity: 41,
+		}
+
+		m.stubLeaseGet(memproxy.LeaseGetResponse{
+			Status: memproxy.LeaseGetStatusLeaseGranted,
+			CAS:    3300,
+		})
+		m.stubFillFunc(stock1)
+		m.stubLeaseSet()
+		m.pipe.DeleteFunc = func(key string, options memproxy.DeleteOptions) func() (memproxy.DeleteResponse, error) {
+			return func() (memproxy.DeleteResponse, error) {
+				return memproxy.DeleteResponse{}, nil
+			}
+		}
+
+		fn := m.mmap.Get(context.Background(), 3,
+			stockLocationRootKey{
+				sku: sku1,
+			},
+			stockLocationKey{
+				loc:  loc1,
+				hash: hash1,
+			},
+		)
+
+		result, err := fn()
+		assert.Equal(t, nil, err)
+		assert.Equal(t, Option[stockLocation]{
+			Valid: true,
+			Data:  stock1,
+		}, result)
+
+		assert.Equal(t, 0, len(m.pipe.LeaseSetCalls()))
+
+		deleteCalls := m.pipe.DeleteCalls()
+		assert.Equal(t, 1, len(deleteCalls))
+		assert.Equal(t, "p/stocks/SKU01:0:", deleteCalls[0].Key)
+
+		assert.Equal(t, uint64(1), m.mmap.GetItemStats().ValueTooLargeCount)
+	})
+
 	t.Run("with single bucket elem count, not found", func(t *testing.T) {
 		m := newMapTest()
 
@@ -520,7 +572,7 @@ func TestMap(t *testing.T) {
 		)
 
 		result, err := fn()
-		assert.Equal(t, errors.New("fill error"), err)
+		assert.Equal(t, errors.New("fill error"), errors.Unwrap(err))
 		assert.Equal(t, Option[stockLocation]{}, result)
 	})
 
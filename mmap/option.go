@@ -5,8 +5,9 @@ import (
 )
 
 type mapConfig struct {
-	itemOptions []item.Option
-	separator   string
+	itemOptions  []item.Option
+	separator    string
+	maxValueSize int
 }
 
 func computeMapConfig(options []MapOption) mapConfig {
@@ -36,3 +37,10 @@ func WithSeparator(sep string) MapOption {
 		conf.separator = sep
 	}
 }
+
+// WithMaxValueSize configures the maximum size in bytes of the buckets, similar to item.WithMaxValueSize
+func WithMaxValueSize(size int) MapOption {
+	return func(conf *mapConfig) {
+		conf.maxValueSize = size
+	}
+}
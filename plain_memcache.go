@@ -91,9 +91,14 @@ func (p *plainPipelineImpl) LowerSession() Session {
 }
 
 // LeaseGet ...
-func (p *plainPipelineImpl) LeaseGet(key string, _ LeaseGetOptions) LeaseGetResult {
+func (p *plainPipelineImpl) LeaseGet(key string, options LeaseGetOptions) LeaseGetResult {
+	leaseDuration := p.leaseDuration
+	if options.NoLease {
+		leaseDuration = 0
+	}
+
 	result, getErr := p.pipeline.MGetFast(key, memcache.MGetOptions{
-		N:   p.leaseDuration,
+		N:   leaseDuration,
 		CAS: true,
 	})
 	if getErr != nil {
@@ -120,6 +125,12 @@ func (r *plainLeaseGetResult) Result() (LeaseGetResponse, error) {
 		return LeaseGetResponse{}, err
 	}
 
+	if mgetResp.Type == memcache.MGetResponseTypeEN {
+		return LeaseGetResponse{
+			Status: LeaseGetStatusNotFound,
+		}, nil
+	}
+
 	if mgetResp.Type != memcache.MGetResponseTypeVA {
 		return LeaseGetResponse{}, ErrInvalidLeaseGetResponse
 	}
@@ -84,6 +84,37 @@ func TestPlainMemcache_LeaseGet_Granted_And_LeaseSet__Then_LeaseGet_Found(t *tes
 	}, leaseGetResp)
 }
 
+func TestPlainMemcache_LeaseGet_No_Lease(t *testing.T) {
+	m := newPlainMemcacheTest(t)
+
+	const key = "key01"
+
+	leaseGetResp, err := m.pipe.LeaseGet(key, LeaseGetOptions{NoLease: true}).Result()
+	assert.Equal(t, nil, err)
+	assert.Equal(t, LeaseGetResponse{
+		Status: LeaseGetStatusNotFound,
+	}, leaseGetResp)
+
+	// Lease Get Still Granted
+	leaseGetResp, err = m.pipe.LeaseGet(key, LeaseGetOptions{}).Result()
+	assert.Equal(t, nil, err)
+	assert.Equal(t, LeaseGetStatusLeaseGranted, leaseGetResp.Status)
+
+	cas := leaseGetResp.CAS
+
+	value := []byte("some value 01")
+	_, err = m.pipe.LeaseSet(key, value, cas, LeaseSetOptions{})()
+	assert.Equal(t, nil, err)
+
+	leaseGetResp, err = m.pipe.LeaseGet(key, LeaseGetOptions{NoLease: true}).Result()
+	assert.Equal(t, nil, err)
+	assert.Equal(t, LeaseGetResponse{
+		Status: LeaseGetStatusFound,
+		CAS:    cas + 1,
+		Data:   value,
+	}, leaseGetResp)
+}
+
 func TestPlainMemcache_LeaseGet_Rejected(t *testing.T) {
 	m := newPlainMemcacheTest(t)
 
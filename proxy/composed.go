@@ -0,0 +1,526 @@
+package proxy
+
+import (
+	"math/rand"
+)
+
+// Pool is a named child route of the composed routes, e.g. a replicated route of a group of servers
+type Pool struct {
+	// Name identifies the pool on the hash ring, keys stay on the same pool when other pools are added or removed
+	Name string
+
+	Route Route
+}
+
+// poolSet contains the common parts of the composed routes
+type poolSet struct {
+	pools []Pool
+
+	allServers []ServerID
+
+	// the indices of pools containing the server
+	serverPools map[ServerID][]int
+}
+
+func newPoolSet(pools []Pool, routeName string) poolSet {
+	if len(pools) == 0 {
+		panic(routeName + ": pools can not be empty")
+	}
+
+	names := map[string]struct{}{}
+	serverPools := map[ServerID][]int{}
+	var allServers []ServerID
+
+	for index, pool := range pools {
+		if pool.Route == nil {
+			panic(routeName + ": pool route is nil")
+		}
+
+		_, existed := names[pool.Name]
+		if pool.Name == "" || existed {
+			panic(routeName + ": pool names must be non-empty and unique")
+		}
+		names[pool.Name] = struct{}{}
+
+		for _, server := range pool.Route.AllServerIDs() {
+			poolIndices, existed := serverPools[server]
+			if !existed {
+				allServers = append(allServers, server)
+			}
+			serverPools[server] = append(poolIndices, index)
+		}
+	}
+
+	return poolSet{
+		pools:       pools,
+		allServers:  allServers,
+		serverPools: serverPools,
+	}
+}
+
+// poolSelectors contains the selectors of the pools, the selectors are created lazily
+type poolSelectors struct {
+	set *poolSet
+
+	selectors []Selector
+
+	// exhausted pools are pools with no available servers
+	exhausted      []bool
+	exhaustedCount int
+
+	// pools containing the last failed server
+	lastFailedPools []int
+}
+
+func newPoolSelectors(set *poolSet) poolSelectors {
+	return poolSelectors{
+		set:       set,
+		selectors: make([]Selector, len(set.pools)),
+		exhausted: make([]bool, len(set.pools)),
+	}
+}
+
+func (p *poolSelectors) get(index int) Selector {
+	sel := p.selectors[index]
+	if sel == nil {
+		sel = p.set.pools[index].Route.NewSelector()
+		p.selectors[index] = sel
+	}
+	return sel
+}
+
+// failedServerMarker is an optional interface implemented by the built-in selectors,
+// for marking a server shared between pools as failed without notifying the stats again
+type failedServerMarker interface {
+	markFailedServer(server ServerID)
+}
+
+func markFailedServer(sel Selector, server ServerID) {
+	if marker, ok := sel.(failedServerMarker); ok {
+		marker.markFailedServer(server)
+		return
+	}
+	sel.SetFailedServer(server)
+}
+
+// setFailedServer only lets the selector of the first pool containing the server notify the stats
+func (p *poolSelectors) setFailedServer(server ServerID) {
+	p.lastFailedPools = p.set.serverPools[server]
+	for i, index := range p.lastFailedPools {
+		sel := p.get(index)
+		if i == 0 {
+			sel.SetFailedServer(server)
+		} else {
+			markFailedServer(sel, server)
+		}
+		p.updateExhausted(index, sel)
+	}
+}
+
+func (p *poolSelectors) markFailedServer(server ServerID) {
+	p.lastFailedPools = p.set.serverPools[server]
+	for _, index := range p.lastFailedPools {
+		sel := p.get(index)
+		markFailedServer(sel, server)
+		p.updateExhausted(index, sel)
+	}
+}
+
+func (p *poolSelectors) updateExhausted(index int, sel Selector) {
+	if !p.exhausted[index] && !sel.HasNextAvailableServer() {
+		p.exhausted[index] = true
+		p.exhaustedCount++
+	}
+}
+
+func (p *poolSelectors) hasAvailablePool() bool {
+	return p.exhaustedCount < len(p.set.pools)
+}
+
+// hasAvailableFailedPool checks if any pool containing the last failed server still has available servers
+func (p *poolSelectors) hasAvailableFailedPool() bool {
+	for _, index := range p.lastFailedPools {
+		if !p.exhausted[index] {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *poolSelectors) appendForDelete(result []ServerID, index int, key string) []ServerID {
+OuterLoop:
+	for _, server := range p.get(index).SelectForDelete(key) {
+		for _, existed := range result {
+			if existed == server {
+				continue OuterLoop
+			}
+		}
+		result = append(result, server)
+	}
+	return result
+}
+
+func (p *poolSelectors) appendSkippedForDelete(result []ServerID, index int, key string) []ServerID {
+	sel, ok := p.get(index).(SkippedDeleteSelector)
+	if !ok {
+		return result
+	}
+	return append(result, sel.SkippedForDelete(key)...)
+}
+
+// selectWarmServer finds the warm server from the child selectors of the pools, see WarmUpSelector
+func (p *poolSelectors) selectWarmServer(indices []int, key string, server ServerID) (ServerID, bool) {
+	for _, index := range indices {
+		sel, ok := p.get(index).(WarmUpSelector)
+		if !ok {
+			continue
+		}
+		if warm, ok := sel.SelectWarmServer(key, server); ok {
+			return warm, true
+		}
+	}
+	return 0, false
+}
+
+func (p *poolSelectors) reset() {
+	for _, sel := range p.selectors {
+		if sel != nil {
+			sel.Reset()
+		}
+	}
+}
+
+// ---------------------------------------------
+// Sharded Pool Route
+// ---------------------------------------------
+
+type shardedPoolRoute struct {
+	set  poolSet
+	ring *hashRing
+	conf *shardedRouteConfig
+}
+
+type shardedPoolRouteSelector struct {
+	route *shardedPoolRoute
+	pools poolSelectors
+}
+
+var _ Route = &shardedPoolRoute{}
+var _ KeyFailoverSelector = &shardedPoolRouteSelector{}
+
+// NewShardedPoolRoute creates a route that partitions keys between pools using the consistent hash ring
+// similar to NewShardedRoute, each key is then routed by the route of its pool.
+// With FailoverNextNode, keys of a pool fall back to the next pool on the ring when all servers of the pool failed
+func NewShardedPoolRoute(
+	pools []Pool,
+	options ...ShardedRouteOption,
+) Route {
+	set := newPoolSet(pools, "sharded pool route")
+
+	conf := &shardedRouteConfig{
+		virtualNodes:   DefaultVirtualNodes,
+		failoverPolicy: FailoverNextNode,
+	}
+
+	for _, opt := range options {
+		opt(conf)
+	}
+
+	if conf.virtualNodes <= 0 {
+		panic("sharded pool route: virtual nodes must be positive")
+	}
+
+	labels := make([]string, 0, len(pools))
+	for _, pool := range pools {
+		labels = append(labels, pool.Name)
+	}
+
+	return &shardedPoolRoute{
+		set:  set,
+		ring: newHashRing(labels, conf.virtualNodes),
+		conf: conf,
+	}
+}
+
+// NewSelector ...
+func (r *shardedPoolRoute) NewSelector() Selector {
+	return &shardedPoolRouteSelector{
+		route: r,
+		pools: newPoolSelectors(&r.set),
+	}
+}
+
+// AllServerIDs returns the list of all possible servers of all pools
+func (r *shardedPoolRoute) AllServerIDs() []ServerID {
+	return r.set.allServers
+}
+
+// SetFailedServer ...
+func (s *shardedPoolRouteSelector) SetFailedServer(server ServerID) {
+	s.pools.setFailedServer(server)
+}
+
+func (s *shardedPoolRouteSelector) markFailedServer(server ServerID) {
+	s.pools.markFailedServer(server)
+}
+
+// HasNextAvailableServer check if next available server ready to be fallback to
+func (s *shardedPoolRouteSelector) HasNextAvailableServer() bool {
+	if s.route.conf.failoverPolicy == FailoverNone {
+		return s.pools.hasAvailableFailedPool()
+	}
+	return s.pools.hasAvailablePool()
+}
+
+// HasNextAvailableServerForKey check if next available server ready to be fallback to for the key,
+// with FailoverNone only the servers of the owner pool of the key are used
+func (s *shardedPoolRouteSelector) HasNextAvailableServerForKey(key string) bool {
+	if s.route.conf.failoverPolicy == FailoverNone {
+		owner, _ := s.choosePools(key, 1)
+		return !s.pools.exhausted[owner]
+	}
+	return s.pools.hasAvailablePool()
+}
+
+// choosePools returns the owner pool of the key and at most n available pools in the ring order
+func (s *shardedPoolRouteSelector) choosePools(key string, n int) (int, []int) {
+	owner := -1
+	var result []int
+
+	s.route.ring.walk(key, func(index int) bool {
+		if owner < 0 {
+			owner = index
+		}
+
+		if s.route.conf.failoverPolicy == FailoverNone {
+			result = append(result, index)
+			return false
+		}
+
+		if s.pools.exhausted[index] {
+			return true
+		}
+		result = append(result, index)
+		return len(result) < n
+	})
+
+	return owner, result
+}
+
+// SelectServer choose the server of the key from the owner pool on the hash ring,
+// or from the next available pool when all servers of the owner pool failed
+func (s *shardedPoolRouteSelector) SelectServer(key string) ServerID {
+	owner, indices := s.choosePools(key, 1)
+	if len(indices) == 0 {
+		return s.pools.get(owner).SelectServer(key)
+	}
+	return s.pools.get(indices[0]).SelectServer(key)
+}
+
+// SelectForDelete choose servers for deleting, includes the servers of the current pool of the key
+// and of its failover pool
+func (s *shardedPoolRouteSelector) SelectForDelete(key string) []ServerID {
+	owner, indices := s.choosePools(key, 2)
+	if len(indices) == 0 {
+		return s.pools.get(owner).SelectForDelete(key)
+	}
+
+	var result []ServerID
+	for _, index := range indices {
+		result = s.pools.appendForDelete(result, index, key)
+	}
+	return result
+}
+
+// SkippedForDelete returns the skipped servers of the pools used by SelectForDelete
+func (s *shardedPoolRouteSelector) SkippedForDelete(key string) []ServerID {
+	owner, indices := s.choosePools(key, 2)
+	if len(indices) == 0 {
+		return s.pools.appendSkippedForDelete(nil, owner, key)
+	}
+
+	var result []ServerID
+	for _, index := range indices {
+		result = s.pools.appendSkippedForDelete(result, index, key)
+	}
+	return result
+}
+
+// SelectWarmServer returns the warm server from the pools containing the server
+func (s *shardedPoolRouteSelector) SelectWarmServer(key string, server ServerID) (ServerID, bool) {
+	return s.pools.selectWarmServer(s.route.set.serverPools[server], key, server)
+}
+
+// Reset the selection
+func (s *shardedPoolRouteSelector) Reset() {
+	s.pools.reset()
+}
+
+// ---------------------------------------------
+// Replicated Pool Route
+// ---------------------------------------------
+
+type replicatedPoolRoute struct {
+	set   poolSet
+	conf  *replicatedRouteConfig
+	stats ServerStats
+}
+
+type replicatedPoolRouteSelector struct {
+	route *replicatedPoolRoute
+	pools poolSelectors
+
+	weightAccum []float64
+	remaining   []int
+
+	alreadyChosen bool
+	chosenPool    int
+}
+
+var _ Route = &replicatedPoolRoute{}
+
+// NewReplicatedPoolRoute creates a route that every pool holds a full copy of the keys, e.g. a pool of each zone.
+// Similar to NewReplicatedRoute, a pool is chosen for each pipeline with the weight computed from
+// the total memory usage of its servers, and falls back to other pools when all servers of the pool failed.
+// Deletes are sent to all available pools
+func NewReplicatedPoolRoute(
+	pools []Pool,
+	stats ServerStats,
+	options ...ReplicatedRouteOption,
+) Route {
+	set := newPoolSet(pools, "replicated pool route")
+
+	conf := &replicatedRouteConfig{
+		memScore: func(mem float64) float64 {
+			return mem
+		},
+		randFunc: func(n uint64) uint64 {
+			return uint64(rand.Intn(int(n)))
+		},
+		minPercent: 1.0, // 1%
+	}
+
+	for _, opt := range options {
+		opt(conf)
+	}
+
+	return &replicatedPoolRoute{
+		set:   set,
+		conf:  conf,
+		stats: stats,
+	}
+}
+
+// NewSelector ...
+func (r *replicatedPoolRoute) NewSelector() Selector {
+	return &replicatedPoolRouteSelector{
+		route: r,
+		pools: newPoolSelectors(&r.set),
+	}
+}
+
+// AllServerIDs returns the list of all possible servers of all pools
+func (r *replicatedPoolRoute) AllServerIDs() []ServerID {
+	return r.set.allServers
+}
+
+// SetFailedServer ...
+func (s *replicatedPoolRouteSelector) SetFailedServer(server ServerID) {
+	prevCount := s.pools.exhaustedCount
+	s.pools.setFailedServer(server)
+	s.resetIfExhausted(prevCount)
+}
+
+func (s *replicatedPoolRouteSelector) markFailedServer(server ServerID) {
+	prevCount := s.pools.exhaustedCount
+	s.pools.markFailedServer(server)
+	s.resetIfExhausted(prevCount)
+}
+
+func (s *replicatedPoolRouteSelector) resetIfExhausted(prevCount int) {
+	if s.pools.exhaustedCount != prevCount {
+		s.alreadyChosen = false
+	}
+}
+
+// HasNextAvailableServer check if next available server ready to be fallback to
+func (s *replicatedPoolRouteSelector) HasNextAvailableServer() bool {
+	return s.pools.hasAvailablePool()
+}
+
+func (s *replicatedPoolRouteSelector) computeRemainingPools() []int {
+	s.remaining = s.remaining[:0]
+	for index := range s.route.set.pools {
+		if !s.pools.exhausted[index] {
+			s.remaining = append(s.remaining, index)
+		}
+	}
+
+	if len(s.remaining) == 0 {
+		for index := range s.route.set.pools {
+			s.remaining = append(s.remaining, index)
+		}
+	}
+	return s.remaining
+}
+
+func (s *replicatedPoolRouteSelector) choosePool() int {
+	if s.alreadyChosen {
+		return s.chosenPool
+	}
+
+	remaining := s.computeRemainingPools()
+
+	s.weightAccum = s.weightAccum[:0]
+	for _, index := range remaining {
+		w := 0.0
+		for _, server := range s.route.set.pools[index].Route.AllServerIDs() {
+			w += s.route.conf.memScore(s.route.stats.GetMemUsage(server))
+		}
+		// current not accumulated
+		s.weightAccum = append(s.weightAccum, w)
+	}
+
+	randVal := s.route.conf.randFunc(RandomMaxValues)
+
+	chosen, weights := computeChosenServer(s.weightAccum, s.route.conf.minPercent, randVal)
+	s.weightAccum = weights
+
+	s.alreadyChosen = true
+	s.chosenPool = remaining[chosen]
+	return s.chosenPool
+}
+
+// SelectServer choose a pool for the pipeline, and the server of the key from that pool
+func (s *replicatedPoolRouteSelector) SelectServer(key string) ServerID {
+	return s.pools.get(s.choosePool()).SelectServer(key)
+}
+
+// SelectForDelete choose servers for deleting from all available pools
+func (s *replicatedPoolRouteSelector) SelectForDelete(key string) []ServerID {
+	var result []ServerID
+	for _, index := range s.computeRemainingPools() {
+		result = s.pools.appendForDelete(result, index, key)
+	}
+	return result
+}
+
+// SkippedForDelete returns the skipped servers of the available pools
+func (s *replicatedPoolRouteSelector) SkippedForDelete(key string) []ServerID {
+	var result []ServerID
+	for _, index := range s.computeRemainingPools() {
+		result = s.pools.appendSkippedForDelete(result, index, key)
+	}
+	return result
+}
+
+// SelectWarmServer returns the warm server from the pools containing the server
+func (s *replicatedPoolRouteSelector) SelectWarmServer(key string, server ServerID) (ServerID, bool) {
+	return s.pools.selectWarmServer(s.route.set.serverPools[server], key, server)
+}
+
+// Reset the selection
+func (s *replicatedPoolRouteSelector) Reset() {
+	s.alreadyChosen = false
+	s.pools.reset()
+}
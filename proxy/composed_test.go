@@ -0,0 +1,317 @@
+package proxy
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/QuangTung97/memproxy"
+)
+
+type composedRouteTest struct {
+	stats *ServerStatsMock
+
+	route    Route
+	selector Selector
+}
+
+func newComposedStats() *ServerStatsMock {
+	return &ServerStatsMock{
+		NotifyServerFailedFunc: func(server ServerID) {
+		},
+		IsServerFailedFunc: func(server ServerID) bool {
+			return false
+		},
+		GetMemUsageFunc: func(server ServerID) float64 {
+			return 1000
+		},
+	}
+}
+
+func alwaysRand(val uint64) ReplicatedRouteOption {
+	return WithRandFunc(func(n uint64) uint64 {
+		return val
+	})
+}
+
+// sharded between 3 pools, each pool replicated between 2 servers
+func newShardedOfReplicatedTest() *composedRouteTest {
+	r := &composedRouteTest{
+		stats: newComposedStats(),
+	}
+
+	newPool := func(name string, servers ...ServerID) Pool {
+		return Pool{
+			Name:  name,
+			Route: NewReplicatedRoute(servers, r.stats, alwaysRand(0)),
+		}
+	}
+
+	r.route = NewShardedPoolRoute([]Pool{
+		newPool("pool-a", 11, 12),
+		newPool("pool-b", 21, 22),
+		newPool("pool-c", 31, 32),
+	})
+	r.selector = r.route.NewSelector()
+	return r
+}
+
+func poolOfServer(server ServerID) ServerID {
+	return server / 10
+}
+
+func TestShardedPoolRoute(t *testing.T) {
+	t.Run("all server ids", func(t *testing.T) {
+		r := newShardedOfReplicatedTest()
+		assert.Equal(t, []ServerID{11, 12, 21, 22, 31, 32}, r.route.AllServerIDs())
+	})
+
+	t.Run("keys sharded between pools", func(t *testing.T) {
+		r := newShardedOfReplicatedTest()
+
+		const numKeys = 30000
+		counters := map[ServerID]int{}
+		for i := 0; i < numKeys; i++ {
+			server := r.selector.SelectServer(fmt.Sprintf("key:%d", i))
+			counters[poolOfServer(server)]++
+		}
+
+		assert.Equal(t, 3, len(counters))
+		for _, count := range counters {
+			assert.InDelta(t, numKeys/3, count, numKeys*0.05)
+		}
+	})
+
+	t.Run("failover inside pool then to next pool", func(t *testing.T) {
+		r := newShardedOfReplicatedTest()
+
+		first := r.selector.SelectServer("key01")
+		pool := poolOfServer(first)
+
+		deleted := r.selector.SelectForDelete("key01")
+		assert.Equal(t, 4, len(deleted))
+		assert.Equal(t, []ServerID{pool*10 + 1, pool*10 + 2}, deleted[:2])
+		nextPool := poolOfServer(deleted[2])
+		assert.NotEqual(t, pool, nextPool)
+
+		// failover to the other replica
+		r.selector.SetFailedServer(first)
+		assert.Equal(t, true, r.selector.HasNextAvailableServer())
+
+		second := r.selector.SelectServer("key01")
+		assert.Equal(t, pool, poolOfServer(second))
+		assert.NotEqual(t, first, second)
+
+		// failover to the next pool
+		r.selector.SetFailedServer(second)
+		assert.Equal(t, true, r.selector.HasNextAvailableServer())
+
+		third := r.selector.SelectServer("key01")
+		assert.Equal(t, nextPool, poolOfServer(third))
+
+		deleted = r.selector.SelectForDelete("key01")
+		assert.Equal(t, 4, len(deleted))
+		assert.Equal(t, []ServerID{nextPool*10 + 1, nextPool*10 + 2}, deleted[:2])
+
+		assert.Equal(t, []ServerID{first, second}, []ServerID{
+			r.stats.NotifyServerFailedCalls()[0].Server,
+			r.stats.NotifyServerFailedCalls()[1].Server,
+		})
+
+		// all failed
+		for _, server := range r.route.AllServerIDs() {
+			r.selector.SetFailedServer(server)
+		}
+		assert.Equal(t, false, r.selector.HasNextAvailableServer())
+		assert.Equal(t, pool, poolOfServer(r.selector.SelectServer("key01")))
+	})
+
+	t.Run("failover none", func(t *testing.T) {
+		stats := newComposedStats()
+		route := NewShardedPoolRoute([]Pool{
+			{Name: "pool-a", Route: NewReplicatedRoute([]ServerID{11, 12}, stats, alwaysRand(0))},
+			{Name: "pool-b", Route: NewReplicatedRoute([]ServerID{21, 22}, stats, alwaysRand(0))},
+		}, WithFailoverPolicy(FailoverNone))
+		selector := route.NewSelector()
+
+		first := selector.SelectServer("key01")
+		pool := poolOfServer(first)
+		assert.Equal(t, []ServerID{pool*10 + 1, pool*10 + 2}, selector.SelectForDelete("key01"))
+
+		selector.SetFailedServer(first)
+		assert.Equal(t, true, selector.HasNextAvailableServer())
+
+		second := selector.SelectServer("key01")
+		assert.Equal(t, pool, poolOfServer(second))
+
+		selector.SetFailedServer(second)
+		assert.Equal(t, false, selector.HasNextAvailableServer())
+		assert.Equal(t, pool, poolOfServer(selector.SelectServer("key01")))
+
+		// keys of other pools still have available servers
+		keySelector := selector.(KeyFailoverSelector)
+		assert.Equal(t, false, keySelector.HasNextAvailableServerForKey("key01"))
+		for i := 0; i < 100; i++ {
+			key := fmt.Sprintf("key:%d", i)
+			if poolOfServer(selector.SelectServer(key)) != pool {
+				assert.Equal(t, true, keySelector.HasNextAvailableServerForKey(key))
+			}
+		}
+	})
+
+	t.Run("server shared between pools notified once", func(t *testing.T) {
+		stats := newComposedStats()
+		route := NewShardedPoolRoute([]Pool{
+			{Name: "pool-a", Route: NewReplicatedRoute([]ServerID{11, 12}, stats, alwaysRand(RandomMaxValues-1))},
+			{Name: "pool-b", Route: NewShardedRoute([]ServerID{12, 21}, stats)},
+			{Name: "pool-c", Route: NewReplicatedPoolRoute([]Pool{
+				{Name: "zone-a", Route: NewRendezvousRoute([]ServerID{12, 31}, stats)},
+			}, stats)},
+		})
+		selector := route.NewSelector()
+
+		selector.SetFailedServer(12)
+		selector.SetFailedServer(12)
+		assert.Equal(t, 1, len(stats.NotifyServerFailedCalls()))
+		assert.Equal(t, ServerID(12), stats.NotifyServerFailedCalls()[0].Server)
+
+		// the server is failed in all pools
+		for i := 0; i < 100; i++ {
+			assert.NotEqual(t, ServerID(12), selector.SelectServer(fmt.Sprintf("key:%d", i)))
+		}
+		assert.Equal(t, true, selector.HasNextAvailableServer())
+
+		selector.SetFailedServer(21)
+		assert.Equal(t, 2, len(stats.NotifyServerFailedCalls()))
+	})
+
+	t.Run("reset child selectors", func(t *testing.T) {
+		r := newShardedOfReplicatedTest()
+		r.selector.SelectServer("key01")
+		r.selector.Reset()
+		r.selector.SelectServer("key01")
+
+		// computes memory weights again after reset
+		assert.Equal(t, 4, len(r.stats.GetMemUsageCalls()))
+	})
+
+	t.Run("invalid config", func(t *testing.T) {
+		stats := newComposedStats()
+		assert.Panics(t, func() {
+			NewShardedPoolRoute(nil)
+		})
+		assert.Panics(t, func() {
+			NewShardedPoolRoute([]Pool{{Name: "pool-a"}})
+		})
+		assert.Panics(t, func() {
+			NewShardedPoolRoute([]Pool{
+				{Name: "pool-a", Route: NewReplicatedRoute([]ServerID{11}, stats)},
+				{Name: "pool-a", Route: NewReplicatedRoute([]ServerID{21}, stats)},
+			})
+		})
+	})
+}
+
+func TestReplicatedPoolRoute(t *testing.T) {
+	// replicated between 2 zones, each zone sharded between 2 servers
+	newRoute := func(stats *ServerStatsMock, randVal uint64) Route {
+		return NewReplicatedPoolRoute([]Pool{
+			{Name: "zone-a", Route: NewShardedRoute([]ServerID{11, 12}, stats)},
+			{Name: "zone-b", Route: NewShardedRoute([]ServerID{21, 22}, stats)},
+		}, stats, alwaysRand(randVal))
+	}
+
+	t.Run("choose zone for the pipeline", func(t *testing.T) {
+		stats := newComposedStats()
+
+		selector := newRoute(stats, 0).NewSelector()
+		for i := 0; i < 100; i++ {
+			server := selector.SelectServer(fmt.Sprintf("key:%d", i))
+			assert.Equal(t, ServerID(1), poolOfServer(server))
+		}
+
+		selector = newRoute(stats, RandomMaxValues-1).NewSelector()
+		for i := 0; i < 100; i++ {
+			server := selector.SelectServer(fmt.Sprintf("key:%d", i))
+			assert.Equal(t, ServerID(2), poolOfServer(server))
+		}
+
+		assert.Equal(t, []ServerID{11, 12, 21, 22}, newRoute(stats, 0).AllServerIDs())
+	})
+
+	t.Run("delete on all zones", func(t *testing.T) {
+		stats := newComposedStats()
+		selector := newRoute(stats, 0).NewSelector()
+
+		deleted := selector.SelectForDelete("key01")
+		assert.Equal(t, 4, len(deleted))
+		assert.Equal(t, ServerID(1), poolOfServer(deleted[0]))
+		assert.Equal(t, ServerID(1), poolOfServer(deleted[1]))
+		assert.Equal(t, ServerID(2), poolOfServer(deleted[2]))
+		assert.Equal(t, ServerID(2), poolOfServer(deleted[3]))
+	})
+
+	t.Run("failover to other zone", func(t *testing.T) {
+		stats := newComposedStats()
+		selector := newRoute(stats, 0).NewSelector()
+
+		first := selector.SelectServer("key01")
+		assert.Equal(t, ServerID(1), poolOfServer(first))
+
+		// failover inside the zone
+		selector.SetFailedServer(first)
+		assert.Equal(t, true, selector.HasNextAvailableServer())
+		second := selector.SelectServer("key01")
+		assert.Equal(t, ServerID(1), poolOfServer(second))
+		assert.NotEqual(t, first, second)
+
+		// zone exhausted
+		selector.SetFailedServer(second)
+		assert.Equal(t, true, selector.HasNextAvailableServer())
+		third := selector.SelectServer("key01")
+		assert.Equal(t, ServerID(2), poolOfServer(third))
+
+		for _, server := range selector.SelectForDelete("key01") {
+			assert.Equal(t, ServerID(2), poolOfServer(server))
+		}
+
+		selector.SetFailedServer(21)
+		selector.SetFailedServer(22)
+		assert.Equal(t, false, selector.HasNextAvailableServer())
+	})
+}
+
+func TestMemcache_Composed_Route_Server_IDs(t *testing.T) {
+	stats := newComposedStats()
+	route := NewShardedPoolRoute([]Pool{
+		{Name: "pool-a", Route: NewReplicatedRoute([]ServerID{11, 12}, stats)},
+		{Name: "pool-b", Route: NewReplicatedRoute([]ServerID{21, 22}, stats)},
+	})
+
+	newServers := func(ids ...ServerID) []SimpleServerConfig {
+		var result []SimpleServerConfig
+		for _, id := range ids {
+			result = append(result, SimpleServerConfig{ID: id, Host: "localhost", Port: 11211})
+		}
+		return result
+	}
+	newFunc := func(conf SimpleServerConfig) memproxy.Memcache {
+		return nil
+	}
+
+	mc, err := New[SimpleServerConfig](Config[SimpleServerConfig]{
+		Servers: newServers(11, 12, 21, 22),
+		Route:   route,
+	}, newFunc)
+	assert.Equal(t, nil, err)
+	assert.NotNil(t, mc)
+
+	mc, err = New[SimpleServerConfig](Config[SimpleServerConfig]{
+		Servers: newServers(11, 12, 21),
+		Route:   route,
+	}, newFunc)
+	assert.Equal(t, errors.New("proxy: server id '22' not in server list"), err)
+	assert.Nil(t, mc)
+}
@@ -40,6 +40,28 @@ type Selector interface {
 	Reset()
 }
 
+// SkippedDeleteSelector is an optional interface of Selector, for recording the deletes
+// of the failed servers to the InvalidationQueue
+type SkippedDeleteSelector interface {
+	// SkippedForDelete returns the failed servers that might contain the key but not chosen by SelectForDelete
+	SkippedForDelete(key string) []ServerID
+}
+
+// KeyFailoverSelector is an optional interface of Selector, for routes that map keys to different groups
+// of servers (e.g. NewPrefixRouter), whether a failed lease get can be retried depends on the group of its key
+type KeyFailoverSelector interface {
+	// HasNextAvailableServerForKey check if next available server of the key ready to be fallback to
+	HasNextAvailableServerForKey(key string) bool
+}
+
+// WarmUpSelector is an optional interface of Selector, for filling cold servers (e.g. newly added or restarted)
+// from their warm replicas instead of from the backing database
+type WarmUpSelector interface {
+	// SelectWarmServer returns the server used for reading the keys missed on the server,
+	// ok = false if the server is not cold or there is no warm replica
+	SelectWarmServer(key string, server ServerID) (warm ServerID, ok bool)
+}
+
 // Config ...
 type Config[S ServerConfig] struct {
 	Servers []S
@@ -0,0 +1,357 @@
+package proxy
+
+import (
+	"bytes"
+	"errors"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/QuangTung97/go-memcache/memcache"
+
+	"github.com/QuangTung97/memproxy"
+)
+
+type configWatcherConfig struct {
+	pollInterval time.Duration
+
+	errorLogger  func(err error)
+	reloadLogger func(conf *FileConfig)
+
+	newClient      func(conf SimpleServerConfig, numConns int) (memproxy.Memcache, error)
+	newStatsClient func(conf SimpleServerConfig) StatsClient
+
+	statsOptions    []SimpleStatsOption
+	memcacheOptions []MemcacheOption
+}
+
+// ConfigWatcherOption ...
+type ConfigWatcherOption func(conf *configWatcherConfig)
+
+// WithConfigPollInterval configures the duration between file checks, default 5 seconds.
+// Zero disables watching, the config can still be reloaded by calling ConfigWatcher.Reload
+func WithConfigPollInterval(d time.Duration) ConfigWatcherOption {
+	return func(conf *configWatcherConfig) {
+		conf.pollInterval = d
+	}
+}
+
+// WithConfigErrorLogger configures the logger of reload errors, the current config is kept when reload failed
+func WithConfigErrorLogger(logger func(err error)) ConfigWatcherOption {
+	return func(conf *configWatcherConfig) {
+		conf.errorLogger = logger
+	}
+}
+
+// WithConfigReloadLogger configures the function called after a new config is applied
+func WithConfigReloadLogger(logger func(conf *FileConfig)) ConfigWatcherOption {
+	return func(conf *configWatcherConfig) {
+		conf.reloadLogger = logger
+	}
+}
+
+// WithConfigNewClientFunc configures the function creating memcached clients,
+// default using memcache.New and memproxy.NewPlainMemcache.
+// When the function returns an error, the reload fails and the current config is kept
+func WithConfigNewClientFunc(
+	fn func(conf SimpleServerConfig, numConns int) (memproxy.Memcache, error),
+) ConfigWatcherOption {
+	return func(conf *configWatcherConfig) {
+		conf.newClient = fn
+	}
+}
+
+// WithConfigStatsClientFunc configures the function creating stats clients, default NewSimpleStatsClient
+func WithConfigStatsClientFunc(fn func(conf SimpleServerConfig) StatsClient) ConfigWatcherOption {
+	return func(conf *configWatcherConfig) {
+		conf.newStatsClient = fn
+	}
+}
+
+// WithConfigStatsOptions ...
+func WithConfigStatsOptions(options ...SimpleStatsOption) ConfigWatcherOption {
+	return func(conf *configWatcherConfig) {
+		conf.statsOptions = options
+	}
+}
+
+// WithConfigMemcacheOptions ...
+func WithConfigMemcacheOptions(options ...MemcacheOption) ConfigWatcherOption {
+	return func(conf *configWatcherConfig) {
+		conf.memcacheOptions = options
+	}
+}
+
+func computeConfigWatcherConfig(options ...ConfigWatcherOption) *configWatcherConfig {
+	conf := &configWatcherConfig{
+		pollInterval: 5 * time.Second,
+		errorLogger: func(err error) {
+			log.Println("[ERROR] ConfigWatcher:", err)
+		},
+		reloadLogger: func(conf *FileConfig) {
+		},
+		newClient: func(conf SimpleServerConfig, numConns int) (memproxy.Memcache, error) {
+			client, err := memcache.New(conf.Address(), numConns)
+			if err != nil {
+				return nil, err
+			}
+			return memproxy.NewPlainMemcache(client), nil
+		},
+		newStatsClient: NewSimpleStatsClient,
+	}
+	for _, fn := range options {
+		fn(conf)
+	}
+	return conf
+}
+
+// ConfigWatcher builds a Memcache from a JSON or YAML config file (see FileConfig and LoadFileConfig),
+// and watches the file to apply the changes at runtime using UpdateConfig.
+// Invalid configs are rejected and the current config is kept
+type ConfigWatcher struct {
+	path string
+	conf *configWatcherConfig
+
+	mc    *Memcache
+	stats *SimpleServerStats
+
+	mut      sync.Mutex
+	current  *FileConfig
+	lastData []byte
+
+	// clients created before calling New or UpdateConfig, taken by newClient
+	preparedClients map[SimpleServerConfig]memproxy.Memcache
+
+	closed chan struct{}
+	wg     sync.WaitGroup
+}
+
+// NewConfigWatcher loads the config file and creates the Memcache and the SimpleServerStats
+func NewConfigWatcher(path string, options ...ConfigWatcherOption) (*ConfigWatcher, error) {
+	conf := computeConfigWatcherConfig(options...)
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	fileConf, err := parseFileConfigOfPath(path, data)
+	if err != nil {
+		return nil, err
+	}
+
+	w := &ConfigWatcher{
+		path: path,
+		conf: conf,
+
+		current:  fileConf,
+		lastData: data,
+
+		closed: make(chan struct{}),
+	}
+
+	statsOptions := append([]SimpleStatsOption{
+		WithSimpleStatsCheckDuration(fileConf.getCheckInterval()),
+	}, conf.statsOptions...)
+	w.stats = NewSimpleServerStats[SimpleServerConfig](fileConf.Servers, conf.newStatsClient, statsOptions...)
+
+	route, err := fileConf.buildRoute(w.stats)
+	if err != nil {
+		w.stats.Shutdown()
+		return nil, err
+	}
+
+	mcOptions := append([]MemcacheOption{
+		WithMemcacheSimpleStats(w.stats),
+	}, conf.memcacheOptions...)
+
+	if err := w.prepareClients(nil, fileConf); err != nil {
+		w.stats.Shutdown()
+		return nil, err
+	}
+
+	w.mc, err = New[SimpleServerConfig](Config[SimpleServerConfig]{
+		Servers: fileConf.Servers,
+		Route:   route,
+	}, w.newClient, mcOptions...)
+	w.closePreparedClients()
+	if err != nil {
+		w.stats.Shutdown()
+		return nil, err
+	}
+
+	if conf.pollInterval > 0 {
+		w.wg.Add(1)
+		go func() {
+			defer w.wg.Done()
+			w.watch()
+		}()
+	}
+
+	return w, nil
+}
+
+// prepareClients creates the clients of the new servers and the servers with changed config,
+// because Memcache creates the clients without returning errors.
+// Called with w.mut locked (or inside NewConfigWatcher)
+func (w *ConfigWatcher) prepareClients(current, fileConf *FileConfig) error {
+	existed := map[SimpleServerConfig]struct{}{}
+	if current != nil {
+		for _, server := range current.Servers {
+			existed[server] = struct{}{}
+		}
+	}
+
+	numConns := fileConf.getNumConnsPerServer()
+	w.preparedClients = map[SimpleServerConfig]memproxy.Memcache{}
+
+	for _, server := range fileConf.Servers {
+		if _, ok := existed[server]; ok {
+			continue
+		}
+
+		client, err := w.conf.newClient(server, numConns)
+		if err != nil {
+			w.closePreparedClients()
+			return err
+		}
+		w.preparedClients[server] = client
+	}
+	return nil
+}
+
+// closePreparedClients closes the prepared clients not taken by the Memcache
+func (w *ConfigWatcher) closePreparedClients() {
+	for _, client := range w.preparedClients {
+		_ = client.Close()
+	}
+	w.preparedClients = nil
+}
+
+// newClient is called with w.mut locked (or inside NewConfigWatcher)
+func (w *ConfigWatcher) newClient(conf SimpleServerConfig) memproxy.Memcache {
+	client, ok := w.preparedClients[conf]
+	if !ok {
+		panic("proxy config: client of the server is not prepared")
+	}
+	delete(w.preparedClients, conf)
+	return client
+}
+
+func (w *ConfigWatcher) watch() {
+	ticker := time.NewTicker(w.conf.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.closed:
+			return
+		case <-ticker.C:
+			if err := w.Reload(); err != nil {
+				w.conf.errorLogger(err)
+			}
+		}
+	}
+}
+
+// Memcache returns the Memcache built from the config file
+func (w *ConfigWatcher) Memcache() *Memcache {
+	return w.mc
+}
+
+// Stats returns the SimpleServerStats of the servers in the config file
+func (w *ConfigWatcher) Stats() *SimpleServerStats {
+	return w.stats
+}
+
+// Config returns the currently applied config
+func (w *ConfigWatcher) Config() *FileConfig {
+	w.mut.Lock()
+	defer w.mut.Unlock()
+	return w.current
+}
+
+// Reload reads the config file and applies it if the file content changed.
+// The current config is kept if the new config is invalid.
+// The number of connections per server is only applied to the new clients
+func (w *ConfigWatcher) Reload() error {
+	w.mut.Lock()
+	defer w.mut.Unlock()
+
+	select {
+	case <-w.closed:
+		return errors.New("proxy config: watcher already closed")
+	default:
+	}
+
+	data, err := os.ReadFile(w.path)
+	if err != nil {
+		return err
+	}
+
+	if bytes.Equal(data, w.lastData) {
+		return nil
+	}
+
+	fileConf, err := parseFileConfigOfPath(w.path, data)
+	if err != nil {
+		return err
+	}
+
+	if err := w.prepareClients(w.current, fileConf); err != nil {
+		return err
+	}
+	defer w.closePreparedClients()
+
+	// the stats are updated before building the route, for the routes using the stats of the new servers
+	// when created (e.g. rendezvous with memory weights)
+	if err := w.stats.UpdateServers(fileConf.Servers); err != nil {
+		return err
+	}
+
+	route, err := fileConf.buildRoute(w.stats)
+	if err == nil {
+		err = UpdateConfig(w.mc, Config[SimpleServerConfig]{
+			Servers: fileConf.Servers,
+			Route:   route,
+		})
+	}
+	if err != nil {
+		w.restoreStats()
+		return err
+	}
+
+	w.stats.SetCheckDuration(fileConf.getCheckInterval())
+
+	w.current = fileConf
+	w.lastData = data
+
+	w.conf.reloadLogger(fileConf)
+	return nil
+}
+
+// restoreStats restores the servers of the stats to the current config after a failed reload
+func (w *ConfigWatcher) restoreStats() {
+	if err := w.stats.UpdateServers(w.current.Servers); err != nil {
+		w.conf.errorLogger(err)
+	}
+}
+
+// Close stops watching, closes the Memcache and shutdowns the stats
+func (w *ConfigWatcher) Close() error {
+	w.mut.Lock()
+	select {
+	case <-w.closed:
+		w.mut.Unlock()
+		return nil
+	default:
+		close(w.closed)
+	}
+	w.mut.Unlock()
+
+	w.wg.Wait()
+
+	w.stats.Shutdown()
+	return w.mc.Close()
+}
@@ -0,0 +1,301 @@
+package proxy
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/QuangTung97/memproxy"
+)
+
+type configWatcherTest struct {
+	path string
+
+	mut            sync.Mutex
+	newClientArgs  []SimpleServerConfig
+	numConnsArgs   []int
+	statsClientNew []SimpleServerConfig
+	reloaded       []*FileConfig
+	errors         []error
+
+	newClientErr *newClientError
+
+	updateTest *updateConfigTest
+	watcher    *ConfigWatcher
+}
+
+type newClientError struct {
+	port uint16
+	err  error
+}
+
+func (c *configWatcherTest) writeConfig(t *testing.T, data string) {
+	err := os.WriteFile(c.path, []byte(data), 0o600)
+	assert.Equal(t, nil, err)
+}
+
+func (c *configWatcherTest) getNewClientArgs() ([]SimpleServerConfig, []int) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	return append([]SimpleServerConfig(nil), c.newClientArgs...), append([]int(nil), c.numConnsArgs...)
+}
+
+func (c *configWatcherTest) getReloadCount() int {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	return len(c.reloaded)
+}
+
+func (c *configWatcherTest) getErrors() []error {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	return append([]error(nil), c.errors...)
+}
+
+func newConfigWatcherTest(t *testing.T, data string, options ...ConfigWatcherOption) *configWatcherTest {
+	return newConfigWatcherTestWithFile(t, "memproxy.json", data, options...)
+}
+
+func newConfigWatcherTestWithFile(
+	t *testing.T, fileName string, data string, options ...ConfigWatcherOption,
+) *configWatcherTest {
+	c := &configWatcherTest{
+		path:       filepath.Join(t.TempDir(), fileName),
+		updateTest: &updateConfigTest{},
+	}
+	c.writeConfig(t, data)
+
+	opts := []ConfigWatcherOption{
+		WithConfigPollInterval(0),
+		WithConfigNewClientFunc(func(conf SimpleServerConfig, numConns int) (memproxy.Memcache, error) {
+			c.mut.Lock()
+			c.newClientArgs = append(c.newClientArgs, conf)
+			c.numConnsArgs = append(c.numConnsArgs, numConns)
+			newClientErr := c.newClientErr
+			c.mut.Unlock()
+
+			if newClientErr != nil && conf.Port == newClientErr.port {
+				return nil, newClientErr.err
+			}
+			return c.updateTest.newClient(conf), nil
+		}),
+		WithConfigStatsClientFunc(func(conf SimpleServerConfig) StatsClient {
+			c.mut.Lock()
+			c.statsClientNew = append(c.statsClientNew, conf)
+			c.mut.Unlock()
+			return &StatsClientMock{
+				GetMemUsageFunc: func() (uint64, error) {
+					return uint64(conf.Port), nil
+				},
+				CloseFunc: func() error { return nil },
+			}
+		}),
+		WithConfigReloadLogger(func(conf *FileConfig) {
+			c.mut.Lock()
+			c.reloaded = append(c.reloaded, conf)
+			c.mut.Unlock()
+		}),
+		WithConfigErrorLogger(func(err error) {
+			c.mut.Lock()
+			c.errors = append(c.errors, err)
+			c.mut.Unlock()
+		}),
+	}
+	opts = append(opts, options...)
+
+	w, err := NewConfigWatcher(c.path, opts...)
+	assert.Equal(t, nil, err)
+	c.watcher = w
+
+	t.Cleanup(func() {
+		_ = w.Close()
+	})
+
+	return c
+}
+
+const watcherConfig1 = `{
+  "servers": [{"id": 1, "host": "localhost", "port": 11211}],
+  "num_conns_per_server": 2,
+  "route": {"type": "replicated", "servers": [1]}
+}`
+
+const watcherConfig2 = `{
+  "servers": [
+    {"id": 1, "host": "localhost", "port": 11211},
+    {"id": 2, "host": "localhost", "port": 11212}
+  ],
+  "num_conns_per_server": 4,
+  "route": {"type": "sharded", "servers": [1, 2]},
+  "stats": {"check_interval": "1m"}
+}`
+
+func TestConfigWatcher(t *testing.T) {
+	t.Run("build memcache", func(t *testing.T) {
+		c := newConfigWatcherTest(t, watcherConfig1)
+
+		args, numConns := c.getNewClientArgs()
+		assert.Equal(t, []SimpleServerConfig{{ID: 1, Host: "localhost", Port: 11211}}, args)
+		assert.Equal(t, []int{2}, numConns)
+
+		assert.Equal(t, float64(11211), c.watcher.Stats().GetMemUsage(1))
+		assert.Equal(t, 30*time.Second, c.watcher.Stats().getCheckDuration())
+
+		client, err := c.watcher.Memcache().ServerMemcache(1)
+		assert.Equal(t, nil, err)
+		assert.Same(t, c.updateTest.clients[0], client)
+	})
+
+	t.Run("reload", func(t *testing.T) {
+		c := newConfigWatcherTest(t, watcherConfig1)
+
+		// not changed
+		assert.Equal(t, nil, c.watcher.Reload())
+		assert.Equal(t, 0, c.getReloadCount())
+
+		c.writeConfig(t, watcherConfig2)
+		assert.Equal(t, nil, c.watcher.Reload())
+		assert.Equal(t, 1, c.getReloadCount())
+
+		args, numConns := c.getNewClientArgs()
+		assert.Equal(t, []SimpleServerConfig{
+			{ID: 1, Host: "localhost", Port: 11211},
+			{ID: 2, Host: "localhost", Port: 11212},
+		}, args)
+		assert.Equal(t, []int{2, 4}, numConns)
+
+		assert.Equal(t, float64(11212), c.watcher.Stats().GetMemUsage(2))
+		assert.Equal(t, time.Minute, c.watcher.Stats().getCheckDuration())
+		assert.Equal(t, 2, len(c.watcher.Config().Servers))
+
+		assert.Equal(t, []ServerID{1, 2}, c.watcher.Memcache().state.Load().route.AllServerIDs())
+	})
+
+	t.Run("reload with stats of new servers", func(t *testing.T) {
+		c := newConfigWatcherTest(t, watcherConfig1)
+
+		c.writeConfig(t, `{
+  "servers": [
+    {"id": 1, "host": "localhost", "port": 11211},
+    {"id": 2, "host": "localhost", "port": 11212}
+  ],
+  "route": {"type": "rendezvous", "servers": [1, 2], "memory_scoring": "linear"}
+}`)
+		assert.Equal(t, nil, c.watcher.Reload())
+
+		// the memory weight of the new server is known when the route is built
+		counts := countShardedKeys(c.watcher.Memcache().state.Load().route.NewSelector(), 10000)
+		assert.Greater(t, counts[2], 4000)
+		assert.Greater(t, counts[1], 4000)
+	})
+
+	t.Run("reload yaml", func(t *testing.T) {
+		c := newConfigWatcherTestWithFile(t, "memproxy.yaml", `
+servers: [{id: 1, host: localhost, port: 11211}]
+route: {type: replicated, servers: [1]}
+`)
+		assert.Equal(t, 1, len(c.watcher.Config().Servers))
+
+		c.writeConfig(t, `
+servers:
+  - {id: 1, host: localhost, port: 11211}
+  - {id: 2, host: localhost, port: 11212}
+route: {type: sharded, servers: [1, 2]}
+stats: {check_interval: 1m}
+`)
+		assert.Equal(t, nil, c.watcher.Reload())
+		assert.Equal(t, 1, c.getReloadCount())
+		assert.Equal(t, time.Minute, c.watcher.Stats().getCheckDuration())
+		assert.Equal(t, []ServerID{1, 2}, c.watcher.Memcache().state.Load().route.AllServerIDs())
+	})
+
+	t.Run("invalid config rejected", func(t *testing.T) {
+		c := newConfigWatcherTest(t, watcherConfig1)
+
+		c.writeConfig(t, `{"servers": [{"id": 1, "host": "localhost", "port": 11211}], "route": {"type": "random"}}`)
+		err := c.watcher.Reload()
+		assert.Equal(t, `proxy config: route.type: unknown route type "random"`, err.Error())
+
+		assert.Equal(t, 0, c.getReloadCount())
+		assert.Equal(t, 1, len(c.watcher.Config().Servers))
+		assert.Equal(t, 1, len(c.updateTest.getNewArgs()))
+
+		c.writeConfig(t, watcherConfig2)
+		assert.Equal(t, nil, c.watcher.Reload())
+		assert.Equal(t, 1, c.getReloadCount())
+	})
+
+	t.Run("new client error", func(t *testing.T) {
+		c := newConfigWatcherTest(t, watcherConfig1)
+
+		c.mut.Lock()
+		c.newClientErr = &newClientError{port: 11213, err: errors.New("dial error")}
+		c.mut.Unlock()
+
+		c.writeConfig(t, `{
+  "servers": [
+    {"id": 1, "host": "localhost", "port": 11211},
+    {"id": 2, "host": "localhost", "port": 11212},
+    {"id": 3, "host": "localhost", "port": 11213}
+  ],
+  "route": {"type": "sharded", "servers": [1, 2, 3]}
+}`)
+		assert.Equal(t, errors.New("dial error"), c.watcher.Reload())
+
+		// current config is kept and the created client is closed
+		assert.Equal(t, 0, c.getReloadCount())
+		assert.Equal(t, 1, len(c.watcher.Config().Servers))
+		assert.Equal(t, []ServerID{1}, c.watcher.Memcache().state.Load().route.AllServerIDs())
+		assert.Equal(t, 2, len(c.updateTest.getNewArgs()))
+		assert.Equal(t, 0, c.updateTest.closeCount(0))
+		assert.Equal(t, 1, c.updateTest.closeCount(1))
+
+		c.mut.Lock()
+		c.newClientErr = nil
+		c.mut.Unlock()
+
+		c.writeConfig(t, watcherConfig2)
+		assert.Equal(t, nil, c.watcher.Reload())
+		assert.Equal(t, 1, c.getReloadCount())
+	})
+
+	t.Run("watch file", func(t *testing.T) {
+		c := newConfigWatcherTest(t, watcherConfig1, WithConfigPollInterval(5*time.Millisecond))
+
+		c.writeConfig(t, `{"servers": []}`)
+		assert.Eventually(t, func() bool {
+			return len(c.getErrors()) > 0
+		}, 2*time.Second, 5*time.Millisecond)
+		assert.Equal(t, "proxy config: servers: must not be empty", c.getErrors()[0].Error())
+
+		c.writeConfig(t, watcherConfig2)
+		assert.Eventually(t, func() bool {
+			return c.getReloadCount() == 1
+		}, 2*time.Second, 5*time.Millisecond)
+
+		assert.Equal(t, nil, c.watcher.Close())
+		assert.Equal(t, nil, c.watcher.Close())
+		assert.Equal(t, 1, c.updateTest.closeCount(0))
+		assert.Equal(t, 1, c.updateTest.closeCount(1))
+
+		assert.Equal(t, "proxy config: watcher already closed", c.watcher.Reload().Error())
+	})
+
+	t.Run("invalid initial config", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "memproxy.json")
+
+		_, err := NewConfigWatcher(path)
+		assert.True(t, os.IsNotExist(err))
+
+		err = os.WriteFile(path, []byte(`{"servers": [{"id": 1, "host": "localhost"}]}`), 0o600)
+		assert.Equal(t, nil, err)
+
+		_, err = NewConfigWatcher(path)
+		assert.Equal(t, "proxy config: servers[0]: port must not be zero", err.Error())
+	})
+}
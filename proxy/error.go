@@ -0,0 +1,26 @@
+package proxy
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// ServerError wraps the errors returned from a memcached server, with the id of that server
+type ServerError struct {
+	Server ServerID
+	Err    error
+}
+
+func (e *ServerError) Error() string {
+	return fmt.Sprintf("proxy: server id '%d': %v", e.Server, e.Err)
+}
+
+// Unwrap ...
+func (e *ServerError) Unwrap() error {
+	return e.Err
+}
+
+// ServerName returns the server id as a string, used by item.Error
+func (e *ServerError) ServerName() string {
+	return strconv.Itoa(int(e.Server))
+}
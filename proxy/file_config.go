@@ -0,0 +1,567 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Route types of RouteConfig
+const (
+	RouteTypeReplicated      = "replicated"
+	RouteTypeSharded         = "sharded"
+	RouteTypeRendezvous      = "rendezvous"
+	RouteTypeShardedPools    = "sharded_pools"
+	RouteTypeReplicatedPools = "replicated_pools"
+	RouteTypePrefix          = "prefix"
+)
+
+// Memory scoring functions of RouteConfig
+const (
+	MemoryScoringLinear = "linear"
+	MemoryScoringSqrt   = "sqrt"
+	MemoryScoringLog    = "log"
+)
+
+// Failover policies of RouteConfig
+const (
+	FailoverPolicyNextNode = "next_node"
+	FailoverPolicyNone     = "none"
+)
+
+// FileConfig describes the servers, pools, route and stats, usually loaded from a JSON or YAML file, e.g.
+//
+//	{
+//	  "servers": [
+//	    {"id": 1, "host": "localhost", "port": 11211},
+//	    {"id": 2, "host": "localhost", "port": 11212}
+//	  ],
+//	  "route": {"type": "replicated", "servers": [1, 2], "min_percent": 1.0, "memory_scoring": "linear"},
+//	  "stats": {"check_interval": "30s"}
+//	}
+type FileConfig struct {
+	Servers []SimpleServerConfig `json:"servers"`
+
+	// NumConnsPerServer is the number of connections of each new client, default 1
+	NumConnsPerServer int `json:"num_conns_per_server"`
+
+	// Pools are named routes that can be used by the route types: sharded_pools, replicated_pools and prefix
+	Pools []PoolConfig `json:"pools"`
+
+	Route RouteConfig `json:"route"`
+
+	Stats StatsConfig `json:"stats"`
+}
+
+// PoolConfig ...
+type PoolConfig struct {
+	Name string `json:"name"`
+
+	// Route MUST be a route type using servers: replicated, sharded or rendezvous
+	Route RouteConfig `json:"route"`
+}
+
+// RouteConfig describes a route, the used fields depend on the route type
+type RouteConfig struct {
+	Type string `json:"type"`
+
+	// Servers is used by the route types: replicated, sharded and rendezvous
+	Servers []ServerID `json:"servers"`
+
+	// Pools is the list of pool names used by the route types: sharded_pools and replicated_pools
+	Pools []string `json:"pools"`
+
+	// Prefixes and DefaultPool are used by the route type prefix
+	Prefixes    []PrefixConfig `json:"prefixes"`
+	DefaultPool string         `json:"default_pool"`
+
+	// MinPercent is used by the route types: replicated and replicated_pools, default 1.0
+	MinPercent float64 `json:"min_percent"`
+
+	// WarmUpRatio is used by the route type replicated, in range (0, 1], see WithWarmUpRatio.
+	// Default zero means warm up disabled
+	WarmUpRatio float64 `json:"warm_up_ratio"`
+
+	// MemoryScoring is used by the route types: replicated, replicated_pools and rendezvous.
+	// One of: linear (default), sqrt, log.
+	// The rendezvous route uses memory weights only when this field is set
+	MemoryScoring string `json:"memory_scoring"`
+
+	// VirtualNodes is used by the route types: sharded and sharded_pools, default DefaultVirtualNodes
+	VirtualNodes int `json:"virtual_nodes"`
+
+	// Failover is used by the route types: sharded and sharded_pools. One of: next_node (default), none
+	Failover string `json:"failover"`
+
+	// Weights is used by the route type rendezvous
+	Weights map[ServerID]float64 `json:"weights"`
+}
+
+// PrefixConfig ...
+type PrefixConfig struct {
+	Prefix string `json:"prefix"`
+	Pool   string `json:"pool"`
+}
+
+// StatsConfig ...
+type StatsConfig struct {
+	// CheckInterval is the duration between memory checks, default 30s
+	CheckInterval Duration `json:"check_interval"`
+}
+
+// Duration is a time.Duration encoded as a string in JSON, e.g. "30s"
+type Duration time.Duration
+
+// UnmarshalJSON ...
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("duration must be a string, e.g. \"30s\"")
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// MarshalJSON ...
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// ParseFileConfig parses and validates the JSON config, unknown fields are rejected
+func ParseFileConfig(data []byte) (*FileConfig, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+
+	conf := &FileConfig{}
+	if err := decoder.Decode(conf); err != nil {
+		return nil, fmt.Errorf("proxy config: invalid json: %w", err)
+	}
+
+	if err := conf.Validate(); err != nil {
+		return nil, err
+	}
+	return conf, nil
+}
+
+// ParseYAMLFileConfig parses and validates the YAML config, the fields are the same as the JSON config.
+// Unknown fields are rejected
+func ParseYAMLFileConfig(data []byte) (*FileConfig, error) {
+	var value any
+	if err := yaml.Unmarshal(data, &value); err != nil {
+		return nil, fmt.Errorf("proxy config: invalid yaml: %w", err)
+	}
+
+	jsonData, err := json.Marshal(yamlToJSONValue(value))
+	if err != nil {
+		return nil, fmt.Errorf("proxy config: invalid yaml: %w", err)
+	}
+	return ParseFileConfig(jsonData)
+}
+
+// yamlToJSONValue converts the maps with non-string keys (e.g. the server ids of weights) to be encoded as JSON
+func yamlToJSONValue(value any) any {
+	switch v := value.(type) {
+	case map[string]any:
+		result := make(map[string]any, len(v))
+		for key, elem := range v {
+			result[key] = yamlToJSONValue(elem)
+		}
+		return result
+	case map[any]any:
+		result := make(map[string]any, len(v))
+		for key, elem := range v {
+			result[fmt.Sprint(key)] = yamlToJSONValue(elem)
+		}
+		return result
+	case []any:
+		result := make([]any, 0, len(v))
+		for _, elem := range v {
+			result = append(result, yamlToJSONValue(elem))
+		}
+		return result
+	default:
+		return value
+	}
+}
+
+// LoadFileConfig reads the file and calls ParseYAMLFileConfig for the files with
+// the extension .yaml or .yml, otherwise calls ParseFileConfig
+func LoadFileConfig(path string) (*FileConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("proxy config: %w", err)
+	}
+	return parseFileConfigOfPath(path, data)
+}
+
+func parseFileConfigOfPath(path string, data []byte) (*FileConfig, error) {
+	switch filepath.Ext(path) {
+	case ".yaml", ".yml":
+		return ParseYAMLFileConfig(data)
+	default:
+		return ParseFileConfig(data)
+	}
+}
+
+func configError(path string, format string, args ...any) error {
+	return fmt.Errorf("proxy config: %s: %s", path, fmt.Sprintf(format, args...))
+}
+
+// Validate checks the config, the same checks are done by ParseFileConfig and ConfigWatcher.Reload
+func (c *FileConfig) Validate() error {
+	_, err := c.buildRoute(nopServerStats{})
+	return err
+}
+
+func (c *FileConfig) getNumConnsPerServer() int {
+	if c.NumConnsPerServer == 0 {
+		return 1
+	}
+	return c.NumConnsPerServer
+}
+
+func (c *FileConfig) getCheckInterval() time.Duration {
+	if c.Stats.CheckInterval == 0 {
+		return 30 * time.Second
+	}
+	return time.Duration(c.Stats.CheckInterval)
+}
+
+func (c *FileConfig) validateServers() (map[ServerID]struct{}, error) {
+	if len(c.Servers) == 0 {
+		return nil, configError("servers", "must not be empty")
+	}
+
+	if c.NumConnsPerServer < 0 {
+		return nil, configError("num_conns_per_server", "must not be negative")
+	}
+
+	if c.Stats.CheckInterval < 0 {
+		return nil, configError("stats.check_interval", "must not be negative")
+	}
+
+	servers := map[ServerID]struct{}{}
+	for i, server := range c.Servers {
+		path := fmt.Sprintf("servers[%d]", i)
+		if _, existed := servers[server.ID]; existed {
+			return nil, configError(path, "duplicated server id %d", server.ID)
+		}
+		if server.Host == "" {
+			return nil, configError(path, "host must not be empty")
+		}
+		if server.Port == 0 {
+			return nil, configError(path, "port must not be zero")
+		}
+		servers[server.ID] = struct{}{}
+	}
+	return servers, nil
+}
+
+func (c *FileConfig) buildRoute(stats ServerStats) (Route, error) {
+	servers, err := c.validateServers()
+	if err != nil {
+		return nil, err
+	}
+
+	b := &routeBuilder{
+		stats:   stats,
+		servers: servers,
+		pools:   map[string]Route{},
+	}
+
+	for i, pool := range c.Pools {
+		path := fmt.Sprintf("pools[%d]", i)
+		if pool.Name == "" {
+			return nil, configError(path, "name must not be empty")
+		}
+		if _, existed := b.pools[pool.Name]; existed {
+			return nil, configError(path, "duplicated pool name %q", pool.Name)
+		}
+
+		route, err := b.buildServerRoute(path+".route", pool.Route)
+		if err != nil {
+			return nil, err
+		}
+		b.pools[pool.Name] = route
+	}
+
+	return b.build("route", c.Route)
+}
+
+type routeBuilder struct {
+	stats ServerStats
+
+	//revive:disable-next-line:nested-structs
+	servers map[ServerID]struct{}
+	pools   map[string]Route
+}
+
+func (b *routeBuilder) build(path string, conf RouteConfig) (Route, error) {
+	switch conf.Type {
+	case RouteTypeShardedPools, RouteTypeReplicatedPools, RouteTypePrefix:
+		return b.buildPoolRoute(path, conf)
+	default:
+		return b.buildServerRoute(path, conf)
+	}
+}
+
+func (b *routeBuilder) checkServers(path string, conf RouteConfig) error {
+	if len(conf.Servers) == 0 {
+		return configError(path+".servers", "must not be empty")
+	}
+	for _, server := range conf.Servers {
+		if _, ok := b.servers[server]; !ok {
+			return configError(path+".servers", "server id %d not in server list", server)
+		}
+	}
+	return nil
+}
+
+func (b *routeBuilder) buildServerRoute(path string, conf RouteConfig) (Route, error) {
+	switch conf.Type {
+	case RouteTypeReplicated, RouteTypeSharded, RouteTypeRendezvous:
+	case RouteTypeShardedPools, RouteTypeReplicatedPools, RouteTypePrefix:
+		return nil, configError(path+".type", "route type %q can not be used in pools", conf.Type)
+	default:
+		return nil, configError(path+".type", "unknown route type %q", conf.Type)
+	}
+
+	if err := b.checkServers(path, conf); err != nil {
+		return nil, err
+	}
+
+	switch conf.Type {
+	case RouteTypeReplicated:
+		options, err := replicatedOptions(path, conf)
+		if err != nil {
+			return nil, err
+		}
+		return NewReplicatedRoute(conf.Servers, b.stats, options...), nil
+
+	case RouteTypeSharded:
+		options, err := shardedOptions(path, conf)
+		if err != nil {
+			return nil, err
+		}
+		return NewShardedRoute(conf.Servers, b.stats, options...), nil
+
+	default:
+		options, err := rendezvousOptions(path, conf, b.servers)
+		if err != nil {
+			return nil, err
+		}
+		return NewRendezvousRoute(conf.Servers, b.stats, options...), nil
+	}
+}
+
+func (b *routeBuilder) getPool(path string, name string) (Pool, error) {
+	route, ok := b.pools[name]
+	if !ok {
+		return Pool{}, configError(path, "pool %q not found", name)
+	}
+	return Pool{Name: name, Route: route}, nil
+}
+
+func (b *routeBuilder) getPools(path string, names []string) ([]Pool, error) {
+	if len(names) == 0 {
+		return nil, configError(path, "must not be empty")
+	}
+
+	pools := make([]Pool, 0, len(names))
+	existed := map[string]struct{}{}
+	for i, name := range names {
+		if _, ok := existed[name]; ok {
+			return nil, configError(fmt.Sprintf("%s[%d]", path, i), "duplicated pool %q", name)
+		}
+		existed[name] = struct{}{}
+
+		pool, err := b.getPool(fmt.Sprintf("%s[%d]", path, i), name)
+		if err != nil {
+			return nil, err
+		}
+		pools = append(pools, pool)
+	}
+	return pools, nil
+}
+
+func (b *routeBuilder) buildPoolRoute(path string, conf RouteConfig) (Route, error) {
+	if len(conf.Servers) > 0 {
+		return nil, configError(path+".servers", "must be empty for route type %q, use pools instead", conf.Type)
+	}
+
+	switch conf.Type {
+	case RouteTypeShardedPools:
+		pools, err := b.getPools(path+".pools", conf.Pools)
+		if err != nil {
+			return nil, err
+		}
+		options, err := shardedOptions(path, conf)
+		if err != nil {
+			return nil, err
+		}
+		return NewShardedPoolRoute(pools, options...), nil
+
+	case RouteTypeReplicatedPools:
+		pools, err := b.getPools(path+".pools", conf.Pools)
+		if err != nil {
+			return nil, err
+		}
+		options, err := replicatedOptions(path, conf)
+		if err != nil {
+			return nil, err
+		}
+		return NewReplicatedPoolRoute(pools, b.stats, options...), nil
+
+	default:
+		return b.buildPrefixRouter(path, conf)
+	}
+}
+
+func (b *routeBuilder) buildPrefixRouter(path string, conf RouteConfig) (Route, error) {
+	if conf.DefaultPool == "" {
+		return nil, configError(path+".default_pool", "must not be empty")
+	}
+	defaultPool, err := b.getPool(path+".default_pool", conf.DefaultPool)
+	if err != nil {
+		return nil, err
+	}
+
+	routes := make([]PrefixRoute, 0, len(conf.Prefixes))
+	existed := map[string]struct{}{}
+	for i, prefix := range conf.Prefixes {
+		prefixPath := fmt.Sprintf("%s.prefixes[%d]", path, i)
+		if prefix.Prefix == "" {
+			return nil, configError(prefixPath, "prefix must not be empty")
+		}
+		if _, ok := existed[prefix.Prefix]; ok {
+			return nil, configError(prefixPath, "duplicated prefix %q", prefix.Prefix)
+		}
+		existed[prefix.Prefix] = struct{}{}
+
+		pool, err := b.getPool(prefixPath+".pool", prefix.Pool)
+		if err != nil {
+			return nil, err
+		}
+		routes = append(routes, PrefixRoute{Prefix: prefix.Prefix, Route: pool.Route})
+	}
+
+	return NewPrefixRouter(routes, defaultPool.Route), nil
+}
+
+func memoryScoringFunc(path string, name string) (func(mem float64) float64, error) {
+	switch name {
+	case "", MemoryScoringLinear:
+		return func(mem float64) float64 {
+			return mem
+		}, nil
+	case MemoryScoringSqrt:
+		return math.Sqrt, nil
+	case MemoryScoringLog:
+		return math.Log1p, nil
+	default:
+		return nil, configError(path+".memory_scoring", "unknown memory scoring %q", name)
+	}
+}
+
+func replicatedOptions(path string, conf RouteConfig) ([]ReplicatedRouteOption, error) {
+	var options []ReplicatedRouteOption
+
+	if conf.MinPercent != 0 {
+		if conf.MinPercent < 0 || conf.MinPercent >= 100 {
+			return nil, configError(path+".min_percent", "must be in range (0, 100), got %v", conf.MinPercent)
+		}
+		options = append(options, WithMinPercentage(conf.MinPercent))
+	}
+
+	if conf.WarmUpRatio != 0 {
+		if conf.WarmUpRatio < 0 || conf.WarmUpRatio > 1 {
+			return nil, configError(path+".warm_up_ratio", "must be in range (0, 1], got %v", conf.WarmUpRatio)
+		}
+		options = append(options, WithWarmUpRatio(conf.WarmUpRatio))
+	}
+
+	scoreFunc, err := memoryScoringFunc(path, conf.MemoryScoring)
+	if err != nil {
+		return nil, err
+	}
+	options = append(options, WithMemoryScoringFunc(scoreFunc))
+
+	return options, nil
+}
+
+func shardedOptions(path string, conf RouteConfig) ([]ShardedRouteOption, error) {
+	var options []ShardedRouteOption
+
+	if conf.VirtualNodes < 0 {
+		return nil, configError(path+".virtual_nodes", "must not be negative")
+	}
+	if conf.VirtualNodes > 0 {
+		options = append(options, WithVirtualNodes(conf.VirtualNodes))
+	}
+
+	switch conf.Failover {
+	case "", FailoverPolicyNextNode:
+	case FailoverPolicyNone:
+		options = append(options, WithFailoverPolicy(FailoverNone))
+	default:
+		return nil, configError(path+".failover", "unknown failover policy %q", conf.Failover)
+	}
+
+	return options, nil
+}
+
+func rendezvousOptions(
+	path string, conf RouteConfig, servers map[ServerID]struct{},
+) ([]RendezvousRouteOption, error) {
+	var options []RendezvousRouteOption
+
+	if len(conf.Weights) > 0 {
+		if conf.MemoryScoring != "" {
+			return nil, configError(path, "weights and memory_scoring can not be used together")
+		}
+		for server, w := range conf.Weights {
+			if _, ok := servers[server]; !ok {
+				return nil, configError(path+".weights", "server id %d not in server list", server)
+			}
+			if w <= 0 {
+				return nil, configError(path+".weights", "weight of server id %d must be positive", server)
+			}
+		}
+		options = append(options, WithRendezvousWeights(conf.Weights))
+	}
+
+	if conf.MemoryScoring != "" {
+		scoreFunc, err := memoryScoringFunc(path, conf.MemoryScoring)
+		if err != nil {
+			return nil, err
+		}
+		options = append(options, WithRendezvousMemoryWeights(scoreFunc))
+	}
+
+	return options, nil
+}
+
+// nopServerStats is used for validating configs
+type nopServerStats struct {
+}
+
+func (nopServerStats) IsServerFailed(ServerID) bool {
+	return false
+}
+
+func (nopServerStats) NotifyServerFailed(ServerID) {
+}
+
+func (nopServerStats) GetMemUsage(ServerID) float64 {
+	return 0
+}
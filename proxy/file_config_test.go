@@ -0,0 +1,270 @@
+package proxy
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testFileConfig = `{
+  "servers": [
+    {"id": 11, "host": "localhost", "port": 11211},
+    {"id": 12, "host": "localhost", "port": 11212},
+    {"id": 21, "host": "localhost", "port": 11221},
+    {"id": 22, "host": "localhost", "port": 11222}
+  ],
+  "num_conns_per_server": 3,
+  "pools": [
+    {
+      "name": "pool-a",
+      "route": {"type": "replicated", "servers": [11, 12], "min_percent": 2, "memory_scoring": "sqrt"}
+    },
+    {"name": "pool-b", "route": {"type": "rendezvous", "servers": [21, 22], "weights": {"21": 2}}}
+  ],
+  "route": {"type": "sharded_pools", "pools": ["pool-a", "pool-b"], "virtual_nodes": 40, "failover": "none"},
+  "stats": {"check_interval": "10s"}
+}`
+
+func TestParseFileConfig(t *testing.T) {
+	t.Run("normal", func(t *testing.T) {
+		conf, err := ParseFileConfig([]byte(testFileConfig))
+		assert.Equal(t, nil, err)
+
+		assert.Equal(t, 4, len(conf.Servers))
+		assert.Equal(t, SimpleServerConfig{ID: 21, Host: "localhost", Port: 11221}, conf.Servers[2])
+		assert.Equal(t, 3, conf.getNumConnsPerServer())
+		assert.Equal(t, 10*time.Second, conf.getCheckInterval())
+		assert.Equal(t, map[ServerID]float64{21: 2}, conf.Pools[1].Route.Weights)
+
+		route, err := conf.buildRoute(newComposedStats())
+		assert.Equal(t, nil, err)
+		assert.Equal(t, []ServerID{11, 12, 21, 22}, route.AllServerIDs())
+	})
+
+	t.Run("defaults", func(t *testing.T) {
+		conf, err := ParseFileConfig([]byte(`{
+  "servers": [{"id": 1, "host": "localhost", "port": 11211}],
+  "route": {"type": "replicated", "servers": [1]}
+}`))
+		assert.Equal(t, nil, err)
+		assert.Equal(t, 1, conf.getNumConnsPerServer())
+		assert.Equal(t, 30*time.Second, conf.getCheckInterval())
+	})
+
+	t.Run("prefix router", func(t *testing.T) {
+		conf, err := ParseFileConfig([]byte(`{
+  "servers": [
+    {"id": 1, "host": "localhost", "port": 11211},
+    {"id": 2, "host": "localhost", "port": 11212}
+  ],
+  "pools": [
+    {"name": "sessions", "route": {"type": "sharded", "servers": [1]}},
+    {"name": "others", "route": {"type": "replicated", "servers": [2], "memory_scoring": "log"}}
+  ],
+  "route": {"type": "prefix", "prefixes": [{"prefix": "sessions:", "pool": "sessions"}], "default_pool": "others"}
+}`))
+		assert.Equal(t, nil, err)
+
+		route, err := conf.buildRoute(newComposedStats())
+		assert.Equal(t, nil, err)
+
+		selector := route.NewSelector()
+		assert.Equal(t, ServerID(1), selector.SelectServer("sessions:user01"))
+		assert.Equal(t, ServerID(2), selector.SelectServer("users:user01"))
+	})
+
+	const server = `"servers": [{"id": 1, "host": "localhost", "port": 11211}]`
+
+	invalidCases := []struct {
+		name string
+		data string
+		err  string
+	}{
+		{
+			name: "invalid json",
+			data: `{"servers": `,
+			err:  "proxy config: invalid json: unexpected EOF",
+		},
+		{
+			name: "unknown field",
+			data: `{` + server + `, "routes": {}}`,
+			err:  `proxy config: invalid json: json: unknown field "routes"`,
+		},
+		{
+			name: "empty servers",
+			data: `{"route": {"type": "replicated", "servers": [1]}}`,
+			err:  "proxy config: servers: must not be empty",
+		},
+		{
+			name: "duplicated server id",
+			data: `{"servers": [{"id": 1, "host": "a", "port": 1}, {"id": 1, "host": "b", "port": 2}]}`,
+			err:  "proxy config: servers[1]: duplicated server id 1",
+		},
+		{
+			name: "empty host",
+			data: `{"servers": [{"id": 1, "port": 1}]}`,
+			err:  "proxy config: servers[0]: host must not be empty",
+		},
+		{
+			name: "invalid duration",
+			data: `{` + server + `, "stats": {"check_interval": 30}}`,
+			err:  `proxy config: invalid json: duration must be a string, e.g. "30s"`,
+		},
+		{
+			name: "unknown route type",
+			data: `{` + server + `, "route": {"type": "random"}}`,
+			err:  `proxy config: route.type: unknown route type "random"`,
+		},
+		{
+			name: "route server not in server list",
+			data: `{` + server + `, "route": {"type": "sharded", "servers": [1, 2]}}`,
+			err:  "proxy config: route.servers: server id 2 not in server list",
+		},
+		{
+			name: "route servers empty",
+			data: `{` + server + `, "route": {"type": "replicated"}}`,
+			err:  "proxy config: route.servers: must not be empty",
+		},
+		{
+			name: "invalid min percent",
+			data: `{` + server + `, "route": {"type": "replicated", "servers": [1], "min_percent": 120}}`,
+			err:  "proxy config: route.min_percent: must be in range (0, 100), got 120",
+		},
+		{
+			name: "invalid warm up ratio",
+			data: `{` + server + `, "route": {"type": "replicated", "servers": [1], "warm_up_ratio": 2}}`,
+			err:  "proxy config: route.warm_up_ratio: must be in range (0, 1], got 2",
+		},
+		{
+			name: "unknown memory scoring",
+			data: `{` + server + `, "route": {"type": "replicated", "servers": [1], "memory_scoring": "cubic"}}`,
+			err:  `proxy config: route.memory_scoring: unknown memory scoring "cubic"`,
+		},
+		{
+			name: "unknown failover",
+			data: `{` + server + `, "route": {"type": "sharded", "servers": [1], "failover": "random"}}`,
+			err:  `proxy config: route.failover: unknown failover policy "random"`,
+		},
+		{
+			name: "invalid weight",
+			data: `{` + server + `, "route": {"type": "rendezvous", "servers": [1], "weights": {"1": 0}}}`,
+			err:  "proxy config: route.weights: weight of server id 1 must be positive",
+		},
+		{
+			name: "pool not found",
+			data: `{` + server + `, "route": {"type": "sharded_pools", "pools": ["pool-a"]}}`,
+			err:  `proxy config: route.pools[0]: pool "pool-a" not found`,
+		},
+		{
+			name: "pool route using pools",
+			data: `{` + server + `, "pools": [{"name": "a", "route": {"type": "prefix"}}]}`,
+			err:  `proxy config: pools[0].route.type: route type "prefix" can not be used in pools`,
+		},
+		{
+			name: "duplicated pool name",
+			data: `{` + server + `, "pools": [` +
+				`{"name": "a", "route": {"type": "replicated", "servers": [1]}},` +
+				`{"name": "a", "route": {"type": "replicated", "servers": [1]}}]}`,
+			err: `proxy config: pools[1]: duplicated pool name "a"`,
+		},
+		{
+			name: "prefix router without default pool",
+			data: `{` + server + `, "route": {"type": "prefix"}}`,
+			err:  "proxy config: route.default_pool: must not be empty",
+		},
+	}
+
+	for _, tc := range invalidCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			conf, err := ParseFileConfig([]byte(tc.data))
+			assert.Nil(t, conf)
+			assert.Equal(t, errors.New(tc.err).Error(), err.Error())
+		})
+	}
+}
+
+const testYAMLFileConfig = `
+servers:
+  - {id: 11, host: localhost, port: 11211}
+  - {id: 12, host: localhost, port: 11212}
+  - {id: 21, host: localhost, port: 11221}
+  - {id: 22, host: localhost, port: 11222}
+num_conns_per_server: 3
+pools:
+  - name: pool-a
+    route: {type: replicated, servers: [11, 12], min_percent: 2, memory_scoring: sqrt}
+  - name: pool-b
+    route:
+      type: rendezvous
+      servers: [21, 22]
+      weights:
+        21: 2
+route: {type: sharded_pools, pools: [pool-a, pool-b], virtual_nodes: 40, failover: none}
+stats:
+  check_interval: 10s
+`
+
+func TestParseYAMLFileConfig(t *testing.T) {
+	t.Run("normal", func(t *testing.T) {
+		conf, err := ParseYAMLFileConfig([]byte(testYAMLFileConfig))
+		assert.Equal(t, nil, err)
+
+		jsonConf, err := ParseFileConfig([]byte(testFileConfig))
+		assert.Equal(t, nil, err)
+		assert.Equal(t, jsonConf, conf)
+	})
+
+	t.Run("invalid yaml", func(t *testing.T) {
+		conf, err := ParseYAMLFileConfig([]byte("servers: [1"))
+		assert.Nil(t, conf)
+		assert.Contains(t, err.Error(), "proxy config: invalid yaml: ")
+	})
+
+	t.Run("unknown field", func(t *testing.T) {
+		conf, err := ParseYAMLFileConfig([]byte("servers: []\nrouter: {}\n"))
+		assert.Nil(t, conf)
+		assert.Equal(t, `proxy config: invalid json: json: unknown field "router"`, err.Error())
+	})
+
+	t.Run("validated", func(t *testing.T) {
+		conf, err := ParseYAMLFileConfig([]byte("servers: []\n"))
+		assert.Nil(t, conf)
+		assert.Equal(t, "proxy config: servers: must not be empty", err.Error())
+	})
+}
+
+func TestLoadFileConfig(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "memproxy.json")
+
+	_, err := LoadFileConfig(path)
+	assert.True(t, errors.Is(err, os.ErrNotExist))
+
+	err = os.WriteFile(path, []byte(testFileConfig), 0o600)
+	assert.Equal(t, nil, err)
+
+	conf, err := LoadFileConfig(path)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 4, len(conf.Servers))
+}
+
+func TestLoadFileConfig_YAML(t *testing.T) {
+	dir := t.TempDir()
+
+	for _, name := range []string{"memproxy.yaml", "memproxy.yml"} {
+		path := filepath.Join(dir, name)
+
+		err := os.WriteFile(path, []byte(testYAMLFileConfig), 0o600)
+		assert.Equal(t, nil, err)
+
+		conf, err := LoadFileConfig(path)
+		assert.Equal(t, nil, err)
+		assert.Equal(t, 4, len(conf.Servers))
+		assert.Equal(t, map[ServerID]float64{21: 2}, conf.Pools[1].Route.Weights)
+	}
+}
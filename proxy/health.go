@@ -0,0 +1,560 @@
+package proxy
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"log"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/QuangTung97/go-memcache/memcache/netconn"
+)
+
+// ResultObserver receives the results of the lease gets of servers, see WithMemcacheResultObserver
+type ResultObserver interface {
+	ObserveResult(server ServerID, err error)
+}
+
+// HealthProbeClient is the client of a server for active health checks
+type HealthProbeClient interface {
+	// Probe sends a lightweight request to the server
+	Probe() error
+
+	// Close client
+	Close() error
+}
+
+type healthCheckConfig struct {
+	probeInterval         time.Duration
+	probeFailureThreshold int
+
+	window         time.Duration
+	windowBuckets  int
+	errorRate      float64
+	minRequests    int
+	baseEjection   time.Duration
+	maxEjection    time.Duration
+	maxEjectionPct int
+
+	errorLogger    func(err error)
+	ejectionLogger func(server ServerID, d time.Duration)
+
+	nowFn func() time.Time
+}
+
+// HealthCheckOption ...
+type HealthCheckOption func(conf *healthCheckConfig)
+
+// WithHealthProbeInterval configures the duration between active probes of each server, default 5 seconds
+func WithHealthProbeInterval(d time.Duration) HealthCheckOption {
+	return func(conf *healthCheckConfig) {
+		conf.probeInterval = d
+	}
+}
+
+// WithHealthProbeFailureThreshold configures the number of consecutive failed probes
+// for ejecting a server, default 3
+func WithHealthProbeFailureThreshold(n int) HealthCheckOption {
+	return func(conf *healthCheckConfig) {
+		conf.probeFailureThreshold = n
+	}
+}
+
+// WithHealthErrorRateWindow configures the sliding window of the error rate, default 10 seconds
+func WithHealthErrorRateWindow(d time.Duration) HealthCheckOption {
+	return func(conf *healthCheckConfig) {
+		conf.window = d
+	}
+}
+
+// WithHealthErrorRate configures the error rate for ejecting a server, default 0.5.
+// The error rate is only checked when the window has at least minRequests results, default 20
+func WithHealthErrorRate(rate float64, minRequests int) HealthCheckOption {
+	return func(conf *healthCheckConfig) {
+		conf.errorRate = rate
+		conf.minRequests = minRequests
+	}
+}
+
+// WithHealthEjectionDuration configures the ejection duration, default 30 seconds, max 5 minutes.
+// The duration is doubled for each consecutive ejection of a server, and is reset
+// when the server was not ejected for the max duration
+func WithHealthEjectionDuration(base time.Duration, max time.Duration) HealthCheckOption {
+	return func(conf *healthCheckConfig) {
+		conf.baseEjection = base
+		conf.maxEjection = max
+	}
+}
+
+// WithHealthMaxEjectionPercent configures the max percentage of servers that can be ejected
+// at the same time, default 50
+func WithHealthMaxEjectionPercent(percent int) HealthCheckOption {
+	return func(conf *healthCheckConfig) {
+		conf.maxEjectionPct = percent
+	}
+}
+
+// WithHealthErrorLogger configures the logger of probe errors
+func WithHealthErrorLogger(logger func(err error)) HealthCheckOption {
+	return func(conf *healthCheckConfig) {
+		conf.errorLogger = logger
+	}
+}
+
+// WithHealthEjectionLogger configures the function called when a server is ejected
+func WithHealthEjectionLogger(logger func(server ServerID, d time.Duration)) HealthCheckOption {
+	return func(conf *healthCheckConfig) {
+		conf.ejectionLogger = logger
+	}
+}
+
+func computeHealthCheckConfig(options ...HealthCheckOption) *healthCheckConfig {
+	conf := &healthCheckConfig{
+		probeInterval:         5 * time.Second,
+		probeFailureThreshold: 3,
+
+		window:         10 * time.Second,
+		windowBuckets:  10,
+		errorRate:      0.5,
+		minRequests:    20,
+		baseEjection:   30 * time.Second,
+		maxEjection:    5 * time.Minute,
+		maxEjectionPct: 50,
+
+		errorLogger: func(err error) {
+			log.Println("[ERROR] HealthChecker:", err)
+		},
+		ejectionLogger: func(server ServerID, d time.Duration) {
+		},
+
+		nowFn: time.Now,
+	}
+	for _, fn := range options {
+		fn(conf)
+	}
+	return conf
+}
+
+func (c *healthCheckConfig) validate() error {
+	if c.probeInterval <= 0 || c.probeFailureThreshold <= 0 {
+		return errors.New("proxy: health probe interval and failure threshold must be positive")
+	}
+	if c.window <= 0 || c.minRequests <= 0 {
+		return errors.New("proxy: health error rate window and min requests must be positive")
+	}
+	if c.errorRate <= 0 || c.errorRate > 1 {
+		return errors.New("proxy: health error rate must be in range (0, 1]")
+	}
+	if c.baseEjection <= 0 || c.maxEjection < c.baseEjection {
+		return errors.New("proxy: health ejection duration must be positive and not greater than max duration")
+	}
+	if c.maxEjectionPct < 0 || c.maxEjectionPct > 100 {
+		return errors.New("proxy: health max ejection percent must be in range [0, 100]")
+	}
+	return nil
+}
+
+// HealthChecker is a ServerStats that ejects unhealthy servers for a duration:
+//   - passively, when the error rate of the lease gets observed by Pipeline (see WithMemcacheResultObserver)
+//     over a sliding window is too high,
+//   - actively, when consecutive probes of the server failed.
+//
+// IsServerFailed returns true for ejected servers, the other methods are delegated to the wrapped stats.
+// The servers are changed by UpdateServers (see WithMemcacheHealthChecker).
+// Thread safe
+type HealthChecker struct {
+	stats ServerStats
+	conf  *healthCheckConfig
+
+	updateFunc func(servers any) error
+
+	// replaced by UpdateServers
+	servers atomic.Pointer[map[ServerID]*healthServer]
+
+	// for updating servers, ejecting servers and shutting down
+	mut      sync.Mutex
+	shutdown bool
+
+	wg   sync.WaitGroup
+	stop chan struct{}
+}
+
+type healthServer struct {
+	id   ServerID
+	conf any
+
+	newClient func() HealthProbeClient
+
+	// closed when the server is removed or its config changed
+	stop chan struct{}
+
+	// unix nano, not ejected when in the past
+	ejectedUntil atomic.Int64
+
+	mut     sync.Mutex
+	buckets []healthBucket
+
+	// protected by HealthChecker.mut
+	ejectionCount int
+}
+
+type healthBucket struct {
+	index  int64
+	total  int
+	errors int
+}
+
+var _ ServerStats = &HealthChecker{}
+var _ ResultObserver = &HealthChecker{}
+
+// NewHealthChecker creates the checker of the servers, the active probes are disabled when factory is nil
+func NewHealthChecker[S ServerConfig](
+	servers []S,
+	stats ServerStats,
+	factory func(conf S) HealthProbeClient,
+	options ...HealthCheckOption,
+) (*HealthChecker, error) {
+	conf := computeHealthCheckConfig(options...)
+	if err := conf.validate(); err != nil {
+		return nil, err
+	}
+
+	h := &HealthChecker{
+		stats: stats,
+		conf:  conf,
+		stop:  make(chan struct{}),
+	}
+
+	emptyServers := map[ServerID]*healthServer{}
+	h.servers.Store(&emptyServers)
+
+	h.updateFunc = func(servers any) error {
+		serverList, ok := servers.([]S)
+		if !ok {
+			return fmt.Errorf("proxy: invalid server list type %T", servers)
+		}
+
+		newServers := make([]*healthServer, 0, len(serverList))
+		for _, server := range serverList {
+			server := server
+			s := &healthServer{
+				id:      server.GetID(),
+				conf:    server,
+				stop:    make(chan struct{}),
+				buckets: make([]healthBucket, conf.windowBuckets),
+			}
+			if factory != nil {
+				s.newClient = func() HealthProbeClient {
+					return factory(server)
+				}
+			}
+			newServers = append(newServers, s)
+		}
+
+		return h.doUpdateServers(newServers)
+	}
+
+	_ = h.updateFunc(servers)
+
+	return h, nil
+}
+
+// UpdateServers starts the probes of the new servers and the servers with changed config,
+// and stops the probes of the removed servers. The ejections of the unchanged servers are kept.
+// The servers MUST be a slice of the same config type passed to NewHealthChecker
+func (h *HealthChecker) UpdateServers(servers any) error {
+	return h.updateFunc(servers)
+}
+
+func (h *HealthChecker) doUpdateServers(newServers []*healthServer) error {
+	h.mut.Lock()
+	defer h.mut.Unlock()
+
+	if h.shutdown {
+		return errors.New("proxy: health checker already shutdown")
+	}
+
+	oldServers := *h.servers.Load()
+	servers := make(map[ServerID]*healthServer, len(newServers))
+
+	startServers := make([]*healthServer, 0, len(newServers))
+	for _, server := range newServers {
+		prev, existed := oldServers[server.id]
+		if existed && reflect.DeepEqual(prev.conf, server.conf) {
+			servers[server.id] = prev
+			continue
+		}
+		servers[server.id] = server
+		startServers = append(startServers, server)
+	}
+
+	h.servers.Store(&servers)
+
+	for id, prev := range oldServers {
+		if servers[id] != prev {
+			close(prev.stop)
+		}
+	}
+
+	for _, server := range startServers {
+		if server.newClient == nil {
+			continue
+		}
+
+		s := server
+		client := s.newClient()
+
+		h.wg.Add(1)
+		go func() {
+			defer h.wg.Done()
+			h.probeLoop(s, client)
+		}()
+	}
+	return nil
+}
+
+func (h *HealthChecker) getServer(server ServerID) (*healthServer, bool) {
+	s, ok := (*h.servers.Load())[server]
+	return s, ok
+}
+
+func (h *HealthChecker) probeLoop(s *healthServer, client HealthProbeClient) {
+	ticker := time.NewTicker(h.conf.probeInterval)
+	defer ticker.Stop()
+
+	failures := 0
+	for {
+		select {
+		case <-h.stop:
+			_ = client.Close()
+			return
+
+		case <-s.stop:
+			_ = client.Close()
+			return
+
+		case <-ticker.C:
+			err := client.Probe()
+			if err == nil {
+				failures = 0
+				continue
+			}
+
+			h.conf.errorLogger(fmt.Errorf("probe server id '%d': %w", s.id, err))
+
+			failures++
+			if failures >= h.conf.probeFailureThreshold {
+				if h.eject(s) {
+					failures = 0
+				}
+			}
+		}
+	}
+}
+
+func (s *healthServer) isEjected(now time.Time) bool {
+	return s.ejectedUntil.Load() > now.UnixNano()
+}
+
+// ObserveResult adds the result to the error rate window of the server,
+// and ejects the server if the error rate is too high
+func (h *HealthChecker) ObserveResult(server ServerID, err error) {
+	s, ok := h.getServer(server)
+	if !ok {
+		return
+	}
+
+	bucketDuration := int64(h.conf.window) / int64(h.conf.windowBuckets)
+	if bucketDuration <= 0 {
+		bucketDuration = 1
+	}
+	now := h.conf.nowFn().UnixNano()
+	index := now / bucketDuration
+
+	s.mut.Lock()
+
+	b := &s.buckets[index%int64(len(s.buckets))]
+	if b.index != index {
+		*b = healthBucket{index: index}
+	}
+	b.total++
+	if err != nil {
+		b.errors++
+	}
+
+	shouldEject := false
+	if err != nil {
+		total, errorCount := 0, 0
+		for _, bucket := range s.buckets {
+			if index-bucket.index < int64(len(s.buckets)) {
+				total += bucket.total
+				errorCount += bucket.errors
+			}
+		}
+		shouldEject = total >= h.conf.minRequests &&
+			float64(errorCount) >= h.conf.errorRate*float64(total)
+	}
+
+	s.mut.Unlock()
+
+	if shouldEject && h.eject(s) {
+		s.mut.Lock()
+		for i := range s.buckets {
+			s.buckets[i] = healthBucket{}
+		}
+		s.mut.Unlock()
+	}
+}
+
+// eject returns false if the server is already ejected or the max ejection percentage reached
+func (h *HealthChecker) eject(s *healthServer) bool {
+	h.mut.Lock()
+	defer h.mut.Unlock()
+
+	now := h.conf.nowFn()
+	if s.isEjected(now) {
+		return false
+	}
+
+	servers := *h.servers.Load()
+	if servers[s.id] != s {
+		// removed by UpdateServers
+		return false
+	}
+
+	ejected := 0
+	for _, other := range servers {
+		if other.isEjected(now) {
+			ejected++
+		}
+	}
+	if (ejected+1)*100 > h.conf.maxEjectionPct*len(servers) {
+		return false
+	}
+
+	lastEjectedUntil := time.Unix(0, s.ejectedUntil.Load())
+	if s.ejectionCount > 0 && now.Sub(lastEjectedUntil) >= h.conf.maxEjection {
+		s.ejectionCount = 0
+	}
+
+	d := h.conf.baseEjection
+	for i := 0; i < s.ejectionCount && d < h.conf.maxEjection; i++ {
+		d *= 2
+	}
+	if d > h.conf.maxEjection {
+		d = h.conf.maxEjection
+	}
+	s.ejectionCount++
+
+	s.ejectedUntil.Store(now.Add(d).UnixNano())
+	h.conf.ejectionLogger(s.id, d)
+	return true
+}
+
+// IsEjected checks whether the server is currently ejected by the checker
+func (h *HealthChecker) IsEjected(server ServerID) bool {
+	s, ok := h.getServer(server)
+	if !ok {
+		return false
+	}
+	return s.isEjected(h.conf.nowFn())
+}
+
+// IsServerFailed returns true if the server is ejected or failed in the wrapped stats
+func (h *HealthChecker) IsServerFailed(server ServerID) bool {
+	return h.IsEjected(server) || h.stats.IsServerFailed(server)
+}
+
+// NotifyServerFailed ...
+func (h *HealthChecker) NotifyServerFailed(server ServerID) {
+	h.stats.NotifyServerFailed(server)
+}
+
+// GetMemUsage ...
+func (h *HealthChecker) GetMemUsage(server ServerID) float64 {
+	return h.stats.GetMemUsage(server)
+}
+
+// Shutdown stops the active probes
+func (h *HealthChecker) Shutdown() {
+	h.mut.Lock()
+	if !h.shutdown {
+		h.shutdown = true
+		close(h.stop)
+	}
+	h.mut.Unlock()
+
+	h.wg.Wait()
+}
+
+type simpleHealthProbeClient struct {
+	addr    string
+	options []netconn.Option
+
+	// nil when not connected, reconnected by the next probe after an error
+	nc     *netconn.NetConn
+	reader *bufio.Reader
+}
+
+// NewSimpleHealthProbeClient creates a probe client using the meta no-op command 'mn'.
+// The connection is created by the first probe, and is created again by the next probe after an error
+func NewSimpleHealthProbeClient(conf SimpleServerConfig) HealthProbeClient {
+	return newNoopProbeClient(conf.Address())
+}
+
+func newNoopProbeClient(addr string, options ...netconn.Option) *simpleHealthProbeClient {
+	return &simpleHealthProbeClient{
+		addr:    addr,
+		options: options,
+	}
+}
+
+// Probe ...
+func (c *simpleHealthProbeClient) Probe() error {
+	if c.nc == nil {
+		nc, err := netconn.DialNewConn(c.addr, c.options...)
+		if err != nil {
+			return err
+		}
+		c.nc = &nc
+		c.reader = bufio.NewReader(nc.Reader)
+	}
+
+	err := c.sendNoop()
+	if err != nil {
+		_ = c.Close()
+	}
+	return err
+}
+
+func (c *simpleHealthProbeClient) sendNoop() error {
+	if _, err := c.nc.Writer.Write([]byte("mn\r\n")); err != nil {
+		return err
+	}
+	if err := c.nc.Writer.Flush(); err != nil {
+		return err
+	}
+
+	line, err := c.reader.ReadString('\n')
+	if err != nil {
+		return err
+	}
+	if line != "MN\r\n" {
+		return fmt.Errorf("proxy: invalid meta no-op response %q", line)
+	}
+	return nil
+}
+
+// Close ...
+func (c *simpleHealthProbeClient) Close() error {
+	if c.nc == nil {
+		return nil
+	}
+	err := c.nc.Closer.Close()
+	c.nc = nil
+	c.reader = nil
+	return err
+}
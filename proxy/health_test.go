@@ -0,0 +1,399 @@
+package proxy
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type healthCheckerTest struct {
+	stats   *ServerStatsMock
+	checker *HealthChecker
+
+	now time.Time
+
+	mut       sync.Mutex
+	ejections []time.Duration
+}
+
+func newHealthCheckerTest(t *testing.T, servers []ServerID, options ...HealthCheckOption) *healthCheckerTest {
+	h := &healthCheckerTest{
+		now: time.Date(2023, 5, 1, 10, 0, 0, 0, time.UTC),
+	}
+
+	h.stats = &ServerStatsMock{
+		IsServerFailedFunc: func(server ServerID) bool {
+			return false
+		},
+	}
+
+	configs := make([]SimpleServerConfig, 0, len(servers))
+	for _, server := range servers {
+		configs = append(configs, SimpleServerConfig{ID: server, Host: "localhost", Port: 11211})
+	}
+
+	opts := []HealthCheckOption{
+		WithHealthErrorRate(0.5, 4),
+		WithHealthEjectionLogger(func(server ServerID, d time.Duration) {
+			h.mut.Lock()
+			h.ejections = append(h.ejections, d)
+			h.mut.Unlock()
+		}),
+		func(conf *healthCheckConfig) {
+			conf.nowFn = func() time.Time {
+				return h.now
+			}
+		},
+	}
+	opts = append(opts, options...)
+
+	checker, err := NewHealthChecker[SimpleServerConfig](configs, h.stats, nil, opts...)
+	assert.Equal(t, nil, err)
+	h.checker = checker
+
+	return h
+}
+
+func (h *healthCheckerTest) observe(server ServerID, successCount int, errorCount int) {
+	for i := 0; i < successCount; i++ {
+		h.checker.ObserveResult(server, nil)
+	}
+	for i := 0; i < errorCount; i++ {
+		h.checker.ObserveResult(server, errors.New("server error"))
+	}
+}
+
+func (h *healthCheckerTest) getEjections() []time.Duration {
+	h.mut.Lock()
+	defer h.mut.Unlock()
+	return append([]time.Duration(nil), h.ejections...)
+}
+
+func TestHealthChecker_Passive(t *testing.T) {
+	allServers := []ServerID{serverID1, serverID2, serverID3}
+
+	t.Run("eject on error rate", func(t *testing.T) {
+		h := newHealthCheckerTest(t, allServers)
+
+		h.observe(serverID1, 2, 1)
+		assert.Equal(t, false, h.checker.IsServerFailed(serverID1))
+
+		h.observe(serverID1, 0, 1)
+		assert.Equal(t, true, h.checker.IsServerFailed(serverID1))
+		assert.Equal(t, true, h.checker.IsEjected(serverID1))
+		assert.Equal(t, false, h.checker.IsServerFailed(serverID2))
+		assert.Equal(t, []time.Duration{30 * time.Second}, h.getEjections())
+
+		h.now = h.now.Add(30 * time.Second)
+		assert.Equal(t, false, h.checker.IsServerFailed(serverID1))
+	})
+
+	t.Run("failed in wrapped stats", func(t *testing.T) {
+		h := newHealthCheckerTest(t, allServers)
+		h.stats.IsServerFailedFunc = func(server ServerID) bool {
+			return server == serverID2
+		}
+
+		assert.Equal(t, false, h.checker.IsServerFailed(serverID1))
+		assert.Equal(t, true, h.checker.IsServerFailed(serverID2))
+		assert.Equal(t, false, h.checker.IsEjected(serverID2))
+	})
+
+	t.Run("errors out of window", func(t *testing.T) {
+		h := newHealthCheckerTest(t, allServers)
+
+		h.observe(serverID1, 0, 3)
+
+		h.now = h.now.Add(11 * time.Second)
+		h.observe(serverID1, 3, 1)
+		assert.Equal(t, false, h.checker.IsServerFailed(serverID1))
+	})
+
+	t.Run("ejection duration backoff", func(t *testing.T) {
+		h := newHealthCheckerTest(t, allServers, WithHealthEjectionDuration(10*time.Second, 30*time.Second))
+
+		for i := 0; i < 4; i++ {
+			h.observe(serverID1, 0, 4)
+			assert.Equal(t, true, h.checker.IsServerFailed(serverID1))
+			h.now = h.now.Add(35 * time.Second)
+		}
+		assert.Equal(t, []time.Duration{
+			10 * time.Second,
+			20 * time.Second,
+			30 * time.Second,
+			30 * time.Second,
+		}, h.getEjections())
+
+		// not ejected for the max duration => reset
+		h.now = h.now.Add(30 * time.Second)
+		h.observe(serverID1, 0, 4)
+		assert.Equal(t, 10*time.Second, h.getEjections()[4])
+	})
+
+	t.Run("max ejection percent", func(t *testing.T) {
+		h := newHealthCheckerTest(t, allServers, WithHealthMaxEjectionPercent(50))
+
+		h.observe(serverID1, 0, 4)
+		h.observe(serverID2, 0, 4)
+
+		assert.Equal(t, true, h.checker.IsServerFailed(serverID1))
+		assert.Equal(t, false, h.checker.IsServerFailed(serverID2))
+		assert.Equal(t, 1, len(h.getEjections()))
+
+		// can be ejected after the first server is back
+		h.now = h.now.Add(30 * time.Second)
+		h.observe(serverID2, 0, 4)
+		assert.Equal(t, true, h.checker.IsServerFailed(serverID2))
+	})
+
+	t.Run("single server not ejected", func(t *testing.T) {
+		h := newHealthCheckerTest(t, []ServerID{serverID1})
+
+		h.observe(serverID1, 0, 10)
+		assert.Equal(t, false, h.checker.IsServerFailed(serverID1))
+	})
+
+	t.Run("unknown server", func(t *testing.T) {
+		h := newHealthCheckerTest(t, allServers)
+
+		h.observe(40, 0, 10)
+		assert.Equal(t, false, h.checker.IsServerFailed(40))
+	})
+
+	t.Run("invalid config", func(t *testing.T) {
+		checker, err := NewHealthChecker[SimpleServerConfig](nil, &ServerStatsMock{}, nil,
+			WithHealthErrorRate(1.5, 10),
+		)
+		assert.Nil(t, checker)
+		assert.Equal(t, errors.New("proxy: health error rate must be in range (0, 1]"), err)
+	})
+}
+
+type healthProbeClientTest struct {
+	failing atomic.Bool
+	probes  atomic.Int64
+	closed  atomic.Int64
+}
+
+func (c *healthProbeClientTest) Probe() error {
+	c.probes.Add(1)
+	if c.failing.Load() {
+		return errors.New("probe error")
+	}
+	return nil
+}
+
+func (c *healthProbeClientTest) Close() error {
+	c.closed.Add(1)
+	return nil
+}
+
+func TestHealthChecker_Active(t *testing.T) {
+	clients := map[ServerID]*healthProbeClientTest{
+		serverID1: {},
+		serverID2: {},
+	}
+	clients[serverID1].failing.Store(true)
+
+	checker, err := NewHealthChecker[SimpleServerConfig](
+		[]SimpleServerConfig{
+			{ID: serverID1, Host: "localhost", Port: 11201},
+			{ID: serverID2, Host: "localhost", Port: 11202},
+		},
+		&ServerStatsMock{
+			IsServerFailedFunc: func(server ServerID) bool {
+				return false
+			},
+		},
+		func(conf SimpleServerConfig) HealthProbeClient {
+			return clients[conf.ID]
+		},
+		WithHealthProbeInterval(5*time.Millisecond),
+		WithHealthProbeFailureThreshold(2),
+		WithHealthErrorLogger(func(err error) {}),
+	)
+	assert.Equal(t, nil, err)
+
+	assert.Eventually(t, func() bool {
+		return checker.IsServerFailed(serverID1)
+	}, time.Second, 5*time.Millisecond)
+	assert.GreaterOrEqual(t, clients[serverID1].probes.Load(), int64(2))
+
+	assert.Greater(t, clients[serverID2].probes.Load(), int64(0))
+	assert.Equal(t, false, checker.IsServerFailed(serverID2))
+
+	checker.Shutdown()
+	checker.Shutdown()
+
+	assert.Equal(t, int64(1), clients[serverID1].closed.Load())
+	assert.Equal(t, int64(1), clients[serverID2].closed.Load())
+}
+
+func TestHealthChecker_UpdateServers(t *testing.T) {
+	allServers := []ServerID{serverID1, serverID2, serverID3}
+
+	newConfigs := func(servers ...ServerID) []SimpleServerConfig {
+		configs := make([]SimpleServerConfig, 0, len(servers))
+		for _, server := range servers {
+			configs = append(configs, SimpleServerConfig{ID: server, Host: "localhost", Port: 11211})
+		}
+		return configs
+	}
+
+	t.Run("keep ejections of unchanged servers", func(t *testing.T) {
+		h := newHealthCheckerTest(t, allServers)
+
+		h.observe(serverID1, 0, 4)
+		assert.Equal(t, true, h.checker.IsEjected(serverID1))
+
+		err := h.checker.UpdateServers(newConfigs(serverID1, serverID2))
+		assert.Equal(t, nil, err)
+		assert.Equal(t, true, h.checker.IsEjected(serverID1))
+
+		// removed server
+		h.observe(serverID3, 0, 4)
+		assert.Equal(t, false, h.checker.IsEjected(serverID3))
+	})
+
+	t.Run("changed config resets ejection", func(t *testing.T) {
+		h := newHealthCheckerTest(t, allServers)
+
+		h.observe(serverID1, 0, 4)
+		assert.Equal(t, true, h.checker.IsEjected(serverID1))
+
+		configs := newConfigs(serverID1, serverID2, serverID3)
+		configs[0].Port = 11212
+
+		err := h.checker.UpdateServers(configs)
+		assert.Equal(t, nil, err)
+		assert.Equal(t, false, h.checker.IsEjected(serverID1))
+	})
+
+	t.Run("invalid server list type", func(t *testing.T) {
+		h := newHealthCheckerTest(t, allServers)
+
+		err := h.checker.UpdateServers([]ServerID{serverID1})
+		assert.Equal(t, errors.New("proxy: invalid server list type []proxy.ServerID"), err)
+	})
+
+	t.Run("already shutdown", func(t *testing.T) {
+		h := newHealthCheckerTest(t, allServers)
+		h.checker.Shutdown()
+
+		err := h.checker.UpdateServers(newConfigs(serverID1))
+		assert.Equal(t, errors.New("proxy: health checker already shutdown"), err)
+	})
+
+	t.Run("start and stop probes", func(t *testing.T) {
+		var mut sync.Mutex
+		clients := map[ServerID]*healthProbeClientTest{}
+
+		checker, err := NewHealthChecker[SimpleServerConfig](
+			newConfigs(serverID1, serverID2),
+			&ServerStatsMock{},
+			func(conf SimpleServerConfig) HealthProbeClient {
+				mut.Lock()
+				defer mut.Unlock()
+
+				client := &healthProbeClientTest{}
+				clients[conf.ID] = client
+				return client
+			},
+			WithHealthProbeInterval(5*time.Millisecond),
+		)
+		assert.Equal(t, nil, err)
+
+		err = checker.UpdateServers(newConfigs(serverID2, serverID3))
+		assert.Equal(t, nil, err)
+
+		mut.Lock()
+		client1, client2, client3 := clients[serverID1], clients[serverID2], clients[serverID3]
+		mut.Unlock()
+
+		assert.Eventually(t, func() bool {
+			return client1.closed.Load() == 1 && client3.probes.Load() > 0
+		}, time.Second, 5*time.Millisecond)
+		assert.Equal(t, int64(0), client2.closed.Load())
+
+		checker.Shutdown()
+
+		assert.Equal(t, int64(1), client1.closed.Load())
+		assert.Equal(t, int64(1), client2.closed.Load())
+		assert.Equal(t, int64(1), client3.closed.Load())
+	})
+}
+
+// startNoopServer responds the meta no-op commands of the n-th connection (from 0) with the response of n
+func startNoopServer(t *testing.T, responses ...string) net.Listener {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Equal(t, nil, err)
+
+	go func() {
+		for index := 0; ; index++ {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			go serveNoop(conn, responses[index%len(responses)])
+		}
+	}()
+
+	return listener
+}
+
+func serveNoop(conn net.Conn, response string) {
+	defer func() { _ = conn.Close() }()
+
+	reader := bufio.NewReader(conn)
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil || line != "mn\r\n" {
+			return
+		}
+		if _, err := conn.Write([]byte(response)); err != nil {
+			return
+		}
+	}
+}
+
+func TestSimpleHealthProbeClient(t *testing.T) {
+	t.Run("meta no-op", func(t *testing.T) {
+		listener := startNoopServer(t, "MN\r\n")
+		defer func() { _ = listener.Close() }()
+
+		client := newNoopProbeClient(listener.Addr().String())
+		assert.Equal(t, nil, client.Probe())
+		assert.Equal(t, nil, client.Probe())
+		assert.Equal(t, nil, client.Close())
+		assert.Equal(t, nil, client.Close())
+	})
+
+	t.Run("reconnect after error", func(t *testing.T) {
+		listener := startNoopServer(t, "ERROR\r\n", "MN\r\n")
+		defer func() { _ = listener.Close() }()
+
+		client := newNoopProbeClient(listener.Addr().String())
+		assert.Equal(t, errors.New(`proxy: invalid meta no-op response "ERROR\r\n"`), client.Probe())
+		assert.Nil(t, client.nc)
+
+		assert.Equal(t, nil, client.Probe())
+		assert.Equal(t, nil, client.Close())
+	})
+
+	t.Run("dial error", func(t *testing.T) {
+		listener := startNoopServer(t, "MN\r\n")
+		addr := listener.Addr().String()
+		_ = listener.Close()
+
+		client := newNoopProbeClient(addr)
+		assert.NotEqual(t, nil, client.Probe())
+		assert.Equal(t, nil, client.Close())
+	})
+}
@@ -0,0 +1,410 @@
+package proxy
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/QuangTung97/memproxy"
+)
+
+type invalidationConfig struct {
+	filePath         string
+	compactThreshold int
+	batchSize        int
+	errorLogger      func(err error)
+}
+
+// InvalidationOption ...
+type InvalidationOption func(conf *invalidationConfig)
+
+// WithInvalidationFile persists the queue to an append-only file, the pending deletes in the file
+// are loaded by NewInvalidationQueue, so they are not lost when the process restarted
+func WithInvalidationFile(path string) InvalidationOption {
+	return func(conf *invalidationConfig) {
+		conf.filePath = path
+	}
+}
+
+// WithInvalidationCompactThreshold configures the number of removed deletes appended to the file
+// before the file is rewritten with only the pending deletes, default 10000
+func WithInvalidationCompactThreshold(n int) InvalidationOption {
+	return func(conf *invalidationConfig) {
+		conf.compactThreshold = n
+	}
+}
+
+// WithInvalidationBatchSize configures the number of deletes in a pipeline when replaying, default 100
+func WithInvalidationBatchSize(size int) InvalidationOption {
+	return func(conf *invalidationConfig) {
+		conf.batchSize = size
+	}
+}
+
+// WithInvalidationErrorLogger configures the logger of file errors
+func WithInvalidationErrorLogger(logger func(err error)) InvalidationOption {
+	return func(conf *invalidationConfig) {
+		conf.errorLogger = logger
+	}
+}
+
+func computeInvalidationConfig(options ...InvalidationOption) *invalidationConfig {
+	conf := &invalidationConfig{
+		compactThreshold: 10000,
+		batchSize:        100,
+		errorLogger: func(err error) {
+			log.Println("[ERROR] InvalidationQueue:", err)
+		},
+	}
+	for _, fn := range options {
+		fn(conf)
+	}
+	return conf
+}
+
+// InvalidationQueue records the deletes of keys that failed or were skipped (because the servers were failed)
+// for each server, and replays them when the servers are healthy again.
+// Without it, a replica that was briefly down keeps the stale values after recovered.
+// Thread safe
+type InvalidationQueue struct {
+	conf *invalidationConfig
+
+	mut     sync.Mutex
+	servers map[ServerID]*serverInvalidations
+	size    int
+	seq     uint64
+
+	file *os.File
+
+	// number of remove lines appended to the file since the last compaction
+	removedLines int
+}
+
+type serverInvalidations struct {
+	// the sequence number of the last Add of the key, for not removing keys added again while replaying
+	keys map[string]uint64
+}
+
+const (
+	invalidationOpAdd    = "+"
+	invalidationOpRemove = "-"
+)
+
+// NewInvalidationQueue creates the queue, and loads the pending deletes from the file if configured
+func NewInvalidationQueue(options ...InvalidationOption) (*InvalidationQueue, error) {
+	conf := computeInvalidationConfig(options...)
+	if conf.batchSize <= 0 {
+		return nil, errors.New("proxy: invalidation batch size must be positive")
+	}
+	if conf.compactThreshold <= 0 {
+		return nil, errors.New("proxy: invalidation compact threshold must be positive")
+	}
+
+	q := &InvalidationQueue{
+		conf:    conf,
+		servers: map[ServerID]*serverInvalidations{},
+	}
+
+	if conf.filePath == "" {
+		return q, nil
+	}
+
+	if err := q.loadFile(); err != nil {
+		return nil, err
+	}
+	if err := q.compactFile(); err != nil {
+		return nil, err
+	}
+	return q, nil
+}
+
+func (q *InvalidationQueue) loadFile() error {
+	file, err := os.Open(q.conf.filePath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer func() { _ = file.Close() }()
+
+	scanner := bufio.NewScanner(file)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+
+		op, server, key, err := parseInvalidationLine(scanner.Text())
+		if err != nil {
+			return fmt.Errorf("proxy: invalidation file %s:%d: %w", q.conf.filePath, lineNum, err)
+		}
+
+		if op == invalidationOpAdd {
+			q.addLocked(server, key)
+		} else {
+			q.removeLocked(server, key)
+		}
+	}
+	return scanner.Err()
+}
+
+func parseInvalidationLine(line string) (string, ServerID, string, error) {
+	parts := strings.SplitN(line, " ", 3)
+	if len(parts) != 3 || (parts[0] != invalidationOpAdd && parts[0] != invalidationOpRemove) {
+		return "", 0, "", errors.New("invalid line")
+	}
+
+	server, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return "", 0, "", fmt.Errorf("invalid server id: %w", err)
+	}
+
+	key, err := strconv.Unquote(parts[2])
+	if err != nil {
+		return "", 0, "", fmt.Errorf("invalid key: %w", err)
+	}
+	return parts[0], ServerID(server), key, nil
+}
+
+func formatInvalidationLine(w io.Writer, op string, server ServerID, key string) error {
+	_, err := fmt.Fprintf(w, "%s %d %q\n", op, server, key)
+	return err
+}
+
+// compactFile rewrites the file with only the pending deletes, then opens it for appending.
+// Called with q.mut locked (or inside NewInvalidationQueue)
+func (q *InvalidationQueue) compactFile() error {
+	tmpPath := q.conf.filePath + ".tmp"
+
+	tmp, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+
+	w := bufio.NewWriter(tmp)
+	for server, s := range q.servers {
+		for key := range s.keys {
+			if err := formatInvalidationLine(w, invalidationOpAdd, server, key); err != nil {
+				_ = tmp.Close()
+				return err
+			}
+		}
+	}
+
+	if err := w.Flush(); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmpPath, q.conf.filePath); err != nil {
+		return err
+	}
+
+	// the old file was replaced, appending to it is lost
+	if q.file != nil {
+		_ = q.file.Close()
+	}
+
+	q.file, err = os.OpenFile(q.conf.filePath, os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		q.file = nil
+		return err
+	}
+	q.removedLines = 0
+	return nil
+}
+
+func (q *InvalidationQueue) appendFile(op string, server ServerID, key string) {
+	if q.file == nil {
+		return
+	}
+	if err := formatInvalidationLine(q.file, op, server, key); err != nil {
+		q.conf.errorLogger(err)
+	}
+}
+
+func (q *InvalidationQueue) addLocked(server ServerID, key string) bool {
+	s, ok := q.servers[server]
+	if !ok {
+		s = &serverInvalidations{keys: map[string]uint64{}}
+		q.servers[server] = s
+	}
+
+	q.seq++
+	_, existed := s.keys[key]
+	s.keys[key] = q.seq
+	if !existed {
+		q.size++
+	}
+	return !existed
+}
+
+func (q *InvalidationQueue) removeLocked(server ServerID, key string) {
+	s, ok := q.servers[server]
+	if !ok {
+		return
+	}
+	if _, existed := s.keys[key]; !existed {
+		return
+	}
+
+	delete(s.keys, key)
+	q.size--
+	if len(s.keys) == 0 {
+		delete(q.servers, server)
+	}
+}
+
+// Add records a delete of the key that failed or was skipped on the server
+func (q *InvalidationQueue) Add(server ServerID, key string) {
+	q.mut.Lock()
+	defer q.mut.Unlock()
+
+	if q.addLocked(server, key) {
+		q.appendFile(invalidationOpAdd, server, key)
+	}
+}
+
+// Size returns the number of pending deletes of all servers
+func (q *InvalidationQueue) Size() int {
+	q.mut.Lock()
+	defer q.mut.Unlock()
+	return q.size
+}
+
+// ServerSize returns the number of pending deletes of the server
+func (q *InvalidationQueue) ServerSize(server ServerID) int {
+	q.mut.Lock()
+	defer q.mut.Unlock()
+
+	s, ok := q.servers[server]
+	if !ok {
+		return 0
+	}
+	return len(s.keys)
+}
+
+type pendingInvalidation struct {
+	key string
+	seq uint64
+}
+
+func (q *InvalidationQueue) getPending(server ServerID) []pendingInvalidation {
+	q.mut.Lock()
+	defer q.mut.Unlock()
+
+	s, ok := q.servers[server]
+	if !ok {
+		return nil
+	}
+
+	result := make([]pendingInvalidation, 0, len(s.keys))
+	for key, seq := range s.keys {
+		result = append(result, pendingInvalidation{key: key, seq: seq})
+	}
+	return result
+}
+
+// markDone removes the replayed keys, keys added again after replay started are kept
+func (q *InvalidationQueue) markDone(server ServerID, done []pendingInvalidation) {
+	q.mut.Lock()
+	defer q.mut.Unlock()
+
+	s, ok := q.servers[server]
+	if !ok {
+		return
+	}
+
+	for _, entry := range done {
+		if s.keys[entry.key] != entry.seq {
+			continue
+		}
+		q.removeLocked(server, entry.key)
+		q.appendFile(invalidationOpRemove, server, entry.key)
+		q.removedLines++
+	}
+
+	if q.file == nil {
+		return
+	}
+
+	if q.size == 0 {
+		if err := q.file.Truncate(0); err != nil {
+			q.conf.errorLogger(err)
+			return
+		}
+		q.removedLines = 0
+		return
+	}
+
+	if q.removedLines >= q.conf.compactThreshold {
+		if err := q.compactFile(); err != nil {
+			q.conf.errorLogger(err)
+		}
+	}
+}
+
+// Replay deletes the pending keys of the server using the client of that server (see Memcache.ServerMemcache).
+// Stops at the first failed batch and returns its error, the keys not deleted are kept in the queue
+func (q *InvalidationQueue) Replay(ctx context.Context, server ServerID, client memproxy.Memcache) error {
+	pending := q.getPending(server)
+
+	for len(pending) > 0 {
+		n := q.conf.batchSize
+		if n > len(pending) {
+			n = len(pending)
+		}
+		batch := pending[:n]
+		pending = pending[n:]
+
+		if err := deleteBatch(ctx, client, batch); err != nil {
+			return err
+		}
+		q.markDone(server, batch)
+	}
+	return nil
+}
+
+func deleteBatch(ctx context.Context, client memproxy.Memcache, batch []pendingInvalidation) error {
+	pipe := client.Pipeline(ctx)
+	defer pipe.Finish()
+
+	fnList := make([]func() (memproxy.DeleteResponse, error), 0, len(batch))
+	for _, entry := range batch {
+		fnList = append(fnList, pipe.Delete(entry.key, memproxy.DeleteOptions{}))
+	}
+
+	var lastErr error
+	for _, fn := range fnList {
+		if _, err := fn(); err != nil {
+			lastErr = err
+		}
+	}
+	return lastErr
+}
+
+// Close closes the file of the queue
+func (q *InvalidationQueue) Close() error {
+	q.mut.Lock()
+	defer q.mut.Unlock()
+
+	if q.file == nil {
+		return nil
+	}
+	err := q.file.Close()
+	q.file = nil
+	return err
+}
@@ -0,0 +1,237 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/QuangTung97/memproxy"
+	"github.com/QuangTung97/memproxy/mocks"
+)
+
+type invalidationTest struct {
+	client *mocks.MemcacheMock
+	pipe   *mocks.PipelineMock
+
+	deleted    []string
+	deleteErrs map[string]error
+}
+
+func newInvalidationTest() *invalidationTest {
+	i := &invalidationTest{
+		deleteErrs: map[string]error{},
+	}
+
+	i.pipe = &mocks.PipelineMock{
+		DeleteFunc: func(key string, options memproxy.DeleteOptions) func() (memproxy.DeleteResponse, error) {
+			return func() (memproxy.DeleteResponse, error) {
+				i.deleted = append(i.deleted, key)
+				return memproxy.DeleteResponse{}, i.deleteErrs[key]
+			}
+		},
+		FinishFunc: func() {},
+	}
+	i.client = &mocks.MemcacheMock{
+		PipelineFunc: func(ctx context.Context, options ...memproxy.PipelineOption) memproxy.Pipeline {
+			return i.pipe
+		},
+	}
+	return i
+}
+
+func sortedStrings(values []string) []string {
+	result := append([]string(nil), values...)
+	sort.Strings(result)
+	return result
+}
+
+func TestInvalidationQueue(t *testing.T) {
+	t.Run("add-and-replay", func(t *testing.T) {
+		q, err := NewInvalidationQueue()
+		assert.Equal(t, nil, err)
+
+		q.Add(serverID1, "KEY01")
+		q.Add(serverID1, "KEY02")
+		q.Add(serverID1, "KEY01")
+		q.Add(serverID2, "KEY03")
+
+		assert.Equal(t, 3, q.Size())
+		assert.Equal(t, 2, q.ServerSize(serverID1))
+		assert.Equal(t, 1, q.ServerSize(serverID2))
+		assert.Equal(t, 0, q.ServerSize(serverID3))
+
+		i := newInvalidationTest()
+		err = q.Replay(context.Background(), serverID1, i.client)
+		assert.Equal(t, nil, err)
+
+		assert.Equal(t, []string{"KEY01", "KEY02"}, sortedStrings(i.deleted))
+		assert.Equal(t, 1, len(i.pipe.FinishCalls()))
+
+		assert.Equal(t, 1, q.Size())
+		assert.Equal(t, 0, q.ServerSize(serverID1))
+		assert.Equal(t, 1, q.ServerSize(serverID2))
+
+		assert.Equal(t, nil, q.Close())
+	})
+
+	t.Run("replay-in-batches--stop-on-error", func(t *testing.T) {
+		q, err := NewInvalidationQueue(WithInvalidationBatchSize(2))
+		assert.Equal(t, nil, err)
+
+		q.Add(serverID1, "KEY01")
+		q.Add(serverID1, "KEY02")
+		q.Add(serverID1, "KEY03")
+		q.Add(serverID1, "KEY04")
+
+		i := newInvalidationTest()
+		i.pipe.DeleteFunc = func(key string, options memproxy.DeleteOptions) func() (memproxy.DeleteResponse, error) {
+			index := len(i.pipe.DeleteCalls())
+			return func() (memproxy.DeleteResponse, error) {
+				if index > 2 {
+					return memproxy.DeleteResponse{}, errors.New("server error")
+				}
+				return memproxy.DeleteResponse{}, nil
+			}
+		}
+
+		err = q.Replay(context.Background(), serverID1, i.client)
+		assert.Equal(t, errors.New("server error"), err)
+
+		assert.Equal(t, 4, len(i.pipe.DeleteCalls()))
+		assert.Equal(t, 2, len(i.pipe.FinishCalls()))
+		assert.Equal(t, 2, q.ServerSize(serverID1))
+	})
+
+	t.Run("key-added-again-while-replaying", func(t *testing.T) {
+		q, err := NewInvalidationQueue()
+		assert.Equal(t, nil, err)
+
+		q.Add(serverID1, "KEY01")
+
+		i := newInvalidationTest()
+		i.pipe.DeleteFunc = func(key string, options memproxy.DeleteOptions) func() (memproxy.DeleteResponse, error) {
+			q.Add(serverID1, key)
+			return func() (memproxy.DeleteResponse, error) {
+				return memproxy.DeleteResponse{}, nil
+			}
+		}
+
+		err = q.Replay(context.Background(), serverID1, i.client)
+		assert.Equal(t, nil, err)
+		assert.Equal(t, 1, q.ServerSize(serverID1))
+	})
+
+	t.Run("invalid-batch-size", func(t *testing.T) {
+		q, err := NewInvalidationQueue(WithInvalidationBatchSize(0))
+		assert.Nil(t, q)
+		assert.Equal(t, errors.New("proxy: invalidation batch size must be positive"), err)
+
+		q, err = NewInvalidationQueue(WithInvalidationCompactThreshold(0))
+		assert.Nil(t, q)
+		assert.Equal(t, errors.New("proxy: invalidation compact threshold must be positive"), err)
+	})
+}
+
+func TestInvalidationQueue_File(t *testing.T) {
+	t.Run("persist-and-reload", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "invalidations.log")
+
+		q, err := NewInvalidationQueue(WithInvalidationFile(path))
+		assert.Equal(t, nil, err)
+
+		q.Add(serverID1, "KEY01")
+		q.Add(serverID1, "KEY 02")
+		q.Add(serverID2, "KEY03")
+		assert.Equal(t, nil, q.Close())
+
+		q, err = NewInvalidationQueue(WithInvalidationFile(path))
+		assert.Equal(t, nil, err)
+		assert.Equal(t, 3, q.Size())
+		assert.Equal(t, 2, q.ServerSize(serverID1))
+
+		i := newInvalidationTest()
+		err = q.Replay(context.Background(), serverID1, i.client)
+		assert.Equal(t, nil, err)
+		assert.Equal(t, []string{"KEY 02", "KEY01"}, sortedStrings(i.deleted))
+		assert.Equal(t, nil, q.Close())
+
+		q, err = NewInvalidationQueue(WithInvalidationFile(path))
+		assert.Equal(t, nil, err)
+		assert.Equal(t, 1, q.Size())
+		assert.Equal(t, 1, q.ServerSize(serverID2))
+
+		// compacted when loaded
+		data, err := os.ReadFile(path)
+		assert.Equal(t, nil, err)
+		assert.Equal(t, "+ 32 \"KEY03\"\n", string(data))
+
+		// truncated when empty
+		err = q.Replay(context.Background(), serverID2, i.client)
+		assert.Equal(t, nil, err)
+		assert.Equal(t, nil, q.Close())
+
+		data, err = os.ReadFile(path)
+		assert.Equal(t, nil, err)
+		assert.Equal(t, "", string(data))
+	})
+
+	t.Run("compacted-when-removed-lines-reached-threshold", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "invalidations.log")
+
+		newQueue := func(threshold int) *InvalidationQueue {
+			q, err := NewInvalidationQueue(WithInvalidationFile(path), WithInvalidationCompactThreshold(threshold))
+			assert.Equal(t, nil, err)
+			q.Add(serverID1, "KEY01")
+			q.Add(serverID1, "KEY02")
+			q.Add(serverID2, "KEY03")
+			return q
+		}
+
+		// not reached the threshold
+		q := newQueue(3)
+		err := q.Replay(context.Background(), serverID1, newInvalidationTest().client)
+		assert.Equal(t, nil, err)
+		assert.Equal(t, nil, q.Close())
+
+		data, err := os.ReadFile(path)
+		assert.Equal(t, nil, err)
+		assert.Equal(t, 5, strings.Count(string(data), "\n"))
+
+		q = newQueue(2)
+		defer func() { _ = q.Close() }()
+
+		err = q.Replay(context.Background(), serverID1, newInvalidationTest().client)
+		assert.Equal(t, nil, err)
+		assert.Equal(t, 1, q.Size())
+
+		data, err = os.ReadFile(path)
+		assert.Equal(t, nil, err)
+		assert.Equal(t, "+ 32 \"KEY03\"\n", string(data))
+
+		// appended after compacted
+		q.Add(serverID1, "KEY04")
+		data, err = os.ReadFile(path)
+		assert.Equal(t, nil, err)
+		assert.Equal(t, "+ 32 \"KEY03\"\n+ 31 \"KEY04\"\n", string(data))
+	})
+
+	t.Run("invalid-file", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "invalidations.log")
+		err := os.WriteFile(path, []byte("+ 31 \"KEY01\"\n+ abc \"KEY02\"\n"), 0o600)
+		assert.Equal(t, nil, err)
+
+		q, err := NewInvalidationQueue(WithInvalidationFile(path))
+		assert.Nil(t, q)
+		assert.Equal(t,
+			"proxy: invalidation file "+path+":2: invalid server id: "+
+				"strconv.ParseInt: parsing \"abc\": invalid syntax",
+			err.Error(),
+		)
+	})
+}
@@ -0,0 +1,134 @@
+package proxy
+
+import (
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// LatencyObserver receives the round-trip times of servers measured by Pipeline, see WithMemcacheLatencyObserver
+type LatencyObserver interface {
+	ObserveLatency(server ServerID, d time.Duration)
+}
+
+// LatencySource returns the smoothed round-trip times of servers, see WithLatencyScoring
+type LatencySource interface {
+	// GetLatency returns zero when no latency observed for the server
+	GetLatency(server ServerID) time.Duration
+}
+
+type latencyStatsConfig struct {
+	alpha float64
+}
+
+// LatencyStatsOption ...
+type LatencyStatsOption func(conf *latencyStatsConfig)
+
+// WithLatencySmoothingFactor configures the smoothing factor of the EWMA, in range (0, 1], default 0.1.
+// Higher values make the latency follow the recent round-trip times faster
+func WithLatencySmoothingFactor(alpha float64) LatencyStatsOption {
+	return func(conf *latencyStatsConfig) {
+		conf.alpha = alpha
+	}
+}
+
+// LatencyServerStats is a ServerStats keeping the EWMA of the round-trip time of each server,
+// the other methods of ServerStats are delegated to the wrapped stats (e.g. SimpleServerStats).
+// Thread safe
+type LatencyServerStats struct {
+	stats ServerStats
+	conf  *latencyStatsConfig
+
+	mut     sync.RWMutex
+	servers map[ServerID]*atomic.Uint64 // float64 bits of the latency in nanoseconds
+}
+
+var _ ServerStats = &LatencyServerStats{}
+var _ LatencyObserver = &LatencyServerStats{}
+var _ LatencySource = &LatencyServerStats{}
+
+// NewLatencyServerStats ...
+func NewLatencyServerStats(stats ServerStats, options ...LatencyStatsOption) *LatencyServerStats {
+	conf := &latencyStatsConfig{
+		alpha: 0.1,
+	}
+	for _, fn := range options {
+		fn(conf)
+	}
+
+	if conf.alpha <= 0 || conf.alpha > 1 {
+		panic("latency server stats: smoothing factor must be in range (0, 1]")
+	}
+
+	return &LatencyServerStats{
+		stats:   stats,
+		conf:    conf,
+		servers: map[ServerID]*atomic.Uint64{},
+	}
+}
+
+func (s *LatencyServerStats) getLatencyValue(server ServerID) *atomic.Uint64 {
+	s.mut.RLock()
+	value, ok := s.servers[server]
+	s.mut.RUnlock()
+	if ok {
+		return value
+	}
+
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	value, ok = s.servers[server]
+	if !ok {
+		value = &atomic.Uint64{}
+		s.servers[server] = value
+	}
+	return value
+}
+
+// ObserveLatency adds a round-trip time of the server to the EWMA
+func (s *LatencyServerStats) ObserveLatency(server ServerID, d time.Duration) {
+	value := s.getLatencyValue(server)
+	sample := float64(d)
+
+	for {
+		oldBits := value.Load()
+
+		newValue := sample
+		if oldBits != 0 {
+			old := math.Float64frombits(oldBits)
+			newValue = old + s.conf.alpha*(sample-old)
+		}
+
+		if value.CompareAndSwap(oldBits, math.Float64bits(newValue)) {
+			return
+		}
+	}
+}
+
+// GetLatency returns the EWMA of the round-trip time of the server
+func (s *LatencyServerStats) GetLatency(server ServerID) time.Duration {
+	s.mut.RLock()
+	value, ok := s.servers[server]
+	s.mut.RUnlock()
+	if !ok {
+		return 0
+	}
+	return time.Duration(math.Float64frombits(value.Load()))
+}
+
+// IsServerFailed ...
+func (s *LatencyServerStats) IsServerFailed(server ServerID) bool {
+	return s.stats.IsServerFailed(server)
+}
+
+// NotifyServerFailed ...
+func (s *LatencyServerStats) NotifyServerFailed(server ServerID) {
+	s.stats.NotifyServerFailed(server)
+}
+
+// GetMemUsage ...
+func (s *LatencyServerStats) GetMemUsage(server ServerID) float64 {
+	return s.stats.GetMemUsage(server)
+}
@@ -0,0 +1,75 @@
+package proxy
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLatencyServerStats(t *testing.T) {
+	t.Run("ewma", func(t *testing.T) {
+		s := NewLatencyServerStats(&ServerStatsMock{}, WithLatencySmoothingFactor(0.5))
+
+		assert.Equal(t, time.Duration(0), s.GetLatency(serverID1))
+
+		s.ObserveLatency(serverID1, 10*time.Millisecond)
+		assert.Equal(t, 10*time.Millisecond, s.GetLatency(serverID1))
+
+		s.ObserveLatency(serverID1, 20*time.Millisecond)
+		assert.Equal(t, 15*time.Millisecond, s.GetLatency(serverID1))
+
+		s.ObserveLatency(serverID1, 5*time.Millisecond)
+		assert.Equal(t, 10*time.Millisecond, s.GetLatency(serverID1))
+
+		assert.Equal(t, time.Duration(0), s.GetLatency(serverID2))
+	})
+
+	t.Run("delegate", func(t *testing.T) {
+		stats := &ServerStatsMock{
+			IsServerFailedFunc: func(server ServerID) bool {
+				return server == serverID1
+			},
+			NotifyServerFailedFunc: func(server ServerID) {
+			},
+			GetMemUsageFunc: func(server ServerID) float64 {
+				return 8000
+			},
+		}
+		s := NewLatencyServerStats(stats)
+
+		assert.Equal(t, true, s.IsServerFailed(serverID1))
+		assert.Equal(t, false, s.IsServerFailed(serverID2))
+		assert.Equal(t, float64(8000), s.GetMemUsage(serverID1))
+
+		s.NotifyServerFailed(serverID2)
+		assert.Equal(t, 1, len(stats.NotifyServerFailedCalls()))
+		assert.Equal(t, serverID2, stats.NotifyServerFailedCalls()[0].Server)
+	})
+
+	t.Run("concurrent", func(t *testing.T) {
+		s := NewLatencyServerStats(&ServerStatsMock{})
+
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for k := 0; k < 100; k++ {
+					s.ObserveLatency(serverID1, 2*time.Millisecond)
+					s.GetLatency(serverID1)
+				}
+			}()
+		}
+		wg.Wait()
+
+		assert.Equal(t, 2*time.Millisecond, s.GetLatency(serverID1))
+	})
+
+	t.Run("invalid smoothing factor", func(t *testing.T) {
+		assert.PanicsWithValue(t, "latency server stats: smoothing factor must be in range (0, 1]", func() {
+			NewLatencyServerStats(&ServerStatsMock{}, WithLatencySmoothingFactor(0))
+		})
+	})
+}
@@ -0,0 +1,140 @@
+package proxy
+
+import (
+	"sort"
+	"strings"
+)
+
+// PrefixRoute maps the keys with the Prefix to the Route
+type PrefixRoute struct {
+	Prefix string
+	Route  Route
+}
+
+type prefixRouter struct {
+	prefixes []string
+
+	// indices of prefixes sorted by length of prefixes descending
+	matchOrder []int
+
+	// pools in the same order as prefixes, the default route is the last pool
+	set poolSet
+}
+
+type prefixRouterSelector struct {
+	router *prefixRouter
+	pools  poolSelectors
+}
+
+var _ Route = &prefixRouter{}
+var _ KeyFailoverSelector = &prefixRouterSelector{}
+
+// NewPrefixRouter creates a route that maps keys to the child routes by the longest matched prefix,
+// keys not matched with any prefix are mapped to the defaultRoute.
+// Each child route can use a different group of servers, e.g. for different data classes
+func NewPrefixRouter(routes []PrefixRoute, defaultRoute Route) Route {
+	if defaultRoute == nil {
+		panic("prefix router: default route is nil")
+	}
+
+	prefixes := make([]string, 0, len(routes))
+	pools := make([]Pool, 0, len(routes)+1)
+
+	existed := map[string]struct{}{}
+	for _, r := range routes {
+		if _, ok := existed[r.Prefix]; ok || r.Prefix == "" {
+			panic("prefix router: prefixes must be non-empty and unique")
+		}
+		existed[r.Prefix] = struct{}{}
+
+		prefixes = append(prefixes, r.Prefix)
+		pools = append(pools, Pool{
+			Name:  "prefix:" + r.Prefix,
+			Route: r.Route,
+		})
+	}
+	pools = append(pools, Pool{
+		Name:  "default",
+		Route: defaultRoute,
+	})
+
+	matchOrder := make([]int, 0, len(prefixes))
+	for index := range prefixes {
+		matchOrder = append(matchOrder, index)
+	}
+	sort.SliceStable(matchOrder, func(i, j int) bool {
+		return len(prefixes[matchOrder[i]]) > len(prefixes[matchOrder[j]])
+	})
+
+	return &prefixRouter{
+		prefixes:   prefixes,
+		matchOrder: matchOrder,
+		set:        newPoolSet(pools, "prefix router"),
+	}
+}
+
+// NewSelector ...
+func (r *prefixRouter) NewSelector() Selector {
+	return &prefixRouterSelector{
+		router: r,
+		pools:  newPoolSelectors(&r.set),
+	}
+}
+
+// AllServerIDs returns the list of all possible servers of all child routes
+func (r *prefixRouter) AllServerIDs() []ServerID {
+	return r.set.allServers
+}
+
+func (r *prefixRouter) findPool(key string) int {
+	for _, index := range r.matchOrder {
+		if strings.HasPrefix(key, r.prefixes[index]) {
+			return index
+		}
+	}
+	return len(r.prefixes)
+}
+
+// SetFailedServer ...
+func (s *prefixRouterSelector) SetFailedServer(server ServerID) {
+	s.pools.setFailedServer(server)
+}
+
+func (s *prefixRouterSelector) markFailedServer(server ServerID) {
+	s.pools.markFailedServer(server)
+}
+
+// HasNextAvailableServer check if any child route containing the last failed server has next available server
+func (s *prefixRouterSelector) HasNextAvailableServer() bool {
+	return s.pools.hasAvailableFailedPool()
+}
+
+// HasNextAvailableServerForKey check if the child route of the key has next available server
+func (s *prefixRouterSelector) HasNextAvailableServerForKey(key string) bool {
+	return !s.pools.exhausted[s.router.findPool(key)]
+}
+
+// SelectServer choose a server from the child route of the key
+func (s *prefixRouterSelector) SelectServer(key string) ServerID {
+	return s.pools.get(s.router.findPool(key)).SelectServer(key)
+}
+
+// SelectForDelete choose servers for deleting from the child route of the key
+func (s *prefixRouterSelector) SelectForDelete(key string) []ServerID {
+	return s.pools.get(s.router.findPool(key)).SelectForDelete(key)
+}
+
+// SkippedForDelete returns the skipped servers of the child route of the key
+func (s *prefixRouterSelector) SkippedForDelete(key string) []ServerID {
+	return s.pools.appendSkippedForDelete(nil, s.router.findPool(key), key)
+}
+
+// SelectWarmServer returns the warm server from the child route of the key
+func (s *prefixRouterSelector) SelectWarmServer(key string, server ServerID) (ServerID, bool) {
+	return s.pools.selectWarmServer([]int{s.router.findPool(key)}, key, server)
+}
+
+// Reset the selection
+func (s *prefixRouterSelector) Reset() {
+	s.pools.reset()
+}
@@ -0,0 +1,236 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/QuangTung97/memproxy"
+	"github.com/QuangTung97/memproxy/mocks"
+)
+
+func newPrefixRouterTest() (Route, *ServerStatsMock) {
+	stats := newComposedStats()
+	route := NewPrefixRouter([]PrefixRoute{
+		{Prefix: "sessions:", Route: NewReplicatedRoute([]ServerID{11, 12}, stats, alwaysRand(0))},
+		{Prefix: "sessions:admin:", Route: NewReplicatedRoute([]ServerID{21}, stats, alwaysRand(0))},
+		{Prefix: "catalog:", Route: NewShardedRoute([]ServerID{31, 32}, stats)},
+	}, NewReplicatedRoute([]ServerID{41}, stats, alwaysRand(0)))
+	return route, stats
+}
+
+func TestPrefixRouter(t *testing.T) {
+	t.Run("longest prefix match", func(t *testing.T) {
+		route, _ := newPrefixRouterTest()
+		selector := route.NewSelector()
+
+		assert.Equal(t, ServerID(11), selector.SelectServer("sessions:user01"))
+		assert.Equal(t, ServerID(21), selector.SelectServer("sessions:admin:user01"))
+		assert.Equal(t, ServerID(3), poolOfServer(selector.SelectServer("catalog:product01")))
+		assert.Equal(t, ServerID(41), selector.SelectServer("feeds:user01"))
+		assert.Equal(t, ServerID(41), selector.SelectServer("sessions"))
+
+		assert.Equal(t, []ServerID{11, 12}, selector.SelectForDelete("sessions:user01"))
+		assert.Equal(t, []ServerID{21}, selector.SelectForDelete("sessions:admin:user01"))
+		assert.Equal(t, []ServerID{41}, selector.SelectForDelete("feeds:user01"))
+
+		assert.Equal(t, []ServerID{11, 12, 21, 31, 32, 41}, route.AllServerIDs())
+	})
+
+	t.Run("failover inside child route", func(t *testing.T) {
+		route, stats := newPrefixRouterTest()
+		selector := route.NewSelector()
+
+		selector.SetFailedServer(11)
+		assert.Equal(t, true, selector.HasNextAvailableServer())
+		assert.Equal(t, ServerID(12), selector.SelectServer("sessions:user01"))
+		assert.Equal(t, []ServerID{12}, selector.SelectForDelete("sessions:user01"))
+
+		selector.SetFailedServer(41)
+		assert.Equal(t, false, selector.HasNextAvailableServer())
+
+		// other child routes are not affected
+		assert.Equal(t, ServerID(21), selector.SelectServer("sessions:admin:user01"))
+
+		assert.Equal(t, 2, len(stats.NotifyServerFailedCalls()))
+	})
+
+	t.Run("failover of server shared between child routes", func(t *testing.T) {
+		stats := newComposedStats()
+		route := NewPrefixRouter([]PrefixRoute{
+			{Prefix: "sessions:", Route: NewReplicatedRoute([]ServerID{11, 12}, stats, alwaysRand(0))},
+		}, NewReplicatedRoute([]ServerID{11}, stats, alwaysRand(0)))
+
+		selector := route.NewSelector()
+		keySelector := selector.(KeyFailoverSelector)
+
+		assert.Equal(t, ServerID(11), selector.SelectServer("sessions:user01"))
+		assert.Equal(t, ServerID(11), selector.SelectServer("feeds:user01"))
+
+		// the default route is exhausted, but the sessions route is not
+		selector.SetFailedServer(11)
+		assert.Equal(t, true, selector.HasNextAvailableServer())
+		assert.Equal(t, true, keySelector.HasNextAvailableServerForKey("sessions:user01"))
+		assert.Equal(t, false, keySelector.HasNextAvailableServerForKey("feeds:user01"))
+
+		assert.Equal(t, ServerID(12), selector.SelectServer("sessions:user01"))
+		assert.Equal(t, ServerID(11), selector.SelectServer("feeds:user01"))
+	})
+
+	t.Run("invalid config", func(t *testing.T) {
+		stats := newComposedStats()
+		defaultRoute := NewReplicatedRoute([]ServerID{41}, stats)
+
+		assert.Panics(t, func() {
+			NewPrefixRouter(nil, nil)
+		})
+		assert.Panics(t, func() {
+			NewPrefixRouter([]PrefixRoute{{Prefix: "", Route: defaultRoute}}, defaultRoute)
+		})
+		assert.Panics(t, func() {
+			NewPrefixRouter([]PrefixRoute{
+				{Prefix: "a:", Route: defaultRoute},
+				{Prefix: "a:", Route: defaultRoute},
+			}, defaultRoute)
+		})
+	})
+}
+
+func TestPrefixRouter_Pipeline_Batching_Per_Server(t *testing.T) {
+	stats := newComposedStats()
+	route := NewPrefixRouter([]PrefixRoute{
+		{Prefix: "sessions:", Route: NewReplicatedRoute([]ServerID{serverID1}, stats)},
+		{Prefix: "catalog:", Route: NewReplicatedRoute([]ServerID{serverID1, serverID2}, stats, alwaysRand(0))},
+	}, NewReplicatedRoute([]ServerID{serverID2}, stats))
+
+	var actions []string
+
+	newPipe := func(server ServerID) *mocks.PipelineMock {
+		return &mocks.PipelineMock{
+			ExecuteFunc: func() {
+				actions = append(actions, pipelineExecuteAction(server))
+			},
+			LeaseGetFunc: func(key string, options memproxy.LeaseGetOptions) memproxy.LeaseGetResult {
+				actions = append(actions, leaseGetAction(key))
+				return memproxy.LeaseGetResultFunc(func() (memproxy.LeaseGetResponse, error) {
+					return memproxy.LeaseGetResponse{Status: memproxy.LeaseGetStatusFound}, nil
+				})
+			},
+		}
+	}
+
+	pipe1 := newPipe(serverID1)
+	pipe2 := newPipe(serverID2)
+
+	mc1 := &mocks.MemcacheMock{
+		PipelineFunc: func(ctx context.Context, options ...memproxy.PipelineOption) memproxy.Pipeline {
+			return pipe1
+		},
+	}
+	mc2 := &mocks.MemcacheMock{
+		PipelineFunc: func(ctx context.Context, options ...memproxy.PipelineOption) memproxy.Pipeline {
+			return pipe2
+		},
+	}
+
+	mc, err := New[SimpleServerConfig](Config[SimpleServerConfig]{
+		Servers: []SimpleServerConfig{
+			{ID: serverID1, Host: "localhost", Port: 11211},
+			{ID: serverID2, Host: "localhost", Port: 11212},
+		},
+		Route: route,
+	}, func(conf SimpleServerConfig) memproxy.Memcache {
+		if conf.ID == serverID1 {
+			return mc1
+		}
+		return mc2
+	})
+	assert.Equal(t, nil, err)
+
+	pipe := mc.Pipeline(newContext())
+
+	fn1 := pipe.LeaseGet("sessions:user01", memproxy.LeaseGetOptions{})
+	fn2 := pipe.LeaseGet("catalog:product01", memproxy.LeaseGetOptions{})
+	fn3 := pipe.LeaseGet("feeds:user01", memproxy.LeaseGetOptions{})
+
+	_, err = fn1.Result()
+	assert.Equal(t, nil, err)
+	_, err = fn2.Result()
+	assert.Equal(t, nil, err)
+	_, err = fn3.Result()
+	assert.Equal(t, nil, err)
+
+	// one pipeline and one execute for each server, even the keys are from different child routes
+	assert.Equal(t, 1, len(mc1.PipelineCalls()))
+	assert.Equal(t, 1, len(mc2.PipelineCalls()))
+	assert.Equal(t, []string{
+		leaseGetAction("sessions:user01"),
+		leaseGetAction("catalog:product01"),
+		leaseGetAction("feeds:user01"),
+		pipelineExecuteAction(serverID1),
+		pipelineExecuteAction(serverID2),
+	}, actions)
+}
+
+func TestPrefixRouter_Pipeline_Failover_Per_Key(t *testing.T) {
+	stats := newComposedStats()
+	route := NewPrefixRouter([]PrefixRoute{
+		{Prefix: "sessions:", Route: NewReplicatedRoute([]ServerID{serverID1, serverID2}, stats, alwaysRand(0))},
+	}, NewReplicatedRoute([]ServerID{serverID1}, stats))
+
+	var leaseGetKeys []string
+
+	newMemcache := func(resp memproxy.LeaseGetResponse, err error) *mocks.MemcacheMock {
+		pipe := &mocks.PipelineMock{
+			ExecuteFunc: func() {},
+			LeaseGetFunc: func(key string, options memproxy.LeaseGetOptions) memproxy.LeaseGetResult {
+				leaseGetKeys = append(leaseGetKeys, key)
+				return memproxy.LeaseGetResultFunc(func() (memproxy.LeaseGetResponse, error) {
+					return resp, err
+				})
+			},
+		}
+		return &mocks.MemcacheMock{
+			PipelineFunc: func(ctx context.Context, options ...memproxy.PipelineOption) memproxy.Pipeline {
+				return pipe
+			},
+		}
+	}
+
+	serverErr := errors.New("server error")
+	mc1 := newMemcache(memproxy.LeaseGetResponse{}, serverErr)
+	mc2 := newMemcache(memproxy.LeaseGetResponse{Status: memproxy.LeaseGetStatusFound, CAS: 22}, nil)
+
+	mc, err := New[SimpleServerConfig](Config[SimpleServerConfig]{
+		Servers: []SimpleServerConfig{
+			{ID: serverID1, Host: "localhost", Port: 11211},
+			{ID: serverID2, Host: "localhost", Port: 11212},
+		},
+		Route: route,
+	}, func(conf SimpleServerConfig) memproxy.Memcache {
+		if conf.ID == serverID1 {
+			return mc1
+		}
+		return mc2
+	})
+	assert.Equal(t, nil, err)
+
+	pipe := mc.Pipeline(newContext())
+
+	// the last enqueued key is of the default route
+	fn1 := pipe.LeaseGet("sessions:user01", memproxy.LeaseGetOptions{})
+	fn2 := pipe.LeaseGet("feeds:user01", memproxy.LeaseGetOptions{})
+
+	resp, err := fn1.Result()
+	assert.Equal(t, nil, err)
+	assert.Equal(t, memproxy.LeaseGetResponse{Status: memproxy.LeaseGetStatusFound, CAS: 22}, resp)
+
+	_, err = fn2.Result()
+	assert.Equal(t, &ServerError{Server: serverID1, Err: serverErr}, err)
+
+	// the key of the default route is not retried
+	assert.Equal(t, []string{"sessions:user01", "feeds:user01", "sessions:user01"}, leaseGetKeys)
+	assert.Equal(t, 1, len(mc2.PipelineCalls()))
+}
@@ -4,6 +4,9 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
 	"unsafe"
 
 	"github.com/QuangTung97/go-memcache/memcache"
@@ -14,17 +17,62 @@ import (
 // Memcache is thread safe
 type Memcache struct {
 	sessProvider memproxy.SessionProvider
-	clients      map[ServerID]memproxy.Memcache
-	route        Route
+
+	// current state, replaced by UpdateConfig
+	state atomic.Pointer[memcacheState]
+
+	// serializes UpdateConfig and Close, for updating the stats and swapping the state together
+	updateMut sync.Mutex
+
+	mut sync.Mutex
+	// written with both updateMut and mut locked
+	closed bool
+
+	//revive:disable-next-line:nested-structs
+	liveClients map[*memcacheClient]struct{}
+
+	updateFunc func(conf any, updateStats bool) error
+
+	failover failoverConfig
+
+	invalidations *InvalidationQueue
+
+	latency LatencyObserver
+	results ResultObserver
+
+	nowFn func() time.Time
 }
 
 type memcacheConfig struct {
-	sessProvider memproxy.SessionProvider
+	sessProvider  memproxy.SessionProvider
+	stats         *SimpleServerStats
+	failover      failoverConfig
+	invalidations *InvalidationQueue
+	latency       LatencyObserver
+	results       ResultObserver
+	healthChecker *HealthChecker
+
+	nowFn func() time.Time
+}
+
+type failoverConfig struct {
+	// default 1
+	maxHops int
+
+	delay time.Duration
+
+	// negative means unlimited
+	budget int
 }
 
 func computeMemcacheConfig(options ...MemcacheOption) *memcacheConfig {
 	conf := &memcacheConfig{
 		sessProvider: memproxy.NewSessionProvider(),
+		failover: failoverConfig{
+			maxHops: 1,
+			budget:  -1,
+		},
+		nowFn: time.Now,
 	}
 	for _, fn := range options {
 		fn(conf)
@@ -35,6 +83,82 @@ func computeMemcacheConfig(options ...MemcacheOption) *memcacheConfig {
 // MemcacheOption ...
 type MemcacheOption func(conf *memcacheConfig)
 
+// WithMemcacheSimpleStats starts or stops the goroutines of the stats
+// to match the servers updated by UpdateConfig
+func WithMemcacheSimpleStats(stats *SimpleServerStats) MemcacheOption {
+	return func(conf *memcacheConfig) {
+		conf.stats = stats
+	}
+}
+
+// WithMemcacheMaxFailoverHops configures the max number of other servers a lease get is retried on
+// after the chosen server returned an error, default 1. Zero disables failover
+func WithMemcacheMaxFailoverHops(hops int) MemcacheOption {
+	return func(conf *memcacheConfig) {
+		conf.failover.maxHops = hops
+	}
+}
+
+// WithMemcacheFailoverDelay configures the delay before each failover hop, default no delay.
+// The delay is implemented by the delayed calls of the pipeline session
+func WithMemcacheFailoverDelay(d time.Duration) MemcacheOption {
+	return func(conf *memcacheConfig) {
+		conf.failover.delay = d
+	}
+}
+
+// WithMemcacheFailoverBudget configures the max number of failover hops of all lease gets in a pipeline,
+// for preventing a partial outage from amplifying the traffic to the remaining servers.
+// Negative means unlimited (the default)
+func WithMemcacheFailoverBudget(budget int) MemcacheOption {
+	return func(conf *memcacheConfig) {
+		conf.failover.budget = budget
+	}
+}
+
+// WithMemcacheInvalidationQueue records the deletes that failed or were skipped on servers to the queue.
+// Skipped servers are reported by the selectors implementing SkippedDeleteSelector.
+// The recorded deletes can be replayed by Memcache.ReplayInvalidations
+func WithMemcacheInvalidationQueue(queue *InvalidationQueue) MemcacheOption {
+	return func(conf *memcacheConfig) {
+		conf.invalidations = queue
+	}
+}
+
+// WithMemcacheLatencyObserver measures the round-trip times of servers from the executions of pipelines,
+// the time waiting for the first lease get result of each server after executing its pipeline.
+// The waiting for the results of other servers read before it is not included
+func WithMemcacheLatencyObserver(observer LatencyObserver) MemcacheOption {
+	return func(conf *memcacheConfig) {
+		conf.latency = observer
+	}
+}
+
+// WithMemcacheResultObserver reports the results (success or error) of the lease gets of servers,
+// e.g. to the HealthChecker for ejecting servers with high error rates
+func WithMemcacheResultObserver(observer ResultObserver) MemcacheOption {
+	return func(conf *memcacheConfig) {
+		conf.results = observer
+	}
+}
+
+// WithMemcacheHealthChecker reports the results of the lease gets to the checker (see WithMemcacheResultObserver),
+// and updates the servers of the checker to match the servers updated by UpdateConfig.
+// The checker MUST be created with the same server config type
+func WithMemcacheHealthChecker(checker *HealthChecker) MemcacheOption {
+	return func(conf *memcacheConfig) {
+		conf.results = checker
+		conf.healthChecker = checker
+	}
+}
+
+// WithMemcacheNowFunc configures the clock used for measuring latency, default time.Now
+func WithMemcacheNowFunc(nowFn func() time.Time) MemcacheOption {
+	return func(conf *memcacheConfig) {
+		conf.nowFn = nowFn
+	}
+}
+
 // WithMemcacheSessionProvider ...
 func WithMemcacheSessionProvider(provider memproxy.SessionProvider) MemcacheOption {
 	return func(conf *memcacheConfig) {
@@ -48,43 +172,112 @@ func New[S ServerConfig](
 	newFunc func(conf S) memproxy.Memcache,
 	options ...MemcacheOption,
 ) (*Memcache, error) {
-	if len(conf.Servers) == 0 {
-		return nil, errors.New("proxy: empty server list")
+	memcacheConf := computeMemcacheConfig(options...)
+
+	if memcacheConf.failover.maxHops < 0 {
+		return nil, errors.New("proxy: max failover hops must not be negative")
 	}
 
-	if conf.Route == nil {
-		return nil, errors.New("proxy: route is nil")
+	m := &Memcache{
+		sessProvider: memcacheConf.sessProvider,
+		liveClients:  map[*memcacheClient]struct{}{},
+		failover:     memcacheConf.failover,
+
+		invalidations: memcacheConf.invalidations,
+		latency:       memcacheConf.latency,
+		results:       memcacheConf.results,
+
+		nowFn: memcacheConf.nowFn,
 	}
 
-	memcacheConf := computeMemcacheConfig(options...)
+	m.updateFunc = func(anyConf any, updateStats bool) error {
+		conf, ok := anyConf.(Config[S])
+		if !ok {
+			return fmt.Errorf("proxy: invalid config type %T", anyConf)
+		}
+
+		if err := validateConfig(conf); err != nil {
+			return err
+		}
 
-	clients := map[ServerID]memproxy.Memcache{}
+		m.updateMut.Lock()
+		defer m.updateMut.Unlock()
+
+		if m.closed {
+			return errors.New("proxy: memcache already closed")
+		}
+
+		if updateStats {
+			if err := memcacheConf.updateServers(conf.Servers); err != nil {
+				return err
+			}
+		}
+
+		servers := make([]ServerID, 0, len(conf.Servers))
+		configs := make([]any, 0, len(conf.Servers))
+		for _, server := range conf.Servers {
+			servers = append(servers, server.GetID())
+			configs = append(configs, server)
+		}
+
+		m.swapState(servers, configs, conf.Route, func(index int) memproxy.Memcache {
+			return newFunc(conf.Servers[index])
+		})
+		return nil
+	}
+
+	if err := m.updateFunc(conf, false); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
 
+// updateServers updates the servers of the stats and the health checker
+func (c *memcacheConfig) updateServers(servers any) error {
+	if c.stats != nil {
+		if err := c.stats.UpdateServers(servers); err != nil {
+			return err
+		}
+	}
+	if c.healthChecker != nil {
+		if err := c.healthChecker.UpdateServers(servers); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func validateConfig[S ServerConfig](conf Config[S]) error {
+	if len(conf.Servers) == 0 {
+		return errors.New("proxy: empty server list")
+	}
+
+	if conf.Route == nil {
+		return errors.New("proxy: route is nil")
+	}
+
+	serverSet := map[ServerID]struct{}{}
 	for _, server := range conf.Servers {
-		client := newFunc(server)
-		clients[server.GetID()] = client
+		serverSet[server.GetID()] = struct{}{}
 	}
 
-	allServerIDs := conf.Route.AllServerIDs()
-	for _, serverID := range allServerIDs {
-		_, ok := clients[serverID]
+	for _, serverID := range conf.Route.AllServerIDs() {
+		_, ok := serverSet[serverID]
 		if !ok {
-			return nil, fmt.Errorf("proxy: server id '%d' not in server list", serverID)
+			return fmt.Errorf("proxy: server id '%d' not in server list", serverID)
 		}
 	}
-
-	return &Memcache{
-		sessProvider: memcacheConf.sessProvider,
-		clients:      clients,
-		route:        conf.Route,
-	}, nil
+	return nil
 }
 
 // Pipeline is NOT thread safe
 type Pipeline struct {
 	ctx context.Context
 
-	client   *Memcache
+	// the clients and route of the pipeline, unchanged by UpdateConfig until Finish is called
+	state    *memcacheState
+	released bool
+
 	selector Selector
 
 	sess        memproxy.Session
@@ -97,6 +290,21 @@ type Pipeline struct {
 	needExecServerSet map[ServerID]struct{}
 
 	leaseSetServers map[string]leaseSetState
+
+	failover failoverConfig
+
+	// remaining failover hops of the pipeline, negative means unlimited
+	failoverBudget int
+
+	invalidations *InvalidationQueue
+
+	latency LatencyObserver
+	results ResultObserver
+
+	// the execution start times of the server pipelines, for measuring latency
+	execStartedAt map[ServerID]time.Time
+
+	nowFn func() time.Time
 }
 
 type leaseSetState struct {
@@ -111,11 +319,13 @@ func (m *Memcache) Pipeline(
 	conf := memproxy.ComputePipelineConfig(options)
 	sess := conf.GetSession(m.sessProvider)
 
+	state := m.acquireState()
+
 	return &Pipeline{
 		ctx: ctx,
 
-		client:   m,
-		selector: m.route.NewSelector(),
+		state:    state,
+		selector: state.route.NewSelector(),
 
 		pipeSession: sess,
 		sess:        sess.GetLower(),
@@ -123,25 +333,69 @@ func (m *Memcache) Pipeline(
 		pipelines: map[ServerID]memproxy.Pipeline{},
 
 		leaseSetServers: map[string]leaseSetState{},
+
+		failover:       m.failover,
+		failoverBudget: m.failover.budget,
+
+		invalidations: m.invalidations,
+		latency:       m.latency,
+		results:       m.results,
+
+		nowFn: m.nowFn,
 	}
 }
 
+// ServerMemcache returns the client of a single server, bypassing the route.
+// Often used for warming up a specific server (e.g. after restarted).
+// The returned client MUST NOT be closed, it is closed by Memcache.Close
+// or when the server is removed by UpdateConfig
+func (m *Memcache) ServerMemcache(server ServerID) (memproxy.Memcache, error) {
+	client, ok := m.state.Load().clients[server]
+	if !ok {
+		return nil, fmt.Errorf("proxy: server id '%d' not in server list", server)
+	}
+	return client.client, nil
+}
+
+// ReplayInvalidations deletes the keys recorded in the invalidation queue for the server,
+// often called when the server is healthy again (see WithSimpleStatsRecoveredFunc)
+func (m *Memcache) ReplayInvalidations(ctx context.Context, server ServerID) error {
+	if m.invalidations == nil {
+		return errors.New("proxy: invalidation queue not configured")
+	}
+
+	client, err := m.ServerMemcache(server)
+	if err != nil {
+		return err
+	}
+	return m.invalidations.Replay(ctx, server, client)
+}
+
 // Close ...
 func (m *Memcache) Close() error {
+	m.updateMut.Lock()
+	defer m.updateMut.Unlock()
+
+	m.mut.Lock()
+	defer m.mut.Unlock()
+
+	m.closed = true
+
 	var lastErr error
-	for _, client := range m.clients {
-		err := client.Close()
+	for client := range m.liveClients {
+		err := client.client.Close()
 		if err != nil {
 			lastErr = err
 		}
 	}
+	m.liveClients = nil
 	return lastErr
 }
 
 func (p *Pipeline) getRoutePipeline(serverID ServerID) memproxy.Pipeline {
 	pipe, existed := p.pipelines[serverID]
 	if !existed {
-		pipe = p.client.clients[serverID].Pipeline(p.ctx, memproxy.WithPipelineExistingSession(p.pipeSession))
+		pipe = p.state.clients[serverID].client.Pipeline(p.ctx, memproxy.WithPipelineExistingSession(p.pipeSession))
 		p.pipelines[serverID] = pipe
 	}
 
@@ -159,6 +413,16 @@ func (p *Pipeline) getRoutePipeline(serverID ServerID) memproxy.Pipeline {
 }
 
 func (p *Pipeline) doExecuteForAllServers() {
+	if p.latency != nil && len(p.needExecServers) > 0 {
+		if p.execStartedAt == nil {
+			p.execStartedAt = map[ServerID]time.Time{}
+		}
+		now := p.nowFn()
+		for _, server := range p.needExecServers {
+			p.execStartedAt[server] = now
+		}
+	}
+
 	for _, server := range p.needExecServers {
 		pipe := p.pipelines[server]
 		pipe.Execute()
@@ -167,6 +431,53 @@ func (p *Pipeline) doExecuteForAllServers() {
 	p.needExecServerSet = nil
 }
 
+// getLeaseResult gets the lease get result of the server, measuring the latency of the first result
+// of each execution from the later of the execution start and the start of the call,
+// so the time spent on the results of other servers read before is not included
+func (p *Pipeline) getLeaseResult(server ServerID, fn memproxy.LeaseGetResult) (memproxy.LeaseGetResponse, error) {
+	if p.latency == nil {
+		return fn.Result()
+	}
+
+	calledAt := p.nowFn()
+	resp, err := fn.Result()
+	if err != nil {
+		return resp, err
+	}
+
+	startedAt, ok := p.execStartedAt[server]
+	if !ok {
+		return resp, err
+	}
+	// only the first response of each execution is measured
+	delete(p.execStartedAt, server)
+
+	if calledAt.After(startedAt) {
+		startedAt = calledAt
+	}
+	p.latency.ObserveLatency(server, p.nowFn().Sub(startedAt))
+
+	return resp, err
+}
+
+func (p *Pipeline) hasNextAvailableServer(key string) bool {
+	if sel, ok := p.selector.(KeyFailoverSelector); ok {
+		return sel.HasNextAvailableServerForKey(key)
+	}
+	return p.selector.HasNextAvailableServer()
+}
+
+func (p *Pipeline) useFailoverBudget() bool {
+	if p.failoverBudget < 0 {
+		return true
+	}
+	if p.failoverBudget == 0 {
+		return false
+	}
+	p.failoverBudget--
+	return true
+}
+
 func (p *Pipeline) setKeyForLeaseSet(
 	key string,
 	resp memproxy.LeaseGetResponse,
@@ -198,6 +509,9 @@ type leaseGetState struct {
 
 	fn memproxy.LeaseGetResult
 
+	// number of failover hops done
+	hops int
+
 	resp memproxy.LeaseGetResponse
 	err  error
 }
@@ -208,14 +522,41 @@ func retryOnOtherNodeCallback(obj unsafe.Pointer) {
 }
 
 func (s *leaseGetState) retryOnOtherNode() {
-	s.pipe.doExecuteForAllServers()
+	s.serverID = s.pipe.selector.SelectServer(s.key)
 
-	s.resp, s.err = s.fn.Result()
-	s.fn = nil
+	pipe := s.pipe.getRoutePipeline(s.serverID)
+	s.fn = pipe.LeaseGet(s.key, s.options)
 
-	if s.err == nil {
-		s.pipe.setKeyForLeaseSet(s.key, s.resp, s.serverID)
+	s.pipe.sess.AddNextCall(memproxy.CallbackFunc{
+		Object: unsafe.Pointer(s),
+		Func:   leaseGetStateNextFuncCallback,
+	})
+}
+
+func (s *leaseGetState) failover() {
+	if s.hops >= s.pipe.failover.maxHops {
+		return
+	}
+
+	s.pipe.selector.SetFailedServer(s.serverID)
+	if !s.pipe.hasNextAvailableServer(s.key) {
+		return
+	}
+
+	if !s.pipe.useFailoverBudget() {
+		return
+	}
+	s.hops++
+
+	if s.pipe.failover.delay > 0 {
+		s.pipe.sess.AddDelayedCall(s.pipe.failover.delay, memproxy.CallbackFunc{
+			Object: unsafe.Pointer(s),
+			Func:   retryOnOtherNodeCallback,
+		})
+		return
 	}
+
+	s.retryOnOtherNode()
 }
 
 func leaseGetStateNextFuncCallback(obj unsafe.Pointer) {
@@ -226,29 +567,77 @@ func leaseGetStateNextFuncCallback(obj unsafe.Pointer) {
 func (s *leaseGetState) nextFunc() {
 	s.pipe.doExecuteForAllServers()
 
-	s.resp, s.err = s.fn.Result()
+	s.resp, s.err = s.pipe.getLeaseResult(s.serverID, s.fn)
 	s.fn = nil
 
+	if s.pipe.results != nil {
+		s.pipe.results.ObserveResult(s.serverID, s.err)
+	}
+
 	if s.err != nil {
-		s.pipe.selector.SetFailedServer(s.serverID)
-		if !s.pipe.selector.HasNextAvailableServer() {
-			return
-		}
+		s.failover()
+		return
+	}
 
-		s.serverID = s.pipe.selector.SelectServer(s.key)
+	s.handleResponse()
+}
 
-		pipe := s.pipe.getRoutePipeline(s.serverID)
-		s.fn = pipe.LeaseGet(s.key, s.options)
+func (s *leaseGetState) handleResponse() {
+	if s.resp.Status == memproxy.LeaseGetStatusLeaseGranted && s.startWarmUp() {
+		return
+	}
+	s.pipe.setKeyForLeaseSet(s.key, s.resp, s.serverID)
+}
 
-		s.pipe.sess.AddNextCall(memproxy.CallbackFunc{
-			Object: unsafe.Pointer(s),
-			Func:   retryOnOtherNodeCallback,
-		})
+// startWarmUp reads the key missed on a cold server from a warm server, see WarmUpSelector
+func (s *leaseGetState) startWarmUp() bool {
+	sel, ok := s.pipe.selector.(WarmUpSelector)
+	if !ok {
+		return false
+	}
+
+	warmServer, ok := sel.SelectWarmServer(s.key, s.serverID)
+	if !ok || warmServer == s.serverID {
+		return false
+	}
+
+	pipe := s.pipe.getRoutePipeline(warmServer)
+	s.fn = pipe.LeaseGet(s.key, memproxy.LeaseGetOptions{NoLease: true})
 
+	s.pipe.sess.AddNextCall(memproxy.CallbackFunc{
+		Object: unsafe.Pointer(s),
+		Func:   warmUpCallback,
+	})
+	return true
+}
+
+func warmUpCallback(obj unsafe.Pointer) {
+	s := (*leaseGetState)(obj)
+	s.warmUp()
+}
+
+func (s *leaseGetState) warmUp() {
+	s.pipe.doExecuteForAllServers()
+
+	warmResp, err := s.fn.Result()
+	s.fn = nil
+
+	if err != nil || warmResp.Status != memproxy.LeaseGetStatusFound {
+		// errors of the warm server are ignored, the key is filled from the backing source as normal
+		s.pipe.setKeyForLeaseSet(s.key, s.resp, s.serverID)
 		return
 	}
 
-	s.pipe.setKeyForLeaseSet(s.key, s.resp, s.serverID)
+	// set back to the cold server using the granted lease,
+	// will be executed in the next execution of the pipeline
+	pipe := s.pipe.getRoutePipeline(s.serverID)
+	pipe.LeaseSet(s.key, warmResp.Data, s.resp.CAS, memproxy.LeaseSetOptions{})
+
+	s.resp = memproxy.LeaseGetResponse{
+		Status: memproxy.LeaseGetStatusFound,
+		CAS:    warmResp.CAS,
+		Data:   warmResp.Data,
+	}
 }
 
 func (s *leaseGetState) Result() (memproxy.LeaseGetResponse, error) {
@@ -256,6 +645,9 @@ func (s *leaseGetState) Result() (memproxy.LeaseGetResponse, error) {
 	s.pipe.selector.Reset()
 
 	resp, err := s.resp, s.err
+	if err != nil {
+		err = &ServerError{Server: s.serverID, Err: err}
+	}
 
 	putLeaseGetState(s)
 
@@ -295,12 +687,21 @@ func (p *Pipeline) LeaseSet(
 ) func() (memproxy.LeaseSetResponse, error) {
 	setState, ok := p.leaseSetServers[key]
 	if !ok || !setState.valid {
+		// the server of the lease is unknown or has changed
 		return func() (memproxy.LeaseSetResponse, error) {
-			return memproxy.LeaseSetResponse{}, nil
+			return memproxy.LeaseSetResponse{Status: memproxy.LeaseSetStatusNotStored}, nil
 		}
 	}
 	pipe := p.getRoutePipeline(setState.serverID)
-	return pipe.LeaseSet(key, data, cas, options)
+	fn := pipe.LeaseSet(key, data, cas, options)
+
+	return func() (memproxy.LeaseSetResponse, error) {
+		resp, err := fn()
+		if err != nil {
+			return resp, &ServerError{Server: setState.serverID, Err: err}
+		}
+		return resp, nil
+	}
 }
 
 // Delete ...
@@ -313,12 +714,23 @@ func (p *Pipeline) Delete(
 		fnList = append(fnList, p.getRoutePipeline(id).Delete(key, options))
 	}
 
+	if p.invalidations != nil {
+		if sel, ok := p.selector.(SkippedDeleteSelector); ok {
+			for _, id := range sel.SkippedForDelete(key) {
+				p.invalidations.Add(id, key)
+			}
+		}
+	}
+
 	return func() (memproxy.DeleteResponse, error) {
 		var lastErr error
-		for _, fn := range fnList {
+		for index, fn := range fnList {
 			_, err := fn()
 			if err != nil {
-				lastErr = err
+				lastErr = &ServerError{Server: serverIDs[index], Err: err}
+				if p.invalidations != nil {
+					p.invalidations.Add(serverIDs[index], key)
+				}
 			}
 		}
 		return memproxy.DeleteResponse{}, lastErr
@@ -338,6 +750,11 @@ func (p *Pipeline) Finish() {
 	}
 	p.needExecServers = nil
 	p.needExecServerSet = nil
+
+	if !p.released {
+		p.released = true
+		p.state.release()
+	}
 }
 
 // LowerSession returns a lower priority session
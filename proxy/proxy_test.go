@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 
@@ -71,7 +72,7 @@ func newContext() context.Context {
 	return context.WithValue(context.Background(), ctxKey, "some value")
 }
 
-func newPipelineTest(t *testing.T) *pipelineTest {
+func newPipelineTest(t *testing.T, options ...MemcacheOption) *pipelineTest {
 	p := &pipelineTest{}
 
 	mc1 := &mocks.MemcacheMock{}
@@ -140,7 +141,7 @@ func newPipelineTest(t *testing.T) *pipelineTest {
 			mc1,
 			mc2,
 		}[index]
-	})
+	}, options...)
 	assert.Equal(t, nil, err)
 	assert.Equal(t, []SimpleServerConfig{server1, server2}, newCalls)
 
@@ -367,7 +368,11 @@ func TestPipeline(t *testing.T) {
 		fn := p.pipe.LeaseGet("KEY01", memproxy.LeaseGetOptions{})
 		resp, err := fn.Result()
 
-		assert.Equal(t, getError, err)
+		assert.Equal(t, &ServerError{
+			Server: serverID1,
+			Err:    getError,
+		}, err)
+		assert.Equal(t, "proxy: server id '31': some error", err.Error())
 		assert.Equal(t, memproxy.LeaseGetResponse{}, resp)
 
 		// Check Init New Pipeline
@@ -459,7 +464,7 @@ func TestPipeline__LeaseGet_Then_Set(t *testing.T) {
 		setResp, err := setFn()
 
 		assert.Equal(t, nil, err)
-		assert.Equal(t, memproxy.LeaseSetResponse{}, setResp)
+		assert.Equal(t, memproxy.LeaseSetResponse{Status: memproxy.LeaseSetStatusNotStored}, setResp)
 
 		setCalls := p.pipe1.LeaseSetCalls()
 		assert.Equal(t, 0, len(setCalls))
@@ -578,7 +583,7 @@ func TestPipeline__LeaseGet_Then_Set(t *testing.T) {
 		setResp, err := setFn()
 
 		assert.Equal(t, nil, err)
-		assert.Equal(t, memproxy.LeaseSetResponse{}, setResp)
+		assert.Equal(t, memproxy.LeaseSetResponse{Status: memproxy.LeaseSetStatusNotStored}, setResp)
 
 		get1Calls := p.pipe1.LeaseGetCalls()
 		assert.Equal(t, 2, len(get1Calls))
@@ -671,7 +676,7 @@ func TestPipeline__LeaseGet_Then_Set(t *testing.T) {
 		setResp, err := setFn()
 
 		assert.Equal(t, nil, err)
-		assert.Equal(t, memproxy.LeaseSetResponse{}, setResp)
+		assert.Equal(t, memproxy.LeaseSetResponse{Status: memproxy.LeaseSetStatusNotStored}, setResp)
 
 		get1Calls := p.pipe1.LeaseGetCalls()
 		assert.Equal(t, 3, len(get1Calls))
@@ -737,6 +742,420 @@ func TestPipeline__LeaseGet_Multi(t *testing.T) {
 	})
 }
 
+type warmUpSelectorTest struct {
+	*SelectorMock
+	warmServers map[ServerID]ServerID
+}
+
+func (s *warmUpSelectorTest) SelectWarmServer(_ string, server ServerID) (ServerID, bool) {
+	warm, ok := s.warmServers[server]
+	return warm, ok
+}
+
+func (p *pipelineTest) stubWarmServers(warmServers map[ServerID]ServerID) {
+	p.route.NewSelectorFunc = func() Selector {
+		return &warmUpSelectorTest{
+			SelectorMock: p.selector,
+			warmServers:  warmServers,
+		}
+	}
+	p.pipe = p.client.Pipeline(newContext())
+}
+
+func TestPipeline__LeaseGet_Warm_Up(t *testing.T) {
+	t.Run("found-on-warm-server--set-back-to-cold-server", func(t *testing.T) {
+		p := newPipelineTest(t)
+		p.stubWarmServers(map[ServerID]ServerID{serverID1: serverID2})
+
+		p.stubSelect(serverID1)
+		p.stubLeaseGet1(memproxy.LeaseGetResponse{
+			Status: memproxy.LeaseGetStatusLeaseGranted,
+			CAS:    2255,
+		}, nil)
+		p.stubLeaseGet2(memproxy.LeaseGetResponse{
+			Status: memproxy.LeaseGetStatusFound,
+			CAS:    3311,
+			Data:   []byte("warm data"),
+		}, nil)
+		p.stubLeaseSet1(memproxy.LeaseSetResponse{}, nil)
+
+		resp, err := p.pipe.LeaseGet("KEY01", memproxy.LeaseGetOptions{}).Result()
+		assert.Equal(t, nil, err)
+		assert.Equal(t, memproxy.LeaseGetResponse{
+			Status: memproxy.LeaseGetStatusFound,
+			CAS:    3311,
+			Data:   []byte("warm data"),
+		}, resp)
+
+		getCalls := p.pipe2.LeaseGetCalls()
+		assert.Equal(t, 1, len(getCalls))
+		assert.Equal(t, memproxy.LeaseGetOptions{NoLease: true}, getCalls[0].Options)
+
+		setCalls := p.pipe1.LeaseSetCalls()
+		assert.Equal(t, 1, len(setCalls))
+		assert.Equal(t, "KEY01", setCalls[0].Key)
+		assert.Equal(t, uint64(2255), setCalls[0].Cas)
+		assert.Equal(t, []byte("warm data"), setCalls[0].Data)
+
+		p.pipe.Execute()
+
+		assert.Equal(t, []string{
+			leaseGetAction("KEY01"),
+			pipelineExecuteAction(serverID1),
+			leaseGetFuncAction("KEY01"),
+			leaseGetAction("KEY01"),
+			pipelineExecuteAction(serverID2),
+			leaseGetFuncAction("KEY01"),
+			leaseSetAction("KEY01"),
+			pipelineExecuteAction(serverID1),
+		}, p.actions)
+
+		// the lease was used by the warm up
+		setResp, err := p.pipe.LeaseSet("KEY01", []byte("db data"), 2255, memproxy.LeaseSetOptions{})()
+		assert.Equal(t, nil, err)
+		assert.Equal(t, memproxy.LeaseSetResponse{Status: memproxy.LeaseSetStatusNotStored}, setResp)
+		assert.Equal(t, 1, len(p.pipe1.LeaseSetCalls()))
+	})
+
+	t.Run("not-found-on-warm-server--return-granted", func(t *testing.T) {
+		p := newPipelineTest(t)
+		p.stubWarmServers(map[ServerID]ServerID{serverID1: serverID2})
+
+		p.stubSelect(serverID1)
+		p.stubLeaseGet1(memproxy.LeaseGetResponse{
+			Status: memproxy.LeaseGetStatusLeaseGranted,
+			CAS:    2255,
+		}, nil)
+		p.stubLeaseGet2(memproxy.LeaseGetResponse{
+			Status: memproxy.LeaseGetStatusNotFound,
+		}, nil)
+
+		resp, err := p.pipe.LeaseGet("KEY01", memproxy.LeaseGetOptions{}).Result()
+		assert.Equal(t, nil, err)
+		assert.Equal(t, memproxy.LeaseGetResponse{
+			Status: memproxy.LeaseGetStatusLeaseGranted,
+			CAS:    2255,
+		}, resp)
+
+		// lease set goes to the cold server
+		p.stubLeaseSet1(memproxy.LeaseSetResponse{Status: memproxy.LeaseSetStatusStored}, nil)
+
+		setResp, err := p.pipe.LeaseSet("KEY01", []byte("db data"), 2255, memproxy.LeaseSetOptions{})()
+		assert.Equal(t, nil, err)
+		assert.Equal(t, memproxy.LeaseSetResponse{Status: memproxy.LeaseSetStatusStored}, setResp)
+		assert.Equal(t, 0, len(p.pipe2.LeaseSetCalls()))
+	})
+
+	t.Run("error-on-warm-server--return-granted", func(t *testing.T) {
+		p := newPipelineTest(t)
+		p.stubWarmServers(map[ServerID]ServerID{serverID1: serverID2})
+
+		p.stubSelect(serverID1)
+		p.stubLeaseGet1(memproxy.LeaseGetResponse{
+			Status: memproxy.LeaseGetStatusLeaseGranted,
+			CAS:    2255,
+		}, nil)
+		p.stubLeaseGet2(memproxy.LeaseGetResponse{}, errors.New("server error"))
+
+		resp, err := p.pipe.LeaseGet("KEY01", memproxy.LeaseGetOptions{}).Result()
+		assert.Equal(t, nil, err)
+		assert.Equal(t, memproxy.LeaseGetResponse{
+			Status: memproxy.LeaseGetStatusLeaseGranted,
+			CAS:    2255,
+		}, resp)
+		assert.Equal(t, 0, len(p.selector.SetFailedServerCalls()))
+	})
+
+	t.Run("found-on-cold-server--no-warm-up", func(t *testing.T) {
+		p := newPipelineTest(t)
+		p.stubWarmServers(map[ServerID]ServerID{serverID1: serverID2})
+
+		p.stubSelect(serverID1)
+		p.stubLeaseGet1(memproxy.LeaseGetResponse{
+			Status: memproxy.LeaseGetStatusFound,
+			CAS:    2255,
+			Data:   []byte("cold data"),
+		}, nil)
+
+		resp, err := p.pipe.LeaseGet("KEY01", memproxy.LeaseGetOptions{}).Result()
+		assert.Equal(t, nil, err)
+		assert.Equal(t, []byte("cold data"), resp.Data)
+		assert.Equal(t, 0, len(p.pipe2.LeaseGetCalls()))
+	})
+}
+
+func TestPipeline__LeaseGet_Failover(t *testing.T) {
+	serverErr := errors.New("server error")
+
+	t.Run("multi-hops", func(t *testing.T) {
+		p := newPipelineTest(t, WithMemcacheMaxFailoverHops(2))
+
+		p.stubSelect(serverID1, serverID2, serverID1)
+		p.stubHasNextAvail(true)
+
+		p.stubLeaseGetMulti(p.pipe1, []memproxy.LeaseGetResponse{
+			{},
+			{Status: memproxy.LeaseGetStatusFound, Data: []byte("data 01")},
+		}, []error{serverErr, nil})
+		p.stubLeaseGet2(memproxy.LeaseGetResponse{}, serverErr)
+
+		resp, err := p.pipe.LeaseGet("KEY01", memproxy.LeaseGetOptions{}).Result()
+		assert.Equal(t, nil, err)
+		assert.Equal(t, memproxy.LeaseGetResponse{
+			Status: memproxy.LeaseGetStatusFound,
+			Data:   []byte("data 01"),
+		}, resp)
+
+		failedCalls := p.selector.SetFailedServerCalls()
+		assert.Equal(t, 2, len(failedCalls))
+		assert.Equal(t, serverID1, failedCalls[0].Server)
+		assert.Equal(t, serverID2, failedCalls[1].Server)
+
+		assert.Equal(t, []string{
+			leaseGetAction("KEY01"),
+			pipelineExecuteAction(serverID1),
+			leaseGetFuncAction("KEY01"),
+			leaseGetAction("KEY01"),
+			pipelineExecuteAction(serverID2),
+			leaseGetFuncAction("KEY01"),
+			leaseGetAction("KEY01"),
+			pipelineExecuteAction(serverID1),
+			leaseGetFuncAction("KEY01"),
+		}, p.actions)
+	})
+
+	t.Run("max-hops-reached", func(t *testing.T) {
+		p := newPipelineTest(t, WithMemcacheMaxFailoverHops(2))
+
+		p.stubSelect(serverID1, serverID2, serverID1)
+		p.stubHasNextAvail(true)
+
+		p.stubLeaseGet1(memproxy.LeaseGetResponse{}, serverErr)
+		p.stubLeaseGet2(memproxy.LeaseGetResponse{}, serverErr)
+
+		_, err := p.pipe.LeaseGet("KEY01", memproxy.LeaseGetOptions{}).Result()
+		assert.Equal(t, &ServerError{Server: serverID1, Err: serverErr}, err)
+
+		assert.Equal(t, 2, len(p.pipe1.LeaseGetCalls()))
+		assert.Equal(t, 1, len(p.pipe2.LeaseGetCalls()))
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		p := newPipelineTest(t, WithMemcacheMaxFailoverHops(0))
+
+		p.stubSelect(serverID1)
+		p.stubHasNextAvail(true)
+		p.stubLeaseGet1(memproxy.LeaseGetResponse{}, serverErr)
+
+		_, err := p.pipe.LeaseGet("KEY01", memproxy.LeaseGetOptions{}).Result()
+		assert.Equal(t, &ServerError{Server: serverID1, Err: serverErr}, err)
+		assert.Equal(t, 0, len(p.pipe2.LeaseGetCalls()))
+	})
+
+	t.Run("budget-of-pipeline", func(t *testing.T) {
+		p := newPipelineTest(t, WithMemcacheFailoverBudget(1))
+
+		p.stubSelect(serverID1, serverID1, serverID2)
+		p.stubHasNextAvail(true)
+
+		p.stubLeaseGet1(memproxy.LeaseGetResponse{}, serverErr)
+		p.stubLeaseGet2(memproxy.LeaseGetResponse{Status: memproxy.LeaseGetStatusFound}, nil)
+
+		fn1 := p.pipe.LeaseGet("KEY01", memproxy.LeaseGetOptions{})
+		fn2 := p.pipe.LeaseGet("KEY02", memproxy.LeaseGetOptions{})
+
+		resp, err := fn1.Result()
+		assert.Equal(t, nil, err)
+		assert.Equal(t, memproxy.LeaseGetStatusFound, resp.Status)
+
+		// budget exhausted
+		_, err = fn2.Result()
+		assert.Equal(t, &ServerError{Server: serverID1, Err: serverErr}, err)
+
+		assert.Equal(t, 1, len(p.pipe2.LeaseGetCalls()))
+	})
+
+	t.Run("with-delay", func(t *testing.T) {
+		p := newPipelineTest(t, WithMemcacheFailoverDelay(20*time.Millisecond))
+
+		p.stubSelect(serverID1, serverID2)
+		p.stubHasNextAvail(true)
+
+		p.stubLeaseGet1(memproxy.LeaseGetResponse{}, serverErr)
+		p.stubLeaseGet2(memproxy.LeaseGetResponse{Status: memproxy.LeaseGetStatusFound}, nil)
+
+		start := time.Now()
+		resp, err := p.pipe.LeaseGet("KEY01", memproxy.LeaseGetOptions{}).Result()
+		assert.Equal(t, nil, err)
+		assert.Equal(t, memproxy.LeaseGetStatusFound, resp.Status)
+		assert.GreaterOrEqual(t, time.Since(start), 20*time.Millisecond)
+	})
+
+	t.Run("negative-hops", func(t *testing.T) {
+		mc, err := New[SimpleServerConfig](Config[SimpleServerConfig]{
+			Servers: []SimpleServerConfig{{ID: serverID1, Host: "localhost", Port: 11211}},
+			Route:   NewReplicatedRoute([]ServerID{serverID1}, &ServerStatsMock{}),
+		}, func(conf SimpleServerConfig) memproxy.Memcache {
+			return &mocks.MemcacheMock{}
+		}, WithMemcacheMaxFailoverHops(-1))
+		assert.Nil(t, mc)
+		assert.Equal(t, errors.New("proxy: max failover hops must not be negative"), err)
+	})
+}
+
+type skippedDeleteSelectorTest struct {
+	*SelectorMock
+	skipped []ServerID
+}
+
+func (s *skippedDeleteSelectorTest) SkippedForDelete(string) []ServerID {
+	return s.skipped
+}
+
+func TestPipeline__Delete_Invalidation_Queue(t *testing.T) {
+	t.Run("record-failed-and-skipped-servers", func(t *testing.T) {
+		queue, err := NewInvalidationQueue()
+		assert.Equal(t, nil, err)
+
+		p := newPipelineTest(t, WithMemcacheInvalidationQueue(queue))
+		p.route.NewSelectorFunc = func() Selector {
+			return &skippedDeleteSelectorTest{
+				SelectorMock: p.selector,
+				skipped:      []ServerID{serverID2},
+			}
+		}
+		p.pipe = p.client.Pipeline(newContext())
+
+		p.stubSelectForDelete(serverID1)
+		p.stubPipeDelete(p.pipe1, errors.New("server error"))
+
+		_, err = p.pipe.Delete("KEY01", memproxy.DeleteOptions{})()
+		assert.Equal(t, &ServerError{Server: serverID1, Err: errors.New("server error")}, err)
+
+		assert.Equal(t, 2, queue.Size())
+		assert.Equal(t, 1, queue.ServerSize(serverID1))
+		assert.Equal(t, 1, queue.ServerSize(serverID2))
+
+		// replay
+		p.stubPipeDelete(p.pipe2, nil)
+		p.pipe2.FinishFunc = func() {}
+
+		err = p.client.(*Memcache).ReplayInvalidations(newContext(), serverID2)
+		assert.Equal(t, nil, err)
+		assert.Equal(t, 1, queue.Size())
+		assert.Equal(t, "KEY01", p.pipe2.DeleteCalls()[0].Key)
+	})
+
+	t.Run("replay-without-queue", func(t *testing.T) {
+		p := newPipelineTest(t)
+
+		err := p.client.(*Memcache).ReplayInvalidations(newContext(), serverID1)
+		assert.Equal(t, errors.New("proxy: invalidation queue not configured"), err)
+	})
+}
+
+type latencyObserverTest struct {
+	servers   []ServerID
+	latencies []time.Duration
+}
+
+func (l *latencyObserverTest) ObserveLatency(server ServerID, d time.Duration) {
+	l.servers = append(l.servers, server)
+	l.latencies = append(l.latencies, d)
+}
+
+func TestPipeline__LeaseGet_Latency(t *testing.T) {
+	t.Run("observe-first-response-of-each-execution", func(t *testing.T) {
+		now := time.Date(2023, 5, 1, 10, 0, 0, 0, time.UTC)
+
+		observer := &latencyObserverTest{}
+		p := newPipelineTest(t,
+			WithMemcacheLatencyObserver(observer),
+			WithMemcacheResultObserver(resultObserverFunc(func(server ServerID, err error) {
+				// not included in the latency
+				now = now.Add(100 * time.Millisecond)
+			})),
+			WithMemcacheNowFunc(func() time.Time {
+				return now
+			}),
+		)
+
+		p.stubSelect(serverID1, serverID1, serverID2)
+
+		// the time waiting for the responses
+		stubLeaseGet := func(pipe *mocks.PipelineMock, d time.Duration) {
+			pipe.LeaseGetFunc = func(key string, options memproxy.LeaseGetOptions) memproxy.LeaseGetResult {
+				return memproxy.LeaseGetResultFunc(func() (memproxy.LeaseGetResponse, error) {
+					now = now.Add(d)
+					return memproxy.LeaseGetResponse{Status: memproxy.LeaseGetStatusFound}, nil
+				})
+			}
+		}
+		stubLeaseGet(p.pipe1, 10*time.Millisecond)
+		stubLeaseGet(p.pipe2, 3*time.Millisecond)
+
+		fn1 := p.pipe.LeaseGet("KEY01", memproxy.LeaseGetOptions{})
+		fn2 := p.pipe.LeaseGet("KEY02", memproxy.LeaseGetOptions{})
+		fn3 := p.pipe.LeaseGet("KEY03", memproxy.LeaseGetOptions{})
+
+		_, err := fn1.Result()
+		assert.Equal(t, nil, err)
+		_, err = fn2.Result()
+		assert.Equal(t, nil, err)
+		_, err = fn3.Result()
+		assert.Equal(t, nil, err)
+
+		assert.Equal(t, []ServerID{serverID1, serverID2}, observer.servers)
+		assert.Equal(t, []time.Duration{10 * time.Millisecond, 3 * time.Millisecond}, observer.latencies)
+	})
+
+	t.Run("errors-not-observed", func(t *testing.T) {
+		observer := &latencyObserverTest{}
+		p := newPipelineTest(t, WithMemcacheLatencyObserver(observer))
+
+		p.stubSelect(serverID1, serverID2)
+		p.stubHasNextAvail(true)
+		p.stubLeaseGet1(memproxy.LeaseGetResponse{}, errors.New("server error"))
+		p.stubLeaseGet2(memproxy.LeaseGetResponse{Status: memproxy.LeaseGetStatusFound}, nil)
+
+		_, err := p.pipe.LeaseGet("KEY01", memproxy.LeaseGetOptions{}).Result()
+		assert.Equal(t, nil, err)
+		assert.Equal(t, []ServerID{serverID2}, observer.servers)
+	})
+}
+
+type resultObserverTest struct {
+	servers []ServerID
+	errors  []error
+}
+
+func (r *resultObserverTest) ObserveResult(server ServerID, err error) {
+	r.servers = append(r.servers, server)
+	r.errors = append(r.errors, err)
+}
+
+type resultObserverFunc func(server ServerID, err error)
+
+func (f resultObserverFunc) ObserveResult(server ServerID, err error) {
+	f(server, err)
+}
+
+func TestPipeline__LeaseGet_Result_Observer(t *testing.T) {
+	observer := &resultObserverTest{}
+	p := newPipelineTest(t, WithMemcacheResultObserver(observer))
+
+	p.stubSelect(serverID1, serverID2)
+	p.stubHasNextAvail(true)
+	p.stubLeaseGet1(memproxy.LeaseGetResponse{}, errors.New("server error"))
+	p.stubLeaseGet2(memproxy.LeaseGetResponse{Status: memproxy.LeaseGetStatusFound}, nil)
+
+	_, err := p.pipe.LeaseGet("KEY01", memproxy.LeaseGetOptions{}).Result()
+	assert.Equal(t, nil, err)
+
+	assert.Equal(t, []ServerID{serverID1, serverID2}, observer.servers)
+	assert.Equal(t, []error{errors.New("server error"), nil}, observer.errors)
+}
+
 func TestPipeline__Delete(t *testing.T) {
 	t.Run("normal-single-server", func(t *testing.T) {
 		p := newPipelineTest(t)
@@ -804,7 +1223,10 @@ func TestPipeline__Delete(t *testing.T) {
 		fn := p.pipe.Delete("KEY01", memproxy.DeleteOptions{})
 		resp, err := fn()
 
-		assert.Equal(t, errors.New("some error"), err)
+		assert.Equal(t, &ServerError{
+			Server: serverID1,
+			Err:    errors.New("some error"),
+		}, err)
 		assert.Equal(t, memproxy.DeleteResponse{}, resp)
 
 		selectCalls := p.selector.SelectForDeleteCalls()
@@ -875,3 +1297,34 @@ func TestMemcache_Route_Possible_Server_IDs__Not_In_Server_List(t *testing.T) {
 	assert.Equal(t, errors.New("proxy: server id '41' not in server list"), err)
 	assert.Nil(t, mc)
 }
+
+func TestMemcache_ServerMemcache(t *testing.T) {
+	server1 := SimpleServerConfig{
+		ID:   serverID1,
+		Host: "localhost",
+		Port: 11211,
+	}
+
+	route := &RouteMock{}
+	route.AllServerIDsFunc = func() []ServerID {
+		return []ServerID{serverID1}
+	}
+
+	client1 := &mocks.MemcacheMock{}
+
+	mc, err := New[SimpleServerConfig](Config[SimpleServerConfig]{
+		Servers: []SimpleServerConfig{server1},
+		Route:   route,
+	}, func(conf SimpleServerConfig) memproxy.Memcache {
+		return client1
+	})
+	assert.Equal(t, nil, err)
+
+	client, err := mc.ServerMemcache(serverID1)
+	assert.Equal(t, nil, err)
+	assert.Same(t, client1, client)
+
+	client, err = mc.ServerMemcache(41)
+	assert.Equal(t, errors.New("proxy: server id '41' not in server list"), err)
+	assert.Nil(t, client)
+}
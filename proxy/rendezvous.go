@@ -0,0 +1,258 @@
+package proxy
+
+import (
+	"math"
+
+	"github.com/spaolacci/murmur3"
+)
+
+type rendezvousRouteConfig struct {
+	// weights from config, nil if not configured
+	weights map[ServerID]float64
+
+	// compute weight from memory usage, nil if not configured
+	memScore func(mem float64) float64
+}
+
+type rendezvousRoute struct {
+	configServers []ServerID
+
+	// weights of configServers, computed once when the route is created
+	weights []float64
+
+	stats ServerStats
+}
+
+// rendezvousRouteSelector does NOT cache the chosen servers of keys,
+// because the scores of a key are cheap to compute and always the same for the same weights
+type rendezvousRouteSelector struct {
+	//revive:disable-next-line:nested-structs
+	failedServers map[ServerID]struct{}
+
+	route *rendezvousRoute
+}
+
+var _ Route = &rendezvousRoute{}
+
+// RendezvousRouteOption ...
+type RendezvousRouteOption func(conf *rendezvousRouteConfig)
+
+// WithRendezvousWeights configures static weights of servers, servers not in the map have weight 1.
+// Weights MUST be positive
+func WithRendezvousWeights(weights map[ServerID]float64) RendezvousRouteOption {
+	return func(conf *rendezvousRouteConfig) {
+		conf.weights = weights
+	}
+}
+
+// WithRendezvousMemoryWeights computes weights of servers from ServerStats.GetMemUsage,
+// weights smaller than 1 are rounded up to 1.
+// The weights are computed once when the route is created, so keys are NOT moved when the memory usages changed,
+// a new route must be created for using the new memory usages (e.g. by UpdateConfig)
+func WithRendezvousMemoryWeights(memScoreFunc func(mem float64) float64) RendezvousRouteOption {
+	return func(conf *rendezvousRouteConfig) {
+		conf.memScore = memScoreFunc
+	}
+}
+
+// NewRendezvousRoute creates a route that partitions keys between servers using weighted rendezvous hashing,
+// each key is placed on the server with the highest score. Adding or removing a server only moves
+// the keys of that server. All servers have the same weight if no weight option is provided
+func NewRendezvousRoute(
+	servers []ServerID,
+	stats ServerStats,
+	options ...RendezvousRouteOption,
+) Route {
+	if len(servers) == 0 {
+		panic("rendezvous route: servers can not be empty")
+	}
+
+	conf := &rendezvousRouteConfig{}
+
+	for _, opt := range options {
+		opt(conf)
+	}
+
+	for _, w := range conf.weights {
+		if w <= 0 {
+			panic("rendezvous route: weights must be positive")
+		}
+	}
+
+	weights := make([]float64, 0, len(servers))
+	for _, server := range servers {
+		weights = append(weights, conf.getWeight(server, stats))
+	}
+
+	return &rendezvousRoute{
+		configServers: servers,
+
+		weights: weights,
+		stats:   stats,
+	}
+}
+
+// NewSelector ...
+func (r *rendezvousRoute) NewSelector() Selector {
+	s := &rendezvousRouteSelector{
+		route: r,
+	}
+	for _, server := range r.configServers {
+		if r.stats.IsServerFailed(server) {
+			s.getFailedServers()[server] = struct{}{}
+		}
+	}
+	return s
+}
+
+// AllServerIDs returns the list of all possible servers
+func (r *rendezvousRoute) AllServerIDs() []ServerID {
+	return r.configServers
+}
+
+func (c *rendezvousRouteConfig) getWeight(server ServerID, stats ServerStats) float64 {
+	if c.memScore != nil {
+		w := c.memScore(stats.GetMemUsage(server))
+		if w < 1.0 {
+			return 1.0
+		}
+		return w
+	}
+
+	w, ok := c.weights[server]
+	if !ok {
+		return 1.0
+	}
+	return w
+}
+
+// rendezvousScore computes the weighted score: -weight / ln(h), with h is the hash in range (0, 1)
+func rendezvousScore(key []byte, server ServerID, weight float64) float64 {
+	hash := murmur3.Sum64WithSeed(key, uint32(server))
+	h := (float64(hash>>11) + 0.5) / (1 << 53)
+	return -weight / math.Log(h)
+}
+
+func (s *rendezvousRouteSelector) getFailedServers() map[ServerID]struct{} {
+	if s.failedServers == nil {
+		s.failedServers = map[ServerID]struct{}{}
+	}
+	return s.failedServers
+}
+
+// SetFailedServer ...
+func (s *rendezvousRouteSelector) SetFailedServer(server ServerID) {
+	if s.addFailedServer(server) {
+		s.route.stats.NotifyServerFailed(server)
+	}
+}
+
+func (s *rendezvousRouteSelector) markFailedServer(server ServerID) {
+	s.addFailedServer(server)
+}
+
+// addFailedServer returns true if the server was not failed before
+func (s *rendezvousRouteSelector) addFailedServer(server ServerID) bool {
+	failed := s.getFailedServers()
+
+	_, existed := failed[server]
+	failed[server] = struct{}{}
+	return !existed
+}
+
+// HasNextAvailableServer check if next available server ready to be fallback to
+func (s *rendezvousRouteSelector) HasNextAvailableServer() bool {
+	return len(s.failedServers) < len(s.route.configServers)
+}
+
+// topServers returns the available servers with the highest scores, sorted by scores descending,
+// the returned list has at most n elements, returns the server with the highest score if all servers failed
+func (s *rendezvousRouteSelector) topServers(key string, n int) []ServerID {
+	keyBytes := []byte(key)
+	weights := s.route.weights
+
+	result := make([]ServerID, 0, n)
+	scores := make([]float64, 0, n)
+
+	bestServer := s.route.configServers[0]
+	bestScore := -1.0
+
+	for i, server := range s.route.configServers {
+		score := rendezvousScore(keyBytes, server, weights[i])
+		if score > bestScore {
+			bestScore = score
+			bestServer = server
+		}
+
+		if _, failed := s.failedServers[server]; failed {
+			continue
+		}
+
+		// insertion into the top n list
+		pos := len(scores)
+		for pos > 0 && scores[pos-1] < score {
+			pos--
+		}
+		if pos >= n {
+			continue
+		}
+
+		if len(scores) < n {
+			scores = append(scores, 0)
+			result = append(result, 0)
+		}
+		copy(scores[pos+1:], scores[pos:])
+		copy(result[pos+1:], result[pos:])
+		scores[pos] = score
+		result[pos] = server
+	}
+
+	if len(result) == 0 {
+		return []ServerID{bestServer}
+	}
+	return result
+}
+
+// SelectServer choose the available server with the highest score for the key,
+// will keep in this server id unless failed server added
+func (s *rendezvousRouteSelector) SelectServer(key string) ServerID {
+	return s.topServers(key, 1)[0]
+}
+
+// SelectForDelete choose servers for deleting, includes the current server of the key
+// and the server with the next-highest score, which is the failover target
+func (s *rendezvousRouteSelector) SelectForDelete(key string) []ServerID {
+	return s.topServers(key, 2)
+}
+
+// SkippedForDelete returns the failed servers with higher scores than the current server of the key
+func (s *rendezvousRouteSelector) SkippedForDelete(key string) []ServerID {
+	if len(s.failedServers) == 0 || len(s.failedServers) >= len(s.route.configServers) {
+		return nil
+	}
+
+	keyBytes := []byte(key)
+	weights := s.route.weights
+	current := s.topServers(key, 1)[0]
+
+	scores := make([]float64, len(s.route.configServers))
+	currentScore := 0.0
+	for i, server := range s.route.configServers {
+		scores[i] = rendezvousScore(keyBytes, server, weights[i])
+		if server == current {
+			currentScore = scores[i]
+		}
+	}
+
+	var result []ServerID
+	for i, server := range s.route.configServers {
+		if _, failed := s.failedServers[server]; failed && scores[i] > currentScore {
+			result = append(result, server)
+		}
+	}
+	return result
+}
+
+// Reset the selection, nothing to reset because the chosen servers are not cached
+func (*rendezvousRouteSelector) Reset() {
+}
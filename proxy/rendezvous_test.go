@@ -0,0 +1,224 @@
+package proxy
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type rendezvousRouteTest struct {
+	stats *ServerStatsMock
+
+	route    Route
+	selector Selector
+}
+
+func newRendezvousRouteTest(servers []ServerID, options ...RendezvousRouteOption) *rendezvousRouteTest {
+	return newRendezvousRouteTestWithMem(servers, nil, options...)
+}
+
+func newRendezvousRouteTestWithMem(
+	servers []ServerID, memUsage map[ServerID]float64,
+	options ...RendezvousRouteOption,
+) *rendezvousRouteTest {
+	r := &rendezvousRouteTest{}
+
+	r.stats = &ServerStatsMock{
+		NotifyServerFailedFunc: func(server ServerID) {
+		},
+		IsServerFailedFunc: func(server ServerID) bool {
+			return false
+		},
+		GetMemUsageFunc: func(server ServerID) float64 {
+			return memUsage[server]
+		},
+	}
+
+	r.route = NewRendezvousRoute(servers, r.stats, options...)
+	r.selector = r.route.NewSelector()
+
+	return r
+}
+
+func TestRendezvousRoute(t *testing.T) {
+	allServers := []ServerID{serverID1, serverID2, serverID3}
+
+	t.Run("same key same server", func(t *testing.T) {
+		r := newRendezvousRouteTest(allServers)
+
+		server := r.selector.SelectServer("key01")
+		assert.Equal(t, server, r.selector.SelectServer("key01"))
+		assert.Equal(t, server, r.route.NewSelector().SelectServer("key01"))
+
+		r.selector.Reset()
+		assert.Equal(t, server, r.selector.SelectServer("key01"))
+
+		assert.Equal(t, allServers, r.route.AllServerIDs())
+	})
+
+	t.Run("select per key", func(t *testing.T) {
+		r := newRendezvousRouteTest(allServers)
+
+		counters := countShardedKeys(r.selector, 30000)
+		assert.Equal(t, 3, len(counters))
+		for _, server := range allServers {
+			assert.InDelta(t, 10000, counters[server], 30000*0.05)
+		}
+	})
+
+	t.Run("adding server only moves keys to the new server", func(t *testing.T) {
+		before := newRendezvousRouteTest([]ServerID{serverID1, serverID2})
+		after := newRendezvousRouteTest(allServers)
+
+		const numKeys = 10000
+		moved := 0
+		for i := 0; i < numKeys; i++ {
+			key := fmt.Sprintf("key:%d", i)
+			oldServer := before.selector.SelectServer(key)
+			newServer := after.selector.SelectServer(key)
+			if oldServer == newServer {
+				continue
+			}
+			moved++
+			assert.Equal(t, serverID3, newServer)
+		}
+		assert.InDelta(t, numKeys/3, moved, numKeys*0.05)
+	})
+
+	t.Run("invalid config", func(t *testing.T) {
+		assert.Panics(t, func() {
+			NewRendezvousRoute(nil, &ServerStatsMock{})
+		})
+		assert.Panics(t, func() {
+			NewRendezvousRoute(allServers, &ServerStatsMock{}, WithRendezvousWeights(map[ServerID]float64{
+				serverID1: 0,
+			}))
+		})
+	})
+}
+
+func TestRendezvousRoute_Weights(t *testing.T) {
+	allServers := []ServerID{serverID1, serverID2, serverID3}
+
+	t.Run("weights from config", func(t *testing.T) {
+		r := newRendezvousRouteTest(allServers, WithRendezvousWeights(map[ServerID]float64{
+			serverID1: 2,
+			serverID2: 1,
+		}))
+
+		const numKeys = 40000
+		counters := countShardedKeys(r.selector, numKeys)
+		assert.InDelta(t, numKeys/2, counters[serverID1], numKeys*0.05)
+		assert.InDelta(t, numKeys/4, counters[serverID2], numKeys*0.05)
+		assert.InDelta(t, numKeys/4, counters[serverID3], numKeys*0.05)
+	})
+
+	t.Run("weights from memory usage", func(t *testing.T) {
+		r := newRendezvousRouteTestWithMem(allServers, map[ServerID]float64{
+			serverID1: 3000,
+			serverID2: 1000,
+		}, WithRendezvousMemoryWeights(func(mem float64) float64 {
+			return mem
+		}))
+
+		const numKeys = 40000
+		counters := countShardedKeys(r.selector, numKeys)
+		assert.InDelta(t, numKeys*3/4, counters[serverID1], numKeys*0.05)
+		assert.InDelta(t, numKeys/4, counters[serverID2], numKeys*0.05)
+		assert.Less(t, counters[serverID3], numKeys/100)
+
+		// weights are computed once when the route is created
+		assert.Equal(t, 3, len(r.stats.GetMemUsageCalls()))
+	})
+
+	t.Run("memory weights not changed after created", func(t *testing.T) {
+		r := newRendezvousRouteTestWithMem(allServers, map[ServerID]float64{
+			serverID1: 3000,
+			serverID2: 1000,
+			serverID3: 1000,
+		}, WithRendezvousMemoryWeights(func(mem float64) float64 {
+			return mem
+		}))
+
+		const numKeys = 1000
+		before := countShardedKeys(r.selector, numKeys)
+
+		r.stats.GetMemUsageFunc = func(server ServerID) float64 {
+			return 1000
+		}
+		r.selector.Reset()
+
+		assert.Equal(t, before, countShardedKeys(r.selector, numKeys))
+		assert.Equal(t, before, countShardedKeys(r.route.NewSelector(), numKeys))
+	})
+}
+
+func TestRendezvousRoute_Failover(t *testing.T) {
+	allServers := []ServerID{serverID1, serverID2, serverID3}
+
+	t.Run("failover to next highest score", func(t *testing.T) {
+		r := newRendezvousRouteTest(allServers)
+
+		const numKeys = 1000
+		owners := make([]ServerID, numKeys)
+		deleted := make([][]ServerID, numKeys)
+		for i := range owners {
+			key := fmt.Sprintf("key:%d", i)
+			owners[i] = r.selector.SelectServer(key)
+			deleted[i] = r.selector.SelectForDelete(key)
+			assert.Equal(t, 2, len(deleted[i]))
+			assert.Equal(t, owners[i], deleted[i][0])
+		}
+
+		r.selector.SetFailedServer(serverID2)
+		r.selector.SetFailedServer(serverID2)
+
+		assert.Equal(t, 1, len(r.stats.NotifyServerFailedCalls()))
+		assert.Equal(t, true, r.selector.HasNextAvailableServer())
+
+		for i, owner := range owners {
+			server := r.selector.SelectServer(fmt.Sprintf("key:%d", i))
+			if owner != serverID2 {
+				assert.Equal(t, owner, server)
+			} else {
+				assert.Equal(t, deleted[i][1], server)
+			}
+		}
+
+		skipped := r.selector.(SkippedDeleteSelector)
+		for i, owner := range owners {
+			key := fmt.Sprintf("key:%d", i)
+			if owner == serverID2 {
+				assert.Equal(t, []ServerID{serverID2}, skipped.SkippedForDelete(key))
+			} else {
+				assert.Equal(t, []ServerID(nil), skipped.SkippedForDelete(key))
+			}
+		}
+
+		r.selector.SetFailedServer(serverID1)
+		r.selector.SetFailedServer(serverID3)
+		assert.Equal(t, false, r.selector.HasNextAvailableServer())
+		assert.Equal(t, []ServerID(nil), skipped.SkippedForDelete("key:0"))
+
+		// all failed, back to the owners
+		for i, owner := range owners {
+			key := fmt.Sprintf("key:%d", i)
+			assert.Equal(t, owner, r.selector.SelectServer(key))
+			assert.Equal(t, []ServerID{owner}, r.selector.SelectForDelete(key))
+		}
+	})
+
+	t.Run("failed servers from stats", func(t *testing.T) {
+		r := newRendezvousRouteTest(allServers)
+		r.stats.IsServerFailedFunc = func(server ServerID) bool {
+			return server == serverID1
+		}
+		r.selector = r.route.NewSelector()
+
+		counters := countShardedKeys(r.selector, 1000)
+		assert.Equal(t, 0, counters[serverID1])
+		assert.Equal(t, 1000, counters[serverID2]+counters[serverID3])
+		assert.Equal(t, 0, len(r.stats.NotifyServerFailedCalls()))
+	})
+}
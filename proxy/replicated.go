@@ -2,6 +2,7 @@ package proxy
 
 import (
 	"math/rand"
+	"time"
 )
 
 type replicatedRoute struct {
@@ -23,6 +24,12 @@ type replicatedRouteConfig struct {
 
 	// default 1%
 	minPercent float64
+
+	// zero means warm up disabled
+	warmUpRatio float64
+
+	// nil means not using latency
+	latency LatencySource
 }
 
 type replicatedRouteSelector struct {
@@ -33,6 +40,7 @@ type replicatedRouteSelector struct {
 
 	route       *replicatedRoute
 	weightAccum []float64
+	latencies   []time.Duration
 
 	alreadyChosen bool
 	chosenServer  ServerID
@@ -40,6 +48,10 @@ type replicatedRouteSelector struct {
 
 var _ Route = &replicatedRoute{}
 
+var _ WarmUpSelector = &replicatedRouteSelector{}
+
+var _ SkippedDeleteSelector = &replicatedRouteSelector{}
+
 // ReplicatedRouteOption ...
 type ReplicatedRouteOption func(conf *replicatedRouteConfig)
 
@@ -64,6 +76,27 @@ func WithMemoryScoringFunc(memScoreFunc func(mem float64) float64) ReplicatedRou
 	}
 }
 
+// WithWarmUpRatio enables warming up cold servers: a server is cold when its memory usage
+// is lower than ratio * the max memory usage of the other available servers.
+// Lease gets missed on a cold server are read from the warmest replica,
+// and the found values are set back to the cold server using the granted leases.
+// The ratio MUST be in range (0, 1]
+func WithWarmUpRatio(ratio float64) ReplicatedRouteOption {
+	return func(conf *replicatedRouteConfig) {
+		conf.warmUpRatio = ratio
+	}
+}
+
+// WithLatencyScoring combines the memory score with the latency of servers (e.g. from LatencyServerStats).
+// The weight of a server is multiplied by (the lowest latency of the available servers / latency of the server),
+// so a server two times slower than the fastest one receives a half of its memory-weighted share,
+// but not lower than the min percentage. Servers without observed latency are not penalized
+func WithLatencyScoring(latency LatencySource) ReplicatedRouteOption {
+	return func(conf *replicatedRouteConfig) {
+		conf.latency = latency
+	}
+}
+
 // NewReplicatedRoute ...
 func NewReplicatedRoute(
 	servers []ServerID,
@@ -88,6 +121,10 @@ func NewReplicatedRoute(
 		opt(conf)
 	}
 
+	if conf.warmUpRatio < 0 || conf.warmUpRatio > 1 {
+		panic("replicated route: warm up ratio must be in range (0, 1]")
+	}
+
 	return &replicatedRoute{
 		configServers: servers,
 
@@ -119,16 +156,28 @@ func (s *replicatedRouteSelector) getFailedServers() map[ServerID]struct{} {
 
 // SetFailedServer ...
 func (s *replicatedRouteSelector) SetFailedServer(server ServerID) {
+	if s.addFailedServer(server) {
+		s.route.stats.NotifyServerFailed(server)
+	}
+}
+
+func (s *replicatedRouteSelector) markFailedServer(server ServerID) {
+	s.addFailedServer(server)
+}
+
+// addFailedServer returns true if the server was not failed before
+func (s *replicatedRouteSelector) addFailedServer(server ServerID) bool {
 	failed := s.getFailedServers()
 
 	_, existed := failed[server]
+	if existed {
+		return false
+	}
 	failed[server] = struct{}{}
 
-	if !existed {
-		s.Reset()
-		s.remainingServers = s.computeRemainingServers()
-		s.route.stats.NotifyServerFailed(server)
-	}
+	s.Reset()
+	s.remainingServers = s.computeRemainingServers()
+	return true
 }
 
 // HasNextAvailableServer check if next available server ready to be fallback to
@@ -172,6 +221,10 @@ func (s *replicatedRouteSelector) SelectServer(string) ServerID {
 		s.weightAccum = append(s.weightAccum, w)
 	}
 
+	if s.route.conf.latency != nil {
+		s.applyLatencyWeights()
+	}
+
 	randVal := s.route.conf.randFunc(RandomMaxValues)
 
 	index, weights := computeChosenServer(s.weightAccum, s.route.conf.minPercent, randVal)
@@ -182,11 +235,78 @@ func (s *replicatedRouteSelector) SelectServer(string) ServerID {
 	return s.chosenServer
 }
 
+func (s *replicatedRouteSelector) applyLatencyWeights() {
+	s.latencies = s.latencies[:0]
+
+	minLatency := time.Duration(0)
+	for _, server := range s.remainingServers {
+		latency := s.route.conf.latency.GetLatency(server)
+		s.latencies = append(s.latencies, latency)
+
+		if latency > 0 && (minLatency == 0 || latency < minLatency) {
+			minLatency = latency
+		}
+	}
+
+	for i, latency := range s.latencies {
+		if latency > minLatency {
+			s.weightAccum[i] *= float64(minLatency) / float64(latency)
+		}
+	}
+}
+
 // SelectForDelete choose servers for deleting
 func (s *replicatedRouteSelector) SelectForDelete(string) []ServerID {
 	return s.remainingServers
 }
 
+// SkippedForDelete returns the failed servers, which are not included in SelectForDelete
+func (s *replicatedRouteSelector) SkippedForDelete(string) []ServerID {
+	if len(s.remainingServers) == len(s.route.configServers) {
+		return nil
+	}
+
+	var result []ServerID
+	for _, server := range s.route.configServers {
+		if _, failed := s.failedServers[server]; failed {
+			result = append(result, server)
+		}
+	}
+	return result
+}
+
+// SelectWarmServer returns the other available server with the highest memory usage when the server is cold
+func (s *replicatedRouteSelector) SelectWarmServer(_ string, server ServerID) (ServerID, bool) {
+	ratio := s.route.conf.warmUpRatio
+	if ratio == 0 {
+		return 0, false
+	}
+
+	serverMem := -1.0
+	warmServer := server
+	warmMem := -1.0
+
+	for _, id := range s.remainingServers {
+		mem := s.route.stats.GetMemUsage(id)
+		if id == server {
+			serverMem = mem
+			continue
+		}
+		if mem > warmMem {
+			warmServer = id
+			warmMem = mem
+		}
+	}
+
+	if serverMem < 0 || warmServer == server {
+		return 0, false
+	}
+	if serverMem >= ratio*warmMem {
+		return 0, false
+	}
+	return warmServer, true
+}
+
 // Reset the selection
 func (s *replicatedRouteSelector) Reset() {
 	s.alreadyChosen = false
@@ -5,6 +5,7 @@ import (
 	"github.com/stretchr/testify/assert"
 	"math"
 	"testing"
+	"time"
 )
 
 type replicatedRouteTest struct {
@@ -148,6 +149,7 @@ func TestReplicatedRoute(t *testing.T) {
 		assert.Equal(t, true, r.selector.HasNextAvailableServer())
 
 		assert.Equal(t, []ServerID{serverID2}, r.selector.SelectForDelete(""))
+		assert.Equal(t, []ServerID{serverID1}, r.selector.(SkippedDeleteSelector).SkippedForDelete(""))
 
 		assert.Equal(t, 1, len(r.stats.NotifyServerFailedCalls()))
 		assert.Equal(t, serverID1, r.stats.NotifyServerFailedCalls()[0].Server)
@@ -370,3 +372,95 @@ func TestComputeWeightAccumWithMinPercent(t *testing.T) {
 		})
 	}
 }
+
+type latencySourceTest map[ServerID]time.Duration
+
+func (l latencySourceTest) GetLatency(server ServerID) time.Duration {
+	return l[server]
+}
+
+func TestReplicatedRoute_With_Latency_Scoring(t *testing.T) {
+	t.Run("slow server weight reduced", func(t *testing.T) {
+		r := newReplicatedRouteTest(WithLatencyScoring(latencySourceTest{
+			serverID1: 10 * time.Millisecond,
+			serverID2: 20 * time.Millisecond,
+		}))
+
+		// weights: 50, 25
+		r.stubGetMem(50, 50)
+		r.stubRand(600000)
+		assert.Equal(t, serverID1, r.selector.SelectServer(""))
+		assert.Equal(t, []float64{50, 75}, r.selector.(*replicatedRouteSelector).weightAccum)
+
+		r.selector.Reset()
+
+		r.stubGetMem(50, 50, 50, 50)
+		r.stubRand(700000)
+		assert.Equal(t, serverID2, r.selector.SelectServer(""))
+	})
+
+	t.Run("no latency observed", func(t *testing.T) {
+		r := newReplicatedRouteTest(WithLatencyScoring(latencySourceTest{
+			serverID2: 20 * time.Millisecond,
+		}))
+
+		r.stubGetMem(50, 50)
+		r.stubRand(600000)
+		assert.Equal(t, serverID2, r.selector.SelectServer(""))
+		assert.Equal(t, []float64{50, 100}, r.selector.(*replicatedRouteSelector).weightAccum)
+	})
+}
+
+func TestReplicatedRoute_SelectWarmServer(t *testing.T) {
+	t.Run("disabled", func(t *testing.T) {
+		r := newReplicatedRouteTest()
+
+		warm, ok := r.selector.(WarmUpSelector).SelectWarmServer("KEY01", serverID1)
+		assert.Equal(t, false, ok)
+		assert.Equal(t, ServerID(0), warm)
+		assert.Equal(t, 0, len(r.stats.GetMemUsageCalls()))
+	})
+
+	t.Run("cold server", func(t *testing.T) {
+		r := newReplicatedRouteTest(WithWarmUpRatio(0.5))
+		r.stats.GetMemUsageFunc = func(server ServerID) float64 {
+			return map[ServerID]float64{serverID1: 10, serverID2: 100}[server]
+		}
+
+		warm, ok := r.selector.(WarmUpSelector).SelectWarmServer("KEY01", serverID1)
+		assert.Equal(t, true, ok)
+		assert.Equal(t, serverID2, warm)
+
+		// the warm server is not cold
+		_, ok = r.selector.(WarmUpSelector).SelectWarmServer("KEY01", serverID2)
+		assert.Equal(t, false, ok)
+	})
+
+	t.Run("server filled above ratio", func(t *testing.T) {
+		r := newReplicatedRouteTest(WithWarmUpRatio(0.5))
+		r.stats.GetMemUsageFunc = func(server ServerID) float64 {
+			return map[ServerID]float64{serverID1: 50, serverID2: 100}[server]
+		}
+
+		_, ok := r.selector.(WarmUpSelector).SelectWarmServer("KEY01", serverID1)
+		assert.Equal(t, false, ok)
+	})
+
+	t.Run("warm server failed", func(t *testing.T) {
+		r := newReplicatedRouteTest(WithWarmUpRatio(0.5))
+		r.stats.GetMemUsageFunc = func(server ServerID) float64 {
+			return map[ServerID]float64{serverID1: 10, serverID2: 100}[server]
+		}
+
+		r.selector.SetFailedServer(serverID2)
+
+		_, ok := r.selector.(WarmUpSelector).SelectWarmServer("KEY01", serverID1)
+		assert.Equal(t, false, ok)
+	})
+
+	t.Run("invalid ratio", func(t *testing.T) {
+		assert.PanicsWithValue(t, "replicated route: warm up ratio must be in range (0, 1]", func() {
+			newReplicatedRouteTest(WithWarmUpRatio(1.5))
+		})
+	})
+}
@@ -0,0 +1,319 @@
+package proxy
+
+import (
+	"sort"
+	"strconv"
+
+	"github.com/spaolacci/murmur3"
+)
+
+// DefaultVirtualNodes is the default number of points on the hash ring for each server
+const DefaultVirtualNodes = 160
+
+// FailoverPolicy decides which server to be used when the owner of a key failed
+type FailoverPolicy int
+
+const (
+	// FailoverNextNode chooses the next available server on the hash ring
+	FailoverNextNode FailoverPolicy = iota
+
+	// FailoverNone does not fall back, requests to the failed server return errors
+	FailoverNone
+)
+
+type shardedRouteConfig struct {
+	virtualNodes   int
+	failoverPolicy FailoverPolicy
+}
+
+type ringPoint struct {
+	hash  uint64
+	index int
+}
+
+// hashRing is a ketama-style consistent hash ring of nodes, identified by their indices
+type hashRing struct {
+	numNodes int
+
+	// sorted by hash
+	points []ringPoint
+}
+
+type shardedRoute struct {
+	configServers []ServerID
+
+	ring *hashRing
+
+	conf  *shardedRouteConfig
+	stats ServerStats
+}
+
+type shardedRouteSelector struct {
+	//revive:disable-next-line:nested-structs
+	failedServers map[ServerID]struct{}
+
+	route *shardedRoute
+}
+
+var _ Route = &shardedRoute{}
+
+// ShardedRouteOption ...
+type ShardedRouteOption func(conf *shardedRouteConfig)
+
+// WithVirtualNodes configures the number of points on the hash ring for each server,
+// default is DefaultVirtualNodes
+func WithVirtualNodes(n int) ShardedRouteOption {
+	return func(conf *shardedRouteConfig) {
+		conf.virtualNodes = n
+	}
+}
+
+// WithFailoverPolicy configures the failover policy, default is FailoverNextNode
+func WithFailoverPolicy(policy FailoverPolicy) ShardedRouteOption {
+	return func(conf *shardedRouteConfig) {
+		conf.failoverPolicy = policy
+	}
+}
+
+// NewShardedRoute creates a route that partitions keys between servers
+// using a ketama-style consistent hash ring with virtual nodes.
+// Adding or removing a server only moves the keys of that server
+func NewShardedRoute(
+	servers []ServerID,
+	stats ServerStats,
+	options ...ShardedRouteOption,
+) Route {
+	if len(servers) == 0 {
+		panic("sharded route: servers can not be empty")
+	}
+
+	conf := &shardedRouteConfig{
+		virtualNodes:   DefaultVirtualNodes,
+		failoverPolicy: FailoverNextNode,
+	}
+
+	for _, opt := range options {
+		opt(conf)
+	}
+
+	if conf.virtualNodes <= 0 {
+		panic("sharded route: virtual nodes must be positive")
+	}
+
+	return &shardedRoute{
+		configServers: servers,
+		ring:          newHashRing(serverLabels(servers), conf.virtualNodes),
+
+		conf:  conf,
+		stats: stats,
+	}
+}
+
+func newHashRing(labels []string, virtualNodes int) *hashRing {
+	points := make([]ringPoint, 0, len(labels)*virtualNodes)
+	var buf []byte
+	for index, label := range labels {
+		for i := 0; i < virtualNodes; i++ {
+			buf = append(buf[:0], label...)
+			buf = append(buf, '-')
+			buf = strconv.AppendInt(buf, int64(i), 10)
+
+			points = append(points, ringPoint{
+				hash:  murmur3.Sum64(buf),
+				index: index,
+			})
+		}
+	}
+
+	sort.Slice(points, func(i, j int) bool {
+		if points[i].hash != points[j].hash {
+			return points[i].hash < points[j].hash
+		}
+		return points[i].index < points[j].index
+	})
+
+	return &hashRing{
+		numNodes: len(labels),
+		points:   points,
+	}
+}
+
+// walk calls fn with distinct node indices in the ring order starting from the owner of the key,
+// stops when fn returns false
+func (h *hashRing) walk(key string, fn func(index int) bool) {
+	hash := murmur3.Sum64([]byte(key))
+	start := sort.Search(len(h.points), func(i int) bool {
+		return h.points[i].hash >= hash
+	})
+
+	visited := make([]int, 0, h.numNodes)
+
+OuterLoop:
+	for i := 0; i < len(h.points) && len(visited) < h.numNodes; i++ {
+		index := h.points[(start+i)%len(h.points)].index
+
+		for _, prev := range visited {
+			if prev == index {
+				continue OuterLoop
+			}
+		}
+		visited = append(visited, index)
+
+		if !fn(index) {
+			return
+		}
+	}
+}
+
+func serverLabels(servers []ServerID) []string {
+	labels := make([]string, 0, len(servers))
+	for _, server := range servers {
+		labels = append(labels, strconv.FormatInt(int64(server), 10))
+	}
+	return labels
+}
+
+// NewSelector ...
+func (r *shardedRoute) NewSelector() Selector {
+	s := &shardedRouteSelector{
+		route: r,
+	}
+	for _, server := range r.configServers {
+		if r.stats.IsServerFailed(server) {
+			s.getFailedServers()[server] = struct{}{}
+		}
+	}
+	return s
+}
+
+// AllServerIDs returns the list of all possible servers
+func (r *shardedRoute) AllServerIDs() []ServerID {
+	return r.configServers
+}
+
+// walkRing calls fn with distinct servers in the ring order starting from the owner of the key,
+// stops when fn returns false
+func (r *shardedRoute) walkRing(key string, fn func(server ServerID) bool) {
+	r.ring.walk(key, func(index int) bool {
+		return fn(r.configServers[index])
+	})
+}
+
+func (s *shardedRouteSelector) getFailedServers() map[ServerID]struct{} {
+	if s.failedServers == nil {
+		s.failedServers = map[ServerID]struct{}{}
+	}
+	return s.failedServers
+}
+
+func (s *shardedRouteSelector) isFailed(server ServerID) bool {
+	_, existed := s.failedServers[server]
+	return existed
+}
+
+// SetFailedServer ...
+func (s *shardedRouteSelector) SetFailedServer(server ServerID) {
+	if s.addFailedServer(server) {
+		s.route.stats.NotifyServerFailed(server)
+	}
+}
+
+func (s *shardedRouteSelector) markFailedServer(server ServerID) {
+	s.addFailedServer(server)
+}
+
+// addFailedServer returns true if the server was not failed before
+func (s *shardedRouteSelector) addFailedServer(server ServerID) bool {
+	failed := s.getFailedServers()
+
+	_, existed := failed[server]
+	failed[server] = struct{}{}
+	return !existed
+}
+
+// HasNextAvailableServer check if next available server ready to be fallback to
+func (s *shardedRouteSelector) HasNextAvailableServer() bool {
+	if s.route.conf.failoverPolicy == FailoverNone {
+		return false
+	}
+	return len(s.failedServers) < len(s.route.configServers)
+}
+
+// SelectServer choose the owner of the key on the hash ring,
+// or the next available server when the owner failed
+func (s *shardedRouteSelector) SelectServer(key string) ServerID {
+	var owner ServerID
+	chosen := false
+
+	s.route.walkRing(key, func(server ServerID) bool {
+		if !chosen {
+			owner = server
+			chosen = true
+		}
+
+		if s.route.conf.failoverPolicy == FailoverNone {
+			return false
+		}
+
+		if s.isFailed(server) {
+			return true
+		}
+		owner = server
+		return false
+	})
+
+	return owner
+}
+
+// SelectForDelete choose servers for deleting, includes the current server of the key
+// and its failover target, because the key might be filled on the failover target
+// while the owner was unavailable
+func (s *shardedRouteSelector) SelectForDelete(key string) []ServerID {
+	var owner ServerID
+	chosen := false
+	var result []ServerID
+
+	s.route.walkRing(key, func(server ServerID) bool {
+		if !chosen {
+			owner = server
+			chosen = true
+		}
+
+		if s.route.conf.failoverPolicy == FailoverNone {
+			return false
+		}
+
+		if s.isFailed(server) {
+			return true
+		}
+		result = append(result, server)
+		return len(result) < 2
+	})
+
+	if len(result) == 0 {
+		return []ServerID{owner}
+	}
+	return result
+}
+
+// SkippedForDelete returns the failed servers before the servers of SelectForDelete on the ring,
+// the owner of the key is one of them when it failed
+func (s *shardedRouteSelector) SkippedForDelete(key string) []ServerID {
+	if s.route.conf.failoverPolicy == FailoverNone || len(s.failedServers) >= len(s.route.configServers) {
+		return nil
+	}
+
+	var result []ServerID
+	s.route.walkRing(key, func(server ServerID) bool {
+		if !s.isFailed(server) {
+			return false
+		}
+		result = append(result, server)
+		return true
+	})
+	return result
+}
+
+// Reset the selection
+func (*shardedRouteSelector) Reset() {
+}
@@ -0,0 +1,215 @@
+package proxy
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const serverID3 ServerID = 33
+
+type shardedRouteTest struct {
+	stats *ServerStatsMock
+
+	route    Route
+	selector Selector
+}
+
+func newShardedRouteTest(servers []ServerID, options ...ShardedRouteOption) *shardedRouteTest {
+	r := &shardedRouteTest{}
+
+	r.stats = &ServerStatsMock{
+		NotifyServerFailedFunc: func(server ServerID) {
+		},
+		IsServerFailedFunc: func(server ServerID) bool {
+			return false
+		},
+	}
+
+	r.route = NewShardedRoute(servers, r.stats, options...)
+	r.selector = r.route.NewSelector()
+
+	return r
+}
+
+func countShardedKeys(selector Selector, numKeys int) map[ServerID]int {
+	counters := map[ServerID]int{}
+	for i := 0; i < numKeys; i++ {
+		counters[selector.SelectServer(fmt.Sprintf("key:%d", i))]++
+	}
+	return counters
+}
+
+func TestShardedRoute(t *testing.T) {
+	allServers := []ServerID{serverID1, serverID2, serverID3}
+
+	t.Run("same key same server", func(t *testing.T) {
+		r := newShardedRouteTest(allServers)
+
+		server := r.selector.SelectServer("key01")
+		assert.Equal(t, server, r.selector.SelectServer("key01"))
+		assert.Equal(t, server, r.route.NewSelector().SelectServer("key01"))
+
+		r.selector.Reset()
+		assert.Equal(t, server, r.selector.SelectServer("key01"))
+
+		assert.Equal(t, allServers, r.route.AllServerIDs())
+	})
+
+	t.Run("keys distributed between servers", func(t *testing.T) {
+		r := newShardedRouteTest(allServers)
+
+		const numKeys = 30000
+		counters := countShardedKeys(r.selector, numKeys)
+
+		assert.Equal(t, 3, len(counters))
+		for _, server := range allServers {
+			assert.InDelta(t, numKeys/3, counters[server], numKeys*0.05)
+		}
+	})
+
+	t.Run("adding server only moves keys to the new server", func(t *testing.T) {
+		before := newShardedRouteTest([]ServerID{serverID1, serverID2})
+		after := newShardedRouteTest(allServers)
+
+		const numKeys = 10000
+		moved := 0
+		for i := 0; i < numKeys; i++ {
+			key := fmt.Sprintf("key:%d", i)
+			oldServer := before.selector.SelectServer(key)
+			newServer := after.selector.SelectServer(key)
+			if oldServer == newServer {
+				continue
+			}
+			moved++
+			assert.Equal(t, serverID3, newServer)
+		}
+		assert.InDelta(t, numKeys/3, moved, numKeys*0.05)
+	})
+
+	t.Run("single server", func(t *testing.T) {
+		r := newShardedRouteTest([]ServerID{serverID1}, WithVirtualNodes(4))
+
+		assert.Equal(t, serverID1, r.selector.SelectServer("key01"))
+		assert.Equal(t, []ServerID{serverID1}, r.selector.SelectForDelete("key01"))
+	})
+
+	t.Run("invalid config", func(t *testing.T) {
+		assert.Panics(t, func() {
+			NewShardedRoute(nil, &ServerStatsMock{})
+		})
+		assert.Panics(t, func() {
+			NewShardedRoute(allServers, &ServerStatsMock{}, WithVirtualNodes(0))
+		})
+	})
+}
+
+func TestShardedRoute_Failover(t *testing.T) {
+	allServers := []ServerID{serverID1, serverID2, serverID3}
+
+	t.Run("failover to next node", func(t *testing.T) {
+		r := newShardedRouteTest(allServers)
+
+		const numKeys = 1000
+		owners := make([]ServerID, numKeys)
+		for i := range owners {
+			owners[i] = r.selector.SelectServer(fmt.Sprintf("key:%d", i))
+		}
+
+		r.selector.SetFailedServer(serverID2)
+		r.selector.SetFailedServer(serverID2)
+
+		assert.Equal(t, 1, len(r.stats.NotifyServerFailedCalls()))
+		assert.Equal(t, serverID2, r.stats.NotifyServerFailedCalls()[0].Server)
+		assert.Equal(t, true, r.selector.HasNextAvailableServer())
+
+		for i, owner := range owners {
+			server := r.selector.SelectServer(fmt.Sprintf("key:%d", i))
+			if owner != serverID2 {
+				assert.Equal(t, owner, server)
+			} else {
+				assert.NotEqual(t, serverID2, server)
+			}
+		}
+
+		r.selector.SetFailedServer(serverID1)
+		r.selector.SetFailedServer(serverID3)
+		assert.Equal(t, false, r.selector.HasNextAvailableServer())
+
+		// all failed, back to the owners
+		for i, owner := range owners {
+			assert.Equal(t, owner, r.selector.SelectServer(fmt.Sprintf("key:%d", i)))
+		}
+	})
+
+	t.Run("failed servers from stats", func(t *testing.T) {
+		r := newShardedRouteTest(allServers)
+		r.stats.IsServerFailedFunc = func(server ServerID) bool {
+			return server == serverID1
+		}
+		r.selector = r.route.NewSelector()
+
+		counters := countShardedKeys(r.selector, 1000)
+		assert.Equal(t, 0, counters[serverID1])
+		assert.Equal(t, 1000, counters[serverID2]+counters[serverID3])
+		assert.Equal(t, 0, len(r.stats.NotifyServerFailedCalls()))
+	})
+
+	t.Run("failover none", func(t *testing.T) {
+		r := newShardedRouteTest(allServers, WithFailoverPolicy(FailoverNone))
+
+		server := r.selector.SelectServer("key01")
+		assert.Equal(t, []ServerID{server}, r.selector.SelectForDelete("key01"))
+
+		r.selector.SetFailedServer(server)
+		assert.Equal(t, false, r.selector.HasNextAvailableServer())
+		assert.Equal(t, server, r.selector.SelectServer("key01"))
+		assert.Equal(t, []ServerID{server}, r.selector.SelectForDelete("key01"))
+	})
+
+	t.Run("select for delete covers failover target", func(t *testing.T) {
+		r := newShardedRouteTest(allServers)
+
+		owner := r.selector.SelectServer("key01")
+		deleted := r.selector.SelectForDelete("key01")
+		assert.Equal(t, 2, len(deleted))
+		assert.Equal(t, owner, deleted[0])
+
+		r.selector.SetFailedServer(owner)
+		target := r.selector.SelectServer("key01")
+		assert.Equal(t, deleted[1], target)
+
+		deleted = r.selector.SelectForDelete("key01")
+		assert.Equal(t, 2, len(deleted))
+		assert.Equal(t, target, deleted[0])
+		assert.NotContains(t, deleted, owner)
+
+		r.selector.SetFailedServer(deleted[1])
+		assert.Equal(t, []ServerID{target}, r.selector.SelectForDelete("key01"))
+
+		r.selector.SetFailedServer(target)
+		assert.Equal(t, []ServerID{owner}, r.selector.SelectForDelete("key01"))
+	})
+
+	t.Run("skipped for delete", func(t *testing.T) {
+		r := newShardedRouteTest(allServers)
+		skipped := r.selector.(SkippedDeleteSelector)
+
+		owner := r.selector.SelectServer("key01")
+		deleted := r.selector.SelectForDelete("key01")
+		assert.Equal(t, []ServerID(nil), skipped.SkippedForDelete("key01"))
+
+		r.selector.SetFailedServer(owner)
+		assert.Equal(t, []ServerID{owner}, skipped.SkippedForDelete("key01"))
+
+		r.selector.SetFailedServer(deleted[1])
+		assert.Equal(t, []ServerID{owner, deleted[1]}, skipped.SkippedForDelete("key01"))
+
+		// all failed
+		for _, server := range allServers {
+			r.selector.SetFailedServer(server)
+		}
+		assert.Equal(t, []ServerID(nil), skipped.SkippedForDelete("key01"))
+	})
+}
@@ -1,9 +1,11 @@
 package proxy
 
 import (
+	"errors"
 	"fmt"
 	mcstats "github.com/QuangTung97/go-memcache/memcache/stats"
 	"log"
+	"reflect"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -20,6 +22,7 @@ type simpleStatsConfig struct {
 	errorLogger   func(err error)
 	memLogger     func(server ServerID, mem uint64, err error)
 	checkDuration time.Duration
+	recovered     func(server ServerID)
 }
 
 // SimpleStatsOption ...
@@ -46,6 +49,15 @@ func WithSimpleStatsCheckDuration(d time.Duration) SimpleStatsOption {
 	}
 }
 
+// WithSimpleStatsRecoveredFunc configures the function called when a failed server is healthy again,
+// e.g. for replaying the deletes recorded in the InvalidationQueue.
+// The function is called on the stats goroutine of the server, it SHOULD NOT block
+func WithSimpleStatsRecoveredFunc(fn func(server ServerID)) SimpleStatsOption {
+	return func(conf *simpleStatsConfig) {
+		conf.recovered = fn
+	}
+}
+
 func computeSimpleStatsConfig(options ...SimpleStatsOption) *simpleStatsConfig {
 	conf := &simpleStatsConfig{
 		errorLogger: func(err error) {
@@ -54,6 +66,8 @@ func computeSimpleStatsConfig(options ...SimpleStatsOption) *simpleStatsConfig {
 		memLogger: func(server ServerID, mem uint64, err error) {
 		},
 		checkDuration: 30 * time.Second,
+		recovered: func(server ServerID) {
+		},
 	}
 	for _, option := range options {
 		option(conf)
@@ -67,12 +81,26 @@ type SimpleServerStats struct {
 
 	wg sync.WaitGroup
 
-	//revive:disable-next-line:nested-structs
-	clientSignals map[ServerID]chan struct{}
+	mut      sync.Mutex
+	shutdown bool
+
+	checkDuration atomic.Int64
 
-	statuses map[ServerID]*serverStatus
+	// immutable map, replaced when the servers are updated
+	servers atomic.Pointer[map[ServerID]*statsServer]
 
-	newClientFunc func(server ServerID) StatsClient
+	updateFunc func(servers any) error
+}
+
+// statsServer is the state of the stats goroutine of a server
+type statsServer struct {
+	id     ServerID
+	conf   any // for detecting config changes
+	status *serverStatus
+	signal chan struct{}
+	stop   chan struct{}
+
+	newClient func() StatsClient
 }
 
 // StatsClient ...
@@ -94,71 +122,138 @@ func NewSimpleServerStats[S ServerConfig](
 ) *SimpleServerStats {
 	conf := computeSimpleStatsConfig(options...)
 
-	clients := map[ServerID]StatsClient{}
-	clientSignals := map[ServerID]chan struct{}{}
-	statuses := map[ServerID]*serverStatus{}
-	confMap := map[ServerID]S{}
+	s := &SimpleServerStats{
+		conf: conf,
+	}
+	s.checkDuration.Store(int64(conf.checkDuration))
 
-	for _, server := range servers {
-		confMap[server.GetID()] = server
+	emptyServers := map[ServerID]*statsServer{}
+	s.servers.Store(&emptyServers)
 
-		client := factory(server)
+	s.updateFunc = func(servers any) error {
+		serverList, ok := servers.([]S)
+		if !ok {
+			return fmt.Errorf("proxy: invalid server list type %T", servers)
+		}
 
-		clients[server.GetID()] = client
-		clientSignals[server.GetID()] = make(chan struct{}, signalChanSize)
-		statuses[server.GetID()] = &serverStatus{}
+		newServers := make([]*statsServer, 0, len(serverList))
+		for _, server := range serverList {
+			server := server
+			newServers = append(newServers, &statsServer{
+				id:     server.GetID(),
+				conf:   server,
+				status: &serverStatus{},
+				signal: make(chan struct{}, signalChanSize),
+				stop:   make(chan struct{}),
+
+				newClient: func() StatsClient {
+					return factory(server)
+				},
+			})
+		}
+
+		return s.doUpdateServers(newServers)
 	}
 
-	s := &SimpleServerStats{
-		conf: conf,
+	_ = s.updateFunc(servers)
 
-		clientSignals: clientSignals,
-		statuses:      statuses,
-		newClientFunc: func(server ServerID) StatsClient {
-			return factory(confMap[server])
-		},
+	return s
+}
+
+// UpdateServers starts the goroutines of the new servers and the servers with changed config,
+// and stops the goroutines of the removed servers.
+// The servers MUST be a slice of the same config type passed to NewSimpleServerStats
+func (s *SimpleServerStats) UpdateServers(servers any) error {
+	return s.updateFunc(servers)
+}
+
+func (s *SimpleServerStats) doUpdateServers(newServers []*statsServer) error {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	if s.shutdown {
+		return errors.New("proxy: server stats already shutdown")
 	}
 
-	for _, server := range servers {
-		client := clients[server.GetID()]
-		clients[server.GetID()] = s.clientGetMemory(server.GetID(), client)
+	oldServers := *s.servers.Load()
+	servers := make(map[ServerID]*statsServer, len(newServers))
+
+	startServers := make([]*statsServer, 0, len(newServers))
+	for _, server := range newServers {
+		prev, existed := oldServers[server.id]
+		if existed && reflect.DeepEqual(prev.conf, server.conf) {
+			servers[server.id] = prev
+			continue
+		}
+		servers[server.id] = server
+		startServers = append(startServers, server)
+	}
+
+	clients := make([]StatsClient, 0, len(startServers))
+	for _, server := range startServers {
+		clients = append(clients, server.newClient())
 	}
 
-	s.wg.Add(len(servers))
+	for i, server := range startServers {
+		clients[i] = s.clientGetMemory(server, clients[i])
+	}
+
+	s.servers.Store(&servers)
+
+	for id, prev := range oldServers {
+		if servers[id] != prev {
+			close(prev.stop)
+		}
+	}
 
-	for _, server := range servers {
-		serverID := server.GetID()
+	s.wg.Add(len(startServers))
 
-		client := clients[serverID]
-		ch := clientSignals[serverID]
+	for i, server := range startServers {
+		server := server
+		client := clients[i]
 
 		go func() {
 			defer s.wg.Done()
 
-			s.handleClient(serverID, client, ch)
+			s.handleClient(server, client)
 		}()
 	}
 
-	return s
+	return nil
+}
+
+// SetCheckDuration changes the duration between memory checks, applied after the next check of each server
+func (s *SimpleServerStats) SetCheckDuration(d time.Duration) {
+	s.checkDuration.Store(int64(d))
+}
+
+func (s *SimpleServerStats) getCheckDuration() time.Duration {
+	return time.Duration(s.checkDuration.Load())
+}
+
+func (s *SimpleServerStats) getServer(server ServerID) *statsServer {
+	return (*s.servers.Load())[server]
 }
 
-func (s *SimpleServerStats) clientGetMemory(server ServerID, client StatsClient) StatsClient {
-	status := s.statuses[server]
+func (s *SimpleServerStats) clientGetMemory(server *statsServer, client StatsClient) StatsClient {
+	status := server.status
 
 	if status.failed.Load() {
 		_ = client.Close()
-		client = s.newClientFunc(server)
+		client = server.newClient()
 	}
 
 	mem, err := client.GetMemUsage()
-	s.conf.memLogger(server, mem, err)
+	s.conf.memLogger(server.id, mem, err)
 	if err != nil {
 		s.conf.errorLogger(err)
 		status.failed.Store(true)
 		return client
 	}
-	status.failed.Store(false)
 	status.memory.Store(mem)
+	if status.failed.Swap(false) {
+		s.conf.recovered(server.id)
+	}
 	return client
 }
 
@@ -171,18 +266,19 @@ func drainSignal(signal <-chan struct{}) {
 	}
 }
 
-func (s *SimpleServerStats) handleClient(server ServerID, client StatsClient, signal <-chan struct{}) {
+func (s *SimpleServerStats) handleClient(server *statsServer, client StatsClient) {
 	alreadySignaled := false
-	timer := time.NewTimer(s.conf.checkDuration)
+	timer := time.NewTimer(s.getCheckDuration())
 
 	for {
 		select {
-		case _, ok := <-signal:
-			if !ok {
-				_ = client.Close()
-				return
-			}
-			drainSignal(signal)
+		case <-server.stop:
+			timer.Stop()
+			_ = client.Close()
+			return
+
+		case <-server.signal:
+			drainSignal(server.signal)
 
 			if alreadySignaled {
 				continue
@@ -192,7 +288,7 @@ func (s *SimpleServerStats) handleClient(server ServerID, client StatsClient, si
 			if !timer.Stop() {
 				<-timer.C
 			}
-			timer.Reset(s.conf.checkDuration)
+			timer.Reset(s.getCheckDuration())
 
 			client = s.clientGetMemory(server, client)
 
@@ -200,36 +296,53 @@ func (s *SimpleServerStats) handleClient(server ServerID, client StatsClient, si
 			client = s.clientGetMemory(server, client)
 			alreadySignaled = false
 
-			timer.Reset(s.conf.checkDuration)
+			timer.Reset(s.getCheckDuration())
 		}
 	}
 }
 
 // IsServerFailed check whether the server is currently not connected
 func (s *SimpleServerStats) IsServerFailed(server ServerID) bool {
-	return s.statuses[server].failed.Load()
+	st := s.getServer(server)
+	if st == nil {
+		return false
+	}
+	return st.status.failed.Load()
 }
 
 // NotifyServerFailed ...
 func (s *SimpleServerStats) NotifyServerFailed(server ServerID) {
-	ch := s.clientSignals[server]
+	st := s.getServer(server)
+	if st == nil {
+		return
+	}
+
 	select {
-	case ch <- struct{}{}:
+	case st.signal <- struct{}{}:
 	default:
 	}
 }
 
 // GetMemUsage returns memory usage in bytes
 func (s *SimpleServerStats) GetMemUsage(server ServerID) float64 {
-	status := s.statuses[server]
-	return float64(status.memory.Load())
+	st := s.getServer(server)
+	if st == nil {
+		return 0
+	}
+	return float64(st.status.memory.Load())
 }
 
 // Shutdown ...
 func (s *SimpleServerStats) Shutdown() {
-	for _, ch := range s.clientSignals {
-		close(ch)
+	s.mut.Lock()
+	if !s.shutdown {
+		s.shutdown = true
+		for _, server := range *s.servers.Load() {
+			close(server.stop)
+		}
 	}
+	s.mut.Unlock()
+
 	s.wg.Wait()
 }
 
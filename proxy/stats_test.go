@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"github.com/stretchr/testify/assert"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -309,3 +310,55 @@ func TestSimpleServerStats(t *testing.T) {
 		assert.Equal(t, 1, len(s.clients[serverID2].CloseCalls()))
 	})
 }
+
+func TestSimpleServerStats_RecoveredFunc(t *testing.T) {
+	var failing atomic.Bool
+
+	var mut sync.Mutex
+	var recovered []ServerID
+
+	getRecovered := func() []ServerID {
+		mut.Lock()
+		defer mut.Unlock()
+		return append([]ServerID(nil), recovered...)
+	}
+
+	stats := NewSimpleServerStats[SimpleServerConfig]([]SimpleServerConfig{
+		{ID: serverID1, Host: "localhost", Port: 11201},
+	}, func(conf SimpleServerConfig) StatsClient {
+		return &StatsClientMock{
+			CloseFunc: func() error { return nil },
+			GetMemUsageFunc: func() (uint64, error) {
+				if failing.Load() {
+					return 0, errors.New("some error")
+				}
+				return 8000, nil
+			},
+		}
+	},
+		WithSimpleStatsCheckDuration(50*time.Millisecond),
+		WithSimpleStatsErrorLogger(func(err error) {}),
+		WithSimpleStatsRecoveredFunc(func(server ServerID) {
+			mut.Lock()
+			recovered = append(recovered, server)
+			mut.Unlock()
+		}),
+	)
+	defer stats.Shutdown()
+
+	failing.Store(true)
+	stats.NotifyServerFailed(serverID1)
+	assert.Eventually(t, func() bool {
+		return stats.IsServerFailed(serverID1)
+	}, time.Second, 5*time.Millisecond)
+	assert.Equal(t, []ServerID(nil), getRecovered())
+
+	// recovered on the next check
+	failing.Store(false)
+
+	assert.Eventually(t, func() bool {
+		return len(getRecovered()) == 1
+	}, time.Second, 5*time.Millisecond)
+	assert.Equal(t, false, stats.IsServerFailed(serverID1))
+	assert.Equal(t, []ServerID{serverID1}, getRecovered())
+}
@@ -451,6 +451,7 @@ func TestItemProxy__FailOver__Filler__On_Multi_Keys(t *testing.T) {
 
 		"lease-set: TENANT01:USER01",
 		"execute 1",
+		"lease-set-func: TENANT01:USER01",
 
 		"rand-func",
 		"lease-get: TENANT02:USER02",
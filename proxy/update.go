@@ -0,0 +1,137 @@
+package proxy
+
+import (
+	"reflect"
+	"sync/atomic"
+
+	"github.com/QuangTung97/memproxy"
+)
+
+type memcacheClient struct {
+	conf   any // for detecting config changes
+	client memproxy.Memcache
+
+	// number of unreleased states containing the client, guarded by Memcache.mut
+	owners int
+}
+
+// memcacheState is immutable after created
+type memcacheState struct {
+	root *Memcache
+
+	clients map[ServerID]*memcacheClient
+	route   Route
+
+	// number of pipelines using the state, plus one if it is the current state of Memcache
+	refs atomic.Int64
+}
+
+// UpdateConfig swaps the server set and route of the Memcache atomically.
+// The S MUST be the same server config type passed to New, otherwise an error is returned.
+// Clients of the new servers (or servers with changed config) are created,
+// clients of the removed servers are closed after all pipelines using them finished (Pipeline.Finish is called).
+// Pipelines created before this call keep using the old servers and route.
+// Returns an error if the Memcache is already closed
+func UpdateConfig[S ServerConfig](m *Memcache, conf Config[S]) error {
+	return m.updateFunc(conf, true)
+}
+
+func (m *Memcache) swapState(
+	servers []ServerID, configs []any, route Route,
+	newClient func(index int) memproxy.Memcache,
+) {
+	m.mut.Lock()
+	defer m.mut.Unlock()
+
+	var oldClients map[ServerID]*memcacheClient
+	old := m.state.Load()
+	if old != nil {
+		oldClients = old.clients
+	}
+
+	state := &memcacheState{
+		root:    m,
+		clients: make(map[ServerID]*memcacheClient, len(servers)),
+		route:   route,
+	}
+	state.refs.Store(1)
+
+	for index, server := range servers {
+		prev, existed := oldClients[server]
+		if existed && reflect.DeepEqual(prev.conf, configs[index]) {
+			prev.owners++
+			state.clients[server] = prev
+			continue
+		}
+
+		client := &memcacheClient{
+			conf:   configs[index],
+			client: newClient(index),
+			owners: 1,
+		}
+		m.liveClients[client] = struct{}{}
+		state.clients[server] = client
+	}
+
+	m.state.Store(state)
+
+	if old != nil {
+		old.releaseLocked()
+	}
+}
+
+func (m *Memcache) acquireState() *memcacheState {
+	for {
+		state := m.state.Load()
+		if state.tryAcquire() {
+			return state
+		}
+	}
+}
+
+func (s *memcacheState) tryAcquire() bool {
+	for {
+		n := s.refs.Load()
+		if n <= 0 {
+			// already released, the current state has been swapped
+			return false
+		}
+		if s.refs.CompareAndSwap(n, n+1) {
+			return true
+		}
+	}
+}
+
+func (s *memcacheState) release() {
+	if s.refs.Add(-1) > 0 {
+		return
+	}
+
+	s.root.mut.Lock()
+	defer s.root.mut.Unlock()
+
+	s.closeUnusedClients()
+}
+
+// releaseLocked is called with Memcache.mut locked
+func (s *memcacheState) releaseLocked() {
+	if s.refs.Add(-1) > 0 {
+		return
+	}
+	s.closeUnusedClients()
+}
+
+func (s *memcacheState) closeUnusedClients() {
+	m := s.root
+	for _, client := range s.clients {
+		client.owners--
+		if client.owners > 0 {
+			continue
+		}
+
+		if _, live := m.liveClients[client]; live {
+			delete(m.liveClients, client)
+			_ = client.client.Close()
+		}
+	}
+}
@@ -0,0 +1,368 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/QuangTung97/memproxy"
+	"github.com/QuangTung97/memproxy/mocks"
+)
+
+type updateConfigTest struct {
+	mut     sync.Mutex
+	newArgs []SimpleServerConfig
+	clients []*mocks.MemcacheMock
+
+	stats *ServerStatsMock
+	mc    *Memcache
+}
+
+func (u *updateConfigTest) newClient(conf SimpleServerConfig) memproxy.Memcache {
+	u.mut.Lock()
+	defer u.mut.Unlock()
+
+	client := &mocks.MemcacheMock{
+		PipelineFunc: func(ctx context.Context, options ...memproxy.PipelineOption) memproxy.Pipeline {
+			return &mocks.PipelineMock{
+				DeleteFunc: func(key string, options memproxy.DeleteOptions) func() (memproxy.DeleteResponse, error) {
+					return func() (memproxy.DeleteResponse, error) {
+						return memproxy.DeleteResponse{}, nil
+					}
+				},
+				FinishFunc: func() {},
+			}
+		},
+		CloseFunc: func() error { return nil },
+	}
+
+	u.newArgs = append(u.newArgs, conf)
+	u.clients = append(u.clients, client)
+	return client
+}
+
+func (u *updateConfigTest) getNewArgs() []SimpleServerConfig {
+	u.mut.Lock()
+	defer u.mut.Unlock()
+	return append([]SimpleServerConfig(nil), u.newArgs...)
+}
+
+func (u *updateConfigTest) closeCount(index int) int {
+	u.mut.Lock()
+	client := u.clients[index]
+	u.mut.Unlock()
+	return len(client.CloseCalls())
+}
+
+func newUpdateServer(id ServerID, port uint16) SimpleServerConfig {
+	return SimpleServerConfig{
+		ID:   id,
+		Host: "localhost",
+		Port: port,
+	}
+}
+
+func (u *updateConfigTest) newConfig(servers ...SimpleServerConfig) Config[SimpleServerConfig] {
+	ids := make([]ServerID, 0, len(servers))
+	for _, s := range servers {
+		ids = append(ids, s.ID)
+	}
+	return Config[SimpleServerConfig]{
+		Servers: servers,
+		Route:   NewReplicatedRoute(ids, u.stats, alwaysRand(0)),
+	}
+}
+
+func newUpdateConfigTest(t *testing.T, options ...MemcacheOption) *updateConfigTest {
+	u := &updateConfigTest{
+		stats: newComposedStats(),
+	}
+
+	mc, err := New[SimpleServerConfig](
+		u.newConfig(newUpdateServer(serverID1, 11211)),
+		u.newClient,
+		options...,
+	)
+	assert.Equal(t, nil, err)
+	u.mc = mc
+
+	return u
+}
+
+func deleteServers(pipe memproxy.Pipeline, key string) {
+	_, _ = pipe.Delete(key, memproxy.DeleteOptions{})()
+}
+
+func TestMemcache_UpdateConfig(t *testing.T) {
+	t.Run("add server", func(t *testing.T) {
+		u := newUpdateConfigTest(t)
+
+		oldPipe := u.mc.Pipeline(newContext())
+
+		err := UpdateConfig(u.mc, u.newConfig(
+			newUpdateServer(serverID1, 11211),
+			newUpdateServer(serverID2, 11212),
+		))
+		assert.Equal(t, nil, err)
+
+		// only new server is created
+		assert.Equal(t, []SimpleServerConfig{
+			newUpdateServer(serverID1, 11211),
+			newUpdateServer(serverID2, 11212),
+		}, u.getNewArgs())
+
+		// old pipeline uses the old route
+		deleteServers(oldPipe, "key01")
+		assert.Equal(t, 1, len(u.clients[0].PipelineCalls()))
+		assert.Equal(t, 0, len(u.clients[1].PipelineCalls()))
+
+		newPipe := u.mc.Pipeline(newContext())
+		deleteServers(newPipe, "key01")
+		assert.Equal(t, 2, len(u.clients[0].PipelineCalls()))
+		assert.Equal(t, 1, len(u.clients[1].PipelineCalls()))
+
+		oldPipe.Finish()
+		newPipe.Finish()
+
+		assert.Equal(t, 0, u.closeCount(0))
+		assert.Equal(t, 0, u.closeCount(1))
+
+		client, err := u.mc.ServerMemcache(serverID2)
+		assert.Equal(t, nil, err)
+		assert.Same(t, u.clients[1], client)
+
+		assert.Equal(t, nil, u.mc.Close())
+		assert.Equal(t, 1, u.closeCount(0))
+		assert.Equal(t, 1, u.closeCount(1))
+	})
+
+	t.Run("remove server closed after in-flight pipelines finished", func(t *testing.T) {
+		u := newUpdateConfigTest(t)
+
+		err := UpdateConfig(u.mc, u.newConfig(
+			newUpdateServer(serverID1, 11211),
+			newUpdateServer(serverID2, 11212),
+		))
+		assert.Equal(t, nil, err)
+
+		pipe1 := u.mc.Pipeline(newContext())
+		pipe2 := u.mc.Pipeline(newContext())
+
+		err = UpdateConfig(u.mc, u.newConfig(newUpdateServer(serverID1, 11211)))
+		assert.Equal(t, nil, err)
+
+		assert.Equal(t, 2, len(u.getNewArgs()))
+		assert.Equal(t, 0, u.closeCount(1))
+
+		// the in-flight pipeline still can use the removed server
+		deleteServers(pipe1, "key01")
+		assert.Equal(t, 1, len(u.clients[1].PipelineCalls()))
+
+		pipe1.Finish()
+		pipe1.Finish()
+		assert.Equal(t, 0, u.closeCount(1))
+
+		pipe2.Finish()
+		assert.Equal(t, 1, u.closeCount(1))
+		assert.Equal(t, 0, u.closeCount(0))
+
+		_, err = u.mc.ServerMemcache(serverID2)
+		assert.Equal(t, errors.New("proxy: server id '32' not in server list"), err)
+
+		assert.Equal(t, nil, u.mc.Close())
+		assert.Equal(t, 1, u.closeCount(0))
+		assert.Equal(t, 1, u.closeCount(1))
+	})
+
+	t.Run("server config changed", func(t *testing.T) {
+		u := newUpdateConfigTest(t)
+
+		err := UpdateConfig(u.mc, u.newConfig(newUpdateServer(serverID1, 11300)))
+		assert.Equal(t, nil, err)
+
+		assert.Equal(t, []SimpleServerConfig{
+			newUpdateServer(serverID1, 11211),
+			newUpdateServer(serverID1, 11300),
+		}, u.getNewArgs())
+
+		assert.Equal(t, 1, u.closeCount(0))
+		assert.Equal(t, 0, u.closeCount(1))
+	})
+
+	t.Run("invalid config", func(t *testing.T) {
+		u := newUpdateConfigTest(t)
+
+		err := UpdateConfig(u.mc, Config[ServerConfig]{})
+		assert.Equal(t, errors.New(
+			"proxy: invalid config type proxy.Config[github.com/QuangTung97/memproxy/proxy.ServerConfig]",
+		), err)
+
+		err = UpdateConfig(u.mc, Config[SimpleServerConfig]{})
+		assert.Equal(t, errors.New("proxy: empty server list"), err)
+
+		conf := u.newConfig(newUpdateServer(serverID1, 11211), newUpdateServer(serverID2, 11212))
+		conf.Servers = conf.Servers[:1]
+		err = UpdateConfig(u.mc, conf)
+		assert.Equal(t, errors.New("proxy: server id '32' not in server list"), err)
+
+		// not changed
+		assert.Equal(t, 1, len(u.getNewArgs()))
+		_, err = u.mc.ServerMemcache(serverID1)
+		assert.Equal(t, nil, err)
+	})
+
+	t.Run("already closed", func(t *testing.T) {
+		u := newUpdateConfigTest(t)
+		assert.Equal(t, nil, u.mc.Close())
+
+		err := UpdateConfig(u.mc, u.newConfig(newUpdateServer(serverID2, 11212)))
+		assert.Equal(t, errors.New("proxy: memcache already closed"), err)
+
+		// no client is created
+		assert.Equal(t, 1, len(u.getNewArgs()))
+	})
+
+	t.Run("update simple stats", func(t *testing.T) {
+		var mut sync.Mutex
+		statsClients := map[SimpleServerConfig]*StatsClientMock{}
+
+		stats := NewSimpleServerStats[SimpleServerConfig](
+			[]SimpleServerConfig{newUpdateServer(serverID1, 11211)},
+			func(conf SimpleServerConfig) StatsClient {
+				mut.Lock()
+				defer mut.Unlock()
+
+				client := &StatsClientMock{
+					GetMemUsageFunc: func() (uint64, error) {
+						return uint64(conf.Port), nil
+					},
+					CloseFunc: func() error { return nil },
+				}
+				statsClients[conf] = client
+				return client
+			},
+		)
+		defer stats.Shutdown()
+
+		u := newUpdateConfigTest(t, WithMemcacheSimpleStats(stats))
+
+		err := UpdateConfig(u.mc, u.newConfig(newUpdateServer(serverID2, 11212)))
+		assert.Equal(t, nil, err)
+
+		assert.Equal(t, float64(0), stats.GetMemUsage(serverID1))
+		assert.Equal(t, float64(11212), stats.GetMemUsage(serverID2))
+
+		stats.Shutdown()
+
+		mut.Lock()
+		defer mut.Unlock()
+		assert.Equal(t, 2, len(statsClients))
+		assert.Equal(t, 1, len(statsClients[newUpdateServer(serverID1, 11211)].CloseCalls()))
+		assert.Equal(t, 1, len(statsClients[newUpdateServer(serverID2, 11212)].CloseCalls()))
+	})
+}
+
+func TestMemcache_UpdateConfig_Health_Checker(t *testing.T) {
+	checker, err := NewHealthChecker[SimpleServerConfig](
+		[]SimpleServerConfig{newUpdateServer(serverID1, 11211)},
+		newComposedStats(), nil,
+	)
+	assert.Equal(t, nil, err)
+	defer checker.Shutdown()
+
+	u := newUpdateConfigTest(t, WithMemcacheHealthChecker(checker))
+
+	err = UpdateConfig(u.mc, u.newConfig(newUpdateServer(serverID2, 11212)))
+	assert.Equal(t, nil, err)
+
+	_, ok := checker.getServer(serverID1)
+	assert.Equal(t, false, ok)
+	_, ok = checker.getServer(serverID2)
+	assert.Equal(t, true, ok)
+}
+
+func TestMemcache_UpdateConfig_Concurrent(t *testing.T) {
+	u := newUpdateConfigTest(t)
+
+	var wg sync.WaitGroup
+	wg.Add(4)
+
+	for i := 0; i < 3; i++ {
+		go func() {
+			defer wg.Done()
+			for k := 0; k < 1000; k++ {
+				pipe := u.mc.Pipeline(context.Background())
+				deleteServers(pipe, "key01")
+				pipe.Finish()
+			}
+		}()
+	}
+
+	go func() {
+		defer wg.Done()
+		for k := 0; k < 100; k++ {
+			port := uint16(12000 + k)
+			err := UpdateConfig(u.mc, u.newConfig(
+				newUpdateServer(serverID1, 11211),
+				newUpdateServer(serverID2, port),
+			))
+			assert.Equal(t, nil, err)
+		}
+	}()
+
+	wg.Wait()
+
+	// all clients of removed servers are closed
+	numClients := len(u.getNewArgs())
+	assert.Equal(t, 101, numClients)
+	for i := 1; i < numClients-1; i++ {
+		assert.Equal(t, 1, u.closeCount(i))
+	}
+	assert.Equal(t, 0, u.closeCount(0))
+	assert.Equal(t, 0, u.closeCount(numClients-1))
+}
+
+func TestMemcache_UpdateConfig_Concurrent_With_Stats(t *testing.T) {
+	stats := NewSimpleServerStats[SimpleServerConfig](
+		[]SimpleServerConfig{newUpdateServer(serverID1, 11211)},
+		func(conf SimpleServerConfig) StatsClient {
+			return &StatsClientMock{
+				GetMemUsageFunc: func() (uint64, error) {
+					return uint64(conf.Port), nil
+				},
+				CloseFunc: func() error { return nil },
+			}
+		},
+	)
+	defer stats.Shutdown()
+
+	u := newUpdateConfigTest(t, WithMemcacheSimpleStats(stats))
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+
+	for _, server := range []SimpleServerConfig{
+		newUpdateServer(serverID1, 11211),
+		newUpdateServer(serverID2, 11212),
+	} {
+		server := server
+		go func() {
+			defer wg.Done()
+			for k := 0; k < 100; k++ {
+				err := UpdateConfig(u.mc, u.newConfig(server))
+				assert.Equal(t, nil, err)
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	// the servers of the stats are the same as the servers of the current state
+	for _, server := range []ServerID{serverID1, serverID2} {
+		_, err := u.mc.ServerMemcache(server)
+		assert.Equal(t, err == nil, stats.GetMemUsage(server) > 0)
+	}
+}
@@ -0,0 +1,250 @@
+package secondary
+
+import (
+	"context"
+
+	"github.com/QuangTung97/memproxy"
+	"github.com/QuangTung97/memproxy/item"
+)
+
+// PrimaryKey is the primary key constraint, it is also the value stored for each alternate key
+type PrimaryKey interface {
+	item.Key
+	item.Value
+}
+
+// Option an optional value
+type Option[T any] struct {
+	Valid bool
+	Data  T
+}
+
+type itemConfig struct {
+	itemOptions []item.Option
+}
+
+// ItemOption ...
+type ItemOption func(conf *itemConfig)
+
+// WithItemOptions configures the options of the item storing alternate key => primary key mappings
+func WithItemOptions(options ...item.Option) ItemOption {
+	return func(conf *itemConfig) {
+		conf.itemOptions = options
+	}
+}
+
+// Item looks up values by alternate keys (e.g. email or username),
+// caches the mappings alternate key => primary key, then gets the values from the primary item.
+// Item is NOT thread safe
+type Item[T item.Value, P PrimaryKey, A item.Key] struct {
+	pipeline memproxy.Pipeline
+	sess     memproxy.Session
+
+	primary *item.Item[T, P]
+
+	mapping       *item.Item[P, A]
+	refillMapping *item.Item[P, A]
+
+	getAltKeys func(v T) []A
+
+	stats Stats
+}
+
+// New creates a secondary.Item.
+// Param: primary is the item for getting values by primary keys, and MUST use the same pipeline.
+// Param: unmarshaler is for unmarshalling the primary key type.
+// Param: filler is for fetching primary keys by alternate keys from the backing source (e.g. Database),
+// the zero value of the primary key is considered not found.
+// Param: getAltKeys returns all the alternate keys of a value, for verifying mappings and for invalidating.
+func New[T item.Value, P PrimaryKey, A item.Key](
+	pipeline memproxy.Pipeline,
+	primary *item.Item[T, P],
+	unmarshaler item.Unmarshaler[P],
+	filler item.Filler[P, A],
+	getAltKeys func(v T) []A,
+	options ...ItemOption,
+) *Item[T, P, A] {
+	conf := &itemConfig{}
+	for _, fn := range options {
+		fn(conf)
+	}
+
+	mapping := item.New[P, A](pipeline, unmarshaler, filler, conf.itemOptions...)
+
+	return &Item[T, P, A]{
+		pipeline: pipeline,
+		sess:     mapping.LowerSession(),
+
+		primary: primary,
+
+		mapping:       mapping,
+		refillMapping: item.New[P, A](pipeline, unmarshaler, filler, conf.itemOptions...),
+
+		getAltKeys: getAltKeys,
+	}
+}
+
+type getState[T item.Value, P PrimaryKey, A item.Key] struct {
+	item *Item[T, P, A]
+	ctx  context.Context
+	key  A
+
+	refilled bool
+
+	result Option[T]
+	err    error
+}
+
+// Get a value by an alternate key
+func (i *Item[T, P, A]) Get(ctx context.Context, key A) func() (Option[T], error) {
+	s := &getState[T, P, A]{
+		item: i,
+		ctx:  ctx,
+		key:  key,
+	}
+
+	s.resolveMapping(i.mapping.GetFast(ctx, key))
+
+	return func() (Option[T], error) {
+		i.sess.Execute()
+		return s.result, s.err
+	}
+}
+
+func (s *getState[T, P, A]) resolveMapping(mappingState *item.GetState[P, A]) {
+	i := s.item
+
+	i.sess.AddNextCall(memproxy.NewEmptyCallback(func() {
+		primaryKey, err := mappingState.Result()
+		if err != nil {
+			s.err = err
+			return
+		}
+
+		var zero P
+		if primaryKey == zero {
+			return
+		}
+
+		// calling GetFast in the next round, because calling Result() of other keys
+		// in this round will execute the lease gets of the primary item one by one
+		i.sess.AddNextCall(memproxy.NewEmptyCallback(func() {
+			primaryState := i.primary.GetFast(s.ctx, primaryKey)
+
+			i.sess.AddNextCall(memproxy.NewEmptyCallback(func() {
+				s.handlePrimaryResult(primaryState.Result())
+			}))
+		}))
+	}))
+}
+
+func (s *getState[T, P, A]) handlePrimaryResult(value T, err error) {
+	if err != nil {
+		s.err = err
+		return
+	}
+
+	i := s.item
+
+	if s.containsKey(value) {
+		s.result = Option[T]{
+			Valid: true,
+			Data:  value,
+		}
+		return
+	}
+
+	if s.refilled {
+		return
+	}
+
+	// the mapping is outdated, e.g. the email has been changed to another user
+	i.stats.StaleCount++
+
+	s.refilled = true
+	i.sess.AddNextCall(memproxy.NewEmptyCallback(func() {
+		i.pipeline.Delete(s.key.String(), memproxy.DeleteOptions{})
+		s.resolveMapping(i.refillMapping.GetFast(s.ctx, s.key))
+	}))
+}
+
+func (s *getState[T, P, A]) containsKey(value T) bool {
+	for _, k := range s.item.getAltKeys(value) {
+		if k == s.key {
+			return true
+		}
+	}
+	return false
+}
+
+// GetMulti gets multiple values by alternate keys at once
+func (i *Item[T, P, A]) GetMulti(ctx context.Context, keys []A) func() ([]Option[T], error) {
+	fnList := make([]func() (Option[T], error), 0, len(keys))
+	for _, k := range keys {
+		fnList = append(fnList, i.Get(ctx, k))
+	}
+
+	return func() ([]Option[T], error) {
+		result := make([]Option[T], 0, len(fnList))
+		for _, fn := range fnList {
+			val, err := fn()
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, val)
+		}
+		return result, nil
+	}
+}
+
+// Invalidate deletes the primary key and all the alternate keys of the values.
+// Values should contain both the values before and after changed,
+// for deleting the mappings of the old alternate keys and the not found mappings of the new alternate keys.
+func (i *Item[T, P, A]) Invalidate(key P, values ...T) func() error {
+	fnList := []func() (memproxy.DeleteResponse, error){
+		i.pipeline.Delete(key.String(), memproxy.DeleteOptions{}),
+	}
+
+	for _, v := range values {
+		for _, altKey := range i.getAltKeys(v) {
+			fnList = append(fnList, i.pipeline.Delete(altKey.String(), memproxy.DeleteOptions{}))
+		}
+	}
+
+	return func() error {
+		var lastErr error
+		for _, fn := range fnList {
+			_, err := fn()
+			if err != nil {
+				lastErr = err
+			}
+		}
+		return lastErr
+	}
+}
+
+// LowerSession ...
+func (i *Item[T, P, A]) LowerSession() memproxy.Session {
+	return i.sess.GetLower()
+}
+
+// Reset clear in-memory cached values
+func (i *Item[T, P, A]) Reset() {
+	i.mapping.Reset()
+	i.refillMapping.Reset()
+}
+
+// Stats ...
+type Stats struct {
+	StaleCount uint64 // number of mappings found pointing to values not having the alternate keys
+}
+
+// GetStats ...
+func (i *Item[T, P, A]) GetStats() Stats {
+	return i.stats
+}
+
+// GetMappingStats returns the stats of the item storing alternate key => primary key mappings
+func (i *Item[T, P, A]) GetMappingStats() item.Stats {
+	return i.mapping.GetStats()
+}
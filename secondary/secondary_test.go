@@ -0,0 +1,241 @@
+package secondary
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/QuangTung97/memproxy"
+	"github.com/QuangTung97/memproxy/fake"
+	"github.com/QuangTung97/memproxy/item"
+)
+
+type userValue struct {
+	ID       int64  `json:"id"`
+	Email    string `json:"email"`
+	Username string `json:"username"`
+}
+
+func (u userValue) Marshal() ([]byte, error) {
+	return json.Marshal(u)
+}
+
+func unmarshalUser(data []byte) (userValue, error) {
+	var u userValue
+	err := json.Unmarshal(data, &u)
+	return u, err
+}
+
+type userKey struct {
+	ID int64
+}
+
+func (k userKey) String() string {
+	return fmt.Sprintf("users:%d", k.ID)
+}
+
+func (k userKey) Marshal() ([]byte, error) {
+	return []byte(strconv.FormatInt(k.ID, 10)), nil
+}
+
+func unmarshalUserKey(data []byte) (userKey, error) {
+	id, err := strconv.ParseInt(string(data), 10, 64)
+	return userKey{ID: id}, err
+}
+
+type userAltKey struct {
+	Email    string
+	Username string
+}
+
+func (k userAltKey) String() string {
+	if k.Email != "" {
+		return "users:email:" + k.Email
+	}
+	return "users:username:" + k.Username
+}
+
+func getUserAltKeys(u userValue) []userAltKey {
+	return []userAltKey{
+		{Email: u.Email},
+		{Username: u.Username},
+	}
+}
+
+type secondaryTest struct {
+	mc *fake.Memcache
+
+	users map[int64]userValue
+
+	primaryFills [][]userKey
+	mappingFills [][]userAltKey
+}
+
+func newSecondaryTest() *secondaryTest {
+	return &secondaryTest{
+		mc:    fake.New(),
+		users: map[int64]userValue{},
+	}
+}
+
+func (h *secondaryTest) addUser(u userValue) {
+	h.users[u.ID] = u
+}
+
+func (h *secondaryTest) newItem(pipe memproxy.Pipeline) *Item[userValue, userKey, userAltKey] {
+	primary := item.New[userValue, userKey](
+		pipe, unmarshalUser,
+		item.NewMultiGetFiller[userValue, userKey](
+			func(ctx context.Context, keys []userKey) ([]userValue, error) {
+				h.primaryFills = append(h.primaryFills, keys)
+				var result []userValue
+				for _, k := range keys {
+					u, ok := h.users[k.ID]
+					if ok {
+						result = append(result, u)
+					}
+				}
+				return result, nil
+			},
+			func(v userValue) userKey {
+				return userKey{ID: v.ID}
+			},
+		),
+	)
+
+	mappingFiller := func(ctx context.Context, key userAltKey) func() (userKey, error) {
+		h.mappingFills = append(h.mappingFills, []userAltKey{key})
+		return func() (userKey, error) {
+			for _, u := range h.users {
+				if (key.Email != "" && u.Email == key.Email) || (key.Username != "" && u.Username == key.Username) {
+					return userKey{ID: u.ID}, nil
+				}
+			}
+			return userKey{}, nil
+		}
+	}
+
+	return New[userValue, userKey, userAltKey](
+		pipe, primary, unmarshalUserKey, mappingFiller, getUserAltKeys,
+	)
+}
+
+func (h *secondaryTest) getUsers(keys ...userAltKey) []Option[userValue] {
+	pipe := h.mc.Pipeline(context.Background())
+	defer pipe.Finish()
+
+	result, err := h.newItem(pipe).GetMulti(context.Background(), keys)()
+	if err != nil {
+		panic(err)
+	}
+	return result
+}
+
+func TestItem(t *testing.T) {
+	t.Run("get-by-email-and-username", func(t *testing.T) {
+		h := newSecondaryTest()
+
+		u1 := userValue{ID: 11, Email: "user01@mail.com", Username: "user01"}
+		u2 := userValue{ID: 12, Email: "user02@mail.com", Username: "user02"}
+		h.addUser(u1)
+		h.addUser(u2)
+
+		result := h.getUsers(
+			userAltKey{Email: "user01@mail.com"},
+			userAltKey{Username: "user02"},
+			userAltKey{Email: "user03@mail.com"},
+		)
+		assert.Equal(t, []Option[userValue]{
+			{Valid: true, Data: u1},
+			{Valid: true, Data: u2},
+			{},
+		}, result)
+
+		assert.Equal(t, 3, len(h.mappingFills))
+		assert.Equal(t, [][]userKey{
+			{{ID: 11}, {ID: 12}},
+		}, h.primaryFills)
+
+		// Get Again From Cache
+		result = h.getUsers(
+			userAltKey{Email: "user01@mail.com"},
+			userAltKey{Username: "user02"},
+			userAltKey{Email: "user03@mail.com"},
+		)
+		assert.Equal(t, []Option[userValue]{
+			{Valid: true, Data: u1},
+			{Valid: true, Data: u2},
+			{},
+		}, result)
+
+		assert.Equal(t, 3, len(h.mappingFills))
+		assert.Equal(t, 1, len(h.primaryFills))
+	})
+
+	t.Run("invalidate-after-email-changed", func(t *testing.T) {
+		h := newSecondaryTest()
+
+		u1 := userValue{ID: 11, Email: "user01@mail.com", Username: "user01"}
+		h.addUser(u1)
+
+		h.getUsers(userAltKey{Email: "user01@mail.com"}, userAltKey{Email: "new01@mail.com"})
+
+		newUser := u1
+		newUser.Email = "new01@mail.com"
+		h.addUser(newUser)
+
+		pipe := h.mc.Pipeline(context.Background())
+		err := h.newItem(pipe).Invalidate(userKey{ID: 11}, u1, newUser)()
+		pipe.Finish()
+		assert.Equal(t, nil, err)
+
+		result := h.getUsers(userAltKey{Email: "user01@mail.com"}, userAltKey{Email: "new01@mail.com"})
+		assert.Equal(t, []Option[userValue]{
+			{},
+			{Valid: true, Data: newUser},
+		}, result)
+	})
+
+	t.Run("outdated-mapping--do-refill", func(t *testing.T) {
+		h := newSecondaryTest()
+
+		u1 := userValue{ID: 11, Email: "user01@mail.com", Username: "user01"}
+		h.addUser(u1)
+
+		h.getUsers(userAltKey{Email: "user01@mail.com"})
+
+		// email moved to another user, only primary keys are invalidated
+		u1.Email = "new01@mail.com"
+		h.addUser(u1)
+		u2 := userValue{ID: 12, Email: "user01@mail.com", Username: "user02"}
+		h.addUser(u2)
+
+		pipe := h.mc.Pipeline(context.Background())
+		it := h.newItem(pipe)
+		err := it.Invalidate(userKey{ID: 11})()
+		assert.Equal(t, nil, err)
+
+		result, err := it.Get(context.Background(), userAltKey{Email: "user01@mail.com"})()
+		pipe.Finish()
+
+		assert.Equal(t, nil, err)
+		assert.Equal(t, Option[userValue]{Valid: true, Data: u2}, result)
+		assert.Equal(t, Stats{StaleCount: 1}, it.GetStats())
+
+		assert.Equal(t, [][]userKey{
+			{{ID: 11}},
+			{{ID: 11}},
+			{{ID: 12}},
+		}, h.primaryFills)
+
+		// Get Again From Cache
+		assert.Equal(t, []Option[userValue]{
+			{Valid: true, Data: u2},
+		}, h.getUsers(userAltKey{Email: "user01@mail.com"}))
+		assert.Equal(t, 3, len(h.primaryFills))
+	})
+}
@@ -0,0 +1,94 @@
+package tagging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+
+	"github.com/QuangTung97/memproxy/item"
+)
+
+// TagVersion ...
+type TagVersion struct {
+	Tag     string
+	Version uint64
+}
+
+// Entry is the value stored in the memcached server, contains the value and the versions of its tags
+type Entry[T item.Value] struct {
+	Tags  []TagVersion
+	Value T
+}
+
+func putLength(buf *bytes.Buffer, length int) {
+	var lenBytes [binary.MaxVarintLen64]byte
+
+	n := binary.PutUvarint(lenBytes[:], uint64(length))
+	_, _ = buf.Write(lenBytes[:n])
+}
+
+// Marshal ...
+func (e Entry[T]) Marshal() ([]byte, error) {
+	data, err := e.Value.Marshal()
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+
+	putLength(&buf, len(e.Tags))
+	for _, t := range e.Tags {
+		putLength(&buf, len(t.Tag))
+		_, _ = buf.WriteString(t.Tag)
+
+		var version [8]byte
+		binary.BigEndian.PutUint64(version[:], t.Version)
+		_, _ = buf.Write(version[:])
+	}
+
+	_, _ = buf.Write(data)
+
+	return buf.Bytes(), nil
+}
+
+// NewEntryUnmarshaler ...
+func NewEntryUnmarshaler[T item.Value](
+	unmarshaler item.Unmarshaler[T],
+) func(data []byte) (Entry[T], error) {
+	return func(data []byte) (Entry[T], error) {
+		numTags, n := binary.Uvarint(data)
+		if n <= 0 {
+			return Entry[T]{}, errors.New("tagging entry: invalid number of tags")
+		}
+		data = data[n:]
+
+		var tags []TagVersion
+		for i := uint64(0); i < numTags; i++ {
+			tagLen, n := binary.Uvarint(data)
+			if n <= 0 {
+				return Entry[T]{}, errors.New("tagging entry: invalid length of tag")
+			}
+			data = data[n:]
+
+			if len(data) < 8 || tagLen > uint64(len(data)-8) {
+				return Entry[T]{}, errors.New("tagging entry: invalid tag data")
+			}
+
+			tags = append(tags, TagVersion{
+				Tag:     string(data[:tagLen]),
+				Version: binary.BigEndian.Uint64(data[tagLen : tagLen+8]),
+			})
+			data = data[tagLen+8:]
+		}
+
+		value, err := unmarshaler(data)
+		if err != nil {
+			return Entry[T]{}, err
+		}
+
+		return Entry[T]{
+			Tags:  tags,
+			Value: value,
+		}, nil
+	}
+}
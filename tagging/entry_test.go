@@ -0,0 +1,82 @@
+package tagging
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEntry_Marshal(t *testing.T) {
+	t.Run("normal", func(t *testing.T) {
+		e := Entry[productValue]{
+			Tags: []TagVersion{
+				{Tag: "product:11", Version: 123},
+				{Tag: "category:3", Version: 456},
+			},
+			Value: productValue{Slug: "sku-01", ID: 11, Name: "Product 01"},
+		}
+
+		data, err := e.Marshal()
+		assert.Equal(t, nil, err)
+
+		result, err := NewEntryUnmarshaler(unmarshalProduct)(data)
+		assert.Equal(t, nil, err)
+		assert.Equal(t, e, result)
+	})
+
+	t.Run("empty-tags", func(t *testing.T) {
+		e := Entry[productValue]{
+			Value: productValue{Slug: "sku-01", ID: 11},
+		}
+
+		data, err := e.Marshal()
+		assert.Equal(t, nil, err)
+
+		result, err := NewEntryUnmarshaler(unmarshalProduct)(data)
+		assert.Equal(t, nil, err)
+		assert.Equal(t, e, result)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		unmarshaler := NewEntryUnmarshaler(unmarshalProduct)
+
+		_, err := unmarshaler(nil)
+		assert.Equal(t, errors.New("tagging entry: invalid number of tags"), err)
+
+		_, err = unmarshaler([]byte{1})
+		assert.Equal(t, errors.New("tagging entry: invalid length of tag"), err)
+
+		_, err = unmarshaler([]byte{1, 3, 'a', 'b', 'c', 0, 0})
+		assert.Equal(t, errors.New("tagging entry: invalid tag data"), err)
+	})
+
+	t.Run("invalid--huge-tag-length", func(t *testing.T) {
+		unmarshaler := NewEntryUnmarshaler(unmarshalProduct)
+
+		garbage := []byte("garbage-data")
+		for _, tagLen := range []uint64{math.MaxUint64, math.MaxUint64 - 7, math.MaxInt64} {
+			data := binary.AppendUvarint(binary.AppendUvarint(nil, 1), tagLen)
+			data = append(data, garbage...)
+
+			_, err := unmarshaler(data)
+			assert.Equal(t, errors.New("tagging entry: invalid tag data"), err)
+		}
+	})
+
+	t.Run("invalid--truncated", func(t *testing.T) {
+		data, err := Entry[productValue]{
+			Tags:  []TagVersion{{Tag: "product:11", Version: 123}},
+			Value: productValue{Slug: "sku-01", ID: 11},
+		}.Marshal()
+		assert.Equal(t, nil, err)
+
+		unmarshaler := NewEntryUnmarshaler(unmarshalProduct)
+		for _, n := range []int{2, 10, 15} {
+			_, err = unmarshaler(data[:n])
+			assert.Equal(t, errors.New("tagging entry: invalid tag data"), err)
+		}
+	})
+}
@@ -0,0 +1,421 @@
+package tagging
+
+import (
+	"context"
+	"encoding/binary"
+	"time"
+
+	"github.com/QuangTung97/go-memcache/memcache"
+
+	"github.com/QuangTung97/memproxy"
+	"github.com/QuangTung97/memproxy/item"
+)
+
+// TagKeyPrefix is the prefix of the memcached keys storing the versions of tags
+const TagKeyPrefix = "tag:"
+
+// TagKey returns the memcached key storing the version of the tag
+func TagKey(tag string) string {
+	return TagKeyPrefix + tag
+}
+
+// Filler is similar to item.Filler, but also returns the list of tags of the value
+type Filler[T any, K any] func(ctx context.Context, key K) func() (T, []string, error)
+
+// NewFiller creates a Filler from an item.Filler (e.g. from item.NewMultiGetFiller)
+// and a function computing the tags of a value
+func NewFiller[T any, K any](
+	filler item.Filler[T, K],
+	getTags func(v T) []string,
+) Filler[T, K] {
+	return func(ctx context.Context, key K) func() (T, []string, error) {
+		fn := filler(ctx, key)
+		return func() (T, []string, error) {
+			v, err := fn()
+			if err != nil {
+				return v, nil, err
+			}
+			return v, getTags(v), nil
+		}
+	}
+}
+
+type itemConfig struct {
+	itemOptions []item.Option
+	newVersion  func() uint64
+}
+
+// Option ...
+type Option func(conf *itemConfig)
+
+// WithItemOptions ...
+func WithItemOptions(options ...item.Option) Option {
+	return func(conf *itemConfig) {
+		conf.itemOptions = options
+	}
+}
+
+// WithVersionFunc configures the function generating new versions for tags and fills,
+// MUST be increasing over time and MUST NOT return zero.
+// Default is using the unix nanoseconds of the current time,
+// so the clocks of the clients should be synchronized
+func WithVersionFunc(fn func() uint64) Option {
+	return func(conf *itemConfig) {
+		conf.newVersion = fn
+	}
+}
+
+func defaultNewVersion() uint64 {
+	return uint64(time.Now().UnixNano())
+}
+
+func computeItemConfig(options []Option) *itemConfig {
+	conf := &itemConfig{
+		itemOptions: nil,
+		newVersion:  defaultNewVersion,
+	}
+	for _, fn := range options {
+		fn(conf)
+	}
+	return conf
+}
+
+// Item is similar to item.Item, but values are stored together with versions of their tags.
+// A cached value is considered stale when one of its tags has been invalidated after the value was stored.
+// Item is NOT thread safe
+type Item[T item.Value, K item.Key] struct {
+	conf *itemConfig
+
+	sess     memproxy.Session
+	pipeline memproxy.Pipeline
+
+	item       *item.Item[Entry[T], K]
+	refillItem *item.Item[Entry[T], K]
+
+	stats Stats
+}
+
+// New creates a tagging.Item, params are similar to item.New.
+//
+// Similar to a lease, a fill version is generated before the fillers are called,
+// and the versions of the tags are read after the fillers returned.
+// If the version of any tag is not available at that time (e.g. tag has just been invalidated),
+// or is newer than the fill version (tag was invalidated while filling),
+// the value is still returned, but not stored in the memcached server.
+func New[T item.Value, K item.Key](
+	pipeline memproxy.Pipeline,
+	unmarshaler item.Unmarshaler[T],
+	filler Filler[T, K],
+	options ...Option,
+) *Item[T, K] {
+	conf := computeItemConfig(options)
+
+	i := &Item[T, K]{
+		conf:     conf,
+		pipeline: pipeline,
+	}
+
+	entryUnmarshaler := NewEntryUnmarshaler(unmarshaler)
+
+	i.item = item.New[Entry[T], K](pipeline, entryUnmarshaler, i.newEntryFiller(filler), conf.itemOptions...)
+	i.refillItem = item.New[Entry[T], K](pipeline, entryUnmarshaler, i.newEntryFiller(filler), conf.itemOptions...)
+	i.sess = i.item.LowerSession()
+
+	return i
+}
+
+// Get a single item with key
+func (i *Item[T, K]) Get(ctx context.Context, key K) func() (T, error) {
+	state := i.item.GetFast(ctx, key)
+
+	var result T
+	var err error
+
+	i.sess.AddNextCall(memproxy.NewEmptyCallback(func() {
+		entry, getErr := state.Result()
+		if getErr != nil {
+			err = getErr
+			return
+		}
+
+		if len(entry.Tags) == 0 {
+			result = entry.Value
+			return
+		}
+
+		versionsFn := i.getTagVersions(entryTagNames(entry.Tags))
+
+		i.sess.AddNextCall(memproxy.NewEmptyCallback(func() {
+			if isEntryUpToDate(entry, versionsFn()) {
+				result = entry.Value
+				return
+			}
+
+			i.stats.StaleCount++
+
+			i.pipeline.Delete(key.String(), memproxy.DeleteOptions{})
+			refillFn := i.refillItem.Get(ctx, key)
+
+			i.sess.AddNextCall(memproxy.NewEmptyCallback(func() {
+				entry, err = refillFn()
+				result = entry.Value
+			}))
+		}))
+	}))
+
+	return func() (T, error) {
+		i.sess.Execute()
+		return result, err
+	}
+}
+
+// GetMulti gets multiple keys at once
+func (i *Item[T, K]) GetMulti(ctx context.Context, keys []K) func() ([]T, error) {
+	fnList := make([]func() (T, error), 0, len(keys))
+	for _, k := range keys {
+		fnList = append(fnList, i.Get(ctx, k))
+	}
+
+	return func() ([]T, error) {
+		result := make([]T, 0, len(fnList))
+		for _, fn := range fnList {
+			val, err := fn()
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, val)
+		}
+		return result, nil
+	}
+}
+
+// LowerSession ...
+func (i *Item[T, K]) LowerSession() memproxy.Session {
+	return i.sess.GetLower()
+}
+
+// Reset clear in-memory cached values
+func (i *Item[T, K]) Reset() {
+	i.item.Reset()
+	i.refillItem.Reset()
+}
+
+// Stats ...
+type Stats struct {
+	StaleCount     uint64 // number of cached values found with outdated tag versions
+	NotStoredCount uint64 // number of filled values not stored because tag versions were not available or too new
+	TagErrorCount  uint64 // number of errors when getting tag versions
+}
+
+// GetStats ...
+func (i *Item[T, K]) GetStats() Stats {
+	return i.stats
+}
+
+// GetItemStats returns the underlining item stats
+func (i *Item[T, K]) GetItemStats() item.Stats {
+	return i.item.GetStats()
+}
+
+// Invalidate deletes the versions of the tags,
+// all cached values having any of these tags will be considered stale
+func Invalidate(pipeline memproxy.Pipeline, tags ...string) func() error {
+	fnList := make([]func() (memproxy.DeleteResponse, error), 0, len(tags))
+	for _, tag := range tags {
+		fnList = append(fnList, pipeline.Delete(TagKey(tag), memproxy.DeleteOptions{}))
+	}
+
+	return func() error {
+		var lastErr error
+		for _, fn := range fnList {
+			_, err := fn()
+			if err != nil {
+				lastErr = err
+			}
+		}
+		return lastErr
+	}
+}
+
+type fillResult[T item.Value] struct {
+	entry Entry[T]
+	tags  []string
+	err   error
+}
+
+type fillState[T item.Value] struct {
+	// generated before calling the fillers, tags having newer versions were invalidated while filling
+	fillVersion uint64
+
+	completed bool
+	fillFuncs []func() (T, []string, error)
+	results   []fillResult[T]
+}
+
+// newEntryFiller batching the tag version gets of all the fills, similar to item.NewMultiGetFiller
+func (i *Item[T, K]) newEntryFiller(filler Filler[T, K]) item.Filler[Entry[T], K] {
+	var state *fillState[T]
+
+	return func(ctx context.Context, key K) func() (Entry[T], error) {
+		if state == nil {
+			state = &fillState[T]{
+				fillVersion: i.conf.newVersion(),
+			}
+		}
+		s := state
+
+		index := len(s.fillFuncs)
+		s.fillFuncs = append(s.fillFuncs, filler(ctx, key))
+
+		return func() (Entry[T], error) {
+			if !s.completed {
+				s.completed = true
+				state = nil
+
+				i.completeFill(s)
+			}
+
+			r := s.results[index]
+			return r.entry, r.err
+		}
+	}
+}
+
+func (i *Item[T, K]) completeFill(s *fillState[T]) {
+	s.results = make([]fillResult[T], 0, len(s.fillFuncs))
+
+	var tags []string
+	for _, fn := range s.fillFuncs {
+		value, valueTags, err := fn()
+		s.results = append(s.results, fillResult[T]{
+			entry: Entry[T]{Value: value},
+			tags:  valueTags,
+			err:   err,
+		})
+		if err == nil {
+			tags = append(tags, valueTags...)
+		}
+	}
+
+	if len(tags) == 0 {
+		return
+	}
+
+	versions := i.getTagVersions(tags)()
+
+	for index := range s.results {
+		r := &s.results[index]
+		if r.err != nil {
+			continue
+		}
+
+		for _, tag := range r.tags {
+			v := versions[tag]
+			if !v.found || v.version > s.fillVersion {
+				// still returns the value, but deletes the lease instead of setting
+				r.err = item.ErrNotFound
+				i.stats.NotStoredCount++
+				break
+			}
+			r.entry.Tags = append(r.entry.Tags, TagVersion{
+				Tag:     tag,
+				Version: v.version,
+			})
+		}
+	}
+}
+
+type tagVersionStatus struct {
+	version uint64
+	found   bool // false when the version has just been created, or can not be read
+}
+
+type tagLeaseGet struct {
+	tag string
+	fn  memproxy.LeaseGetResult
+}
+
+func (i *Item[T, K]) getTagVersions(tags []string) func() map[string]tagVersionStatus {
+	existed := map[string]struct{}{}
+	getList := make([]tagLeaseGet, 0, len(tags))
+
+	for _, tag := range tags {
+		if _, ok := existed[tag]; ok {
+			continue
+		}
+		existed[tag] = struct{}{}
+
+		getList = append(getList, tagLeaseGet{
+			tag: tag,
+			fn:  i.pipeline.LeaseGet(TagKey(tag), memproxy.LeaseGetOptions{}),
+		})
+	}
+
+	return func() map[string]tagVersionStatus {
+		result := make(map[string]tagVersionStatus, len(getList))
+		needExecute := false
+
+		for _, get := range getList {
+			resp, err := get.fn.Result()
+			if err != nil {
+				i.stats.TagErrorCount++
+				result[get.tag] = tagVersionStatus{}
+				continue
+			}
+
+			switch resp.Status {
+			case memproxy.LeaseGetStatusFound:
+				result[get.tag] = decodeTagVersion(resp.Data)
+				memcache.ReleaseGetResponseData(resp.Data)
+
+			case memproxy.LeaseGetStatusLeaseGranted:
+				version := i.conf.newVersion()
+				i.pipeline.LeaseSet(TagKey(get.tag), encodeTagVersion(version), resp.CAS, memproxy.LeaseSetOptions{})
+				needExecute = true
+				result[get.tag] = tagVersionStatus{version: version}
+
+			default:
+				result[get.tag] = tagVersionStatus{}
+			}
+		}
+
+		if needExecute {
+			i.sess.AddNextCall(memproxy.NewEmptyCallback(i.pipeline.Execute))
+		}
+		return result
+	}
+}
+
+func entryTagNames(tags []TagVersion) []string {
+	result := make([]string, 0, len(tags))
+	for _, t := range tags {
+		result = append(result, t.Tag)
+	}
+	return result
+}
+
+func isEntryUpToDate[T item.Value](entry Entry[T], versions map[string]tagVersionStatus) bool {
+	for _, t := range entry.Tags {
+		v := versions[t.Tag]
+		if !v.found || v.version != t.Version {
+			return false
+		}
+	}
+	return true
+}
+
+func encodeTagVersion(version uint64) []byte {
+	var data [8]byte
+	binary.BigEndian.PutUint64(data[:], version)
+	return data[:]
+}
+
+func decodeTagVersion(data []byte) tagVersionStatus {
+	if len(data) != 8 {
+		return tagVersionStatus{}
+	}
+	return tagVersionStatus{
+		version: binary.BigEndian.Uint64(data),
+		found:   true,
+	}
+}
@@ -0,0 +1,266 @@
+package tagging
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/QuangTung97/memproxy"
+	"github.com/QuangTung97/memproxy/fake"
+	"github.com/QuangTung97/memproxy/item"
+)
+
+type productValue struct {
+	Slug    string `json:"slug"`
+	ID      int64  `json:"id"`
+	Name    string `json:"name"`
+	Version int64  `json:"version"`
+}
+
+func (p productValue) Marshal() ([]byte, error) {
+	return json.Marshal(p)
+}
+
+func unmarshalProduct(data []byte) (productValue, error) {
+	var p productValue
+	err := json.Unmarshal(data, &p)
+	return p, err
+}
+
+type productSlugKey struct {
+	slug string
+}
+
+func (k productSlugKey) String() string {
+	return "products:slug:" + k.slug
+}
+
+func productTag(id int64) string {
+	return fmt.Sprintf("product:%d", id)
+}
+
+type taggingTest struct {
+	mc *fake.Memcache
+
+	products map[string]productValue
+
+	fillKeys  [][]productSlugKey
+	versionNo uint64
+
+	onFill func()
+}
+
+func newTaggingTest() *taggingTest {
+	return &taggingTest{
+		mc:       fake.New(),
+		products: map[string]productValue{},
+	}
+}
+
+func (h *taggingTest) newItem(pipe memproxy.Pipeline) *Item[productValue, productSlugKey] {
+	filler := item.NewMultiGetFiller[productValue, productSlugKey](
+		func(ctx context.Context, keys []productSlugKey) ([]productValue, error) {
+			h.fillKeys = append(h.fillKeys, keys)
+			if h.onFill != nil {
+				h.onFill()
+			}
+
+			var result []productValue
+			for _, k := range keys {
+				p, ok := h.products[k.slug]
+				if ok {
+					result = append(result, p)
+				}
+			}
+			return result, nil
+		},
+		func(v productValue) productSlugKey {
+			return productSlugKey{slug: v.Slug}
+		},
+	)
+
+	return New[productValue, productSlugKey](
+		pipe, unmarshalProduct,
+		NewFiller[productValue, productSlugKey](filler, func(v productValue) []string {
+			return []string{productTag(v.ID)}
+		}),
+		WithVersionFunc(func() uint64 {
+			h.versionNo++
+			return h.versionNo
+		}),
+	)
+}
+
+func (h *taggingTest) getProducts(slugs ...string) []productValue {
+	pipe := h.mc.Pipeline(context.Background())
+	defer pipe.Finish()
+
+	it := h.newItem(pipe)
+
+	keys := make([]productSlugKey, 0, len(slugs))
+	for _, s := range slugs {
+		keys = append(keys, productSlugKey{slug: s})
+	}
+
+	result, err := it.GetMulti(context.Background(), keys)()
+	if err != nil {
+		panic(err)
+	}
+	return result
+}
+
+func (h *taggingTest) invalidate(tags ...string) {
+	pipe := h.mc.Pipeline(context.Background())
+	defer pipe.Finish()
+
+	err := Invalidate(pipe, tags...)()
+	if err != nil {
+		panic(err)
+	}
+}
+
+// recreateTag invalidates the tag and creates a new version, similar to another client reading the tag
+func (h *taggingTest) recreateTag(tag string) {
+	h.invalidate(tag)
+
+	pipe := h.mc.Pipeline(context.Background())
+	defer pipe.Finish()
+
+	resp, err := pipe.LeaseGet(TagKey(tag), memproxy.LeaseGetOptions{}).Result()
+	if err != nil {
+		panic(err)
+	}
+
+	h.versionNo++
+	pipe.LeaseSet(TagKey(tag), encodeTagVersion(h.versionNo), resp.CAS, memproxy.LeaseSetOptions{})
+}
+
+func TestItem(t *testing.T) {
+	t.Run("first-fill-not-stored--second-fill-stored--then-hit", func(t *testing.T) {
+		h := newTaggingTest()
+
+		p1 := productValue{Slug: "sku-01", ID: 11, Name: "Product 01"}
+		p2 := productValue{Slug: "sku-02", ID: 12, Name: "Product 02"}
+		h.products[p1.Slug] = p1
+		h.products[p2.Slug] = p2
+
+		assert.Equal(t, []productValue{p1, p2}, h.getProducts("sku-01", "sku-02"))
+		assert.Equal(t, [][]productSlugKey{
+			{{slug: "sku-01"}, {slug: "sku-02"}},
+		}, h.fillKeys)
+
+		assert.Equal(t, []productValue{p1, p2}, h.getProducts("sku-01", "sku-02"))
+		assert.Equal(t, 2, len(h.fillKeys))
+
+		assert.Equal(t, []productValue{p1, p2}, h.getProducts("sku-01", "sku-02"))
+		assert.Equal(t, 2, len(h.fillKeys))
+	})
+
+	t.Run("invalidate-tag--do-refill", func(t *testing.T) {
+		h := newTaggingTest()
+
+		p1 := productValue{Slug: "sku-01", ID: 11, Name: "Product 01"}
+		p2 := productValue{Slug: "sku-02", ID: 12, Name: "Product 02"}
+		h.products[p1.Slug] = p1
+		h.products[p2.Slug] = p2
+
+		h.getProducts("sku-01", "sku-02")
+		h.getProducts("sku-01", "sku-02")
+		assert.Equal(t, 2, len(h.fillKeys))
+
+		p1.Name = "Product 01 New"
+		p1.Version = 1
+		h.products[p1.Slug] = p1
+		h.invalidate(productTag(p1.ID))
+
+		pipe := h.mc.Pipeline(context.Background())
+		it := h.newItem(pipe)
+
+		result, err := it.GetMulti(context.Background(), []productSlugKey{
+			{slug: "sku-01"}, {slug: "sku-02"},
+		})()
+		pipe.Finish()
+
+		assert.Equal(t, nil, err)
+		assert.Equal(t, []productValue{p1, p2}, result)
+		assert.Equal(t, [][]productSlugKey{{{slug: "sku-01"}}}, h.fillKeys[2:])
+
+		// new tag version is created before refilling => stored
+		assert.Equal(t, Stats{
+			StaleCount: 1,
+		}, it.GetStats())
+
+		// hit
+		assert.Equal(t, []productValue{p1, p2}, h.getProducts("sku-01", "sku-02"))
+		assert.Equal(t, 3, len(h.fillKeys))
+	})
+
+	t.Run("tag-invalidated-while-filling--not-stored", func(t *testing.T) {
+		h := newTaggingTest()
+
+		p1 := productValue{Slug: "sku-01", ID: 11, Name: "Product 01"}
+		h.products[p1.Slug] = p1
+
+		h.getProducts("sku-01")
+		h.invalidate(productTag(p1.ID))
+
+		pipe := h.mc.Pipeline(context.Background())
+		it := h.newItem(pipe)
+
+		h.onFill = func() {
+			h.recreateTag(productTag(p1.ID))
+		}
+
+		result, err := it.Get(context.Background(), productSlugKey{slug: "sku-01"})()
+		pipe.Finish()
+
+		assert.Equal(t, nil, err)
+		assert.Equal(t, p1, result)
+		assert.Equal(t, Stats{NotStoredCount: 1}, it.GetStats())
+
+		// filled again
+		h.onFill = nil
+		h.getProducts("sku-01")
+		assert.Equal(t, 3, len(h.fillKeys))
+
+		// hit
+		h.getProducts("sku-01")
+		assert.Equal(t, 3, len(h.fillKeys))
+	})
+
+	t.Run("not-found", func(t *testing.T) {
+		h := newTaggingTest()
+
+		assert.Equal(t, []productValue{{}}, h.getProducts("sku-01"))
+		assert.Equal(t, []productValue{{}}, h.getProducts("sku-01"))
+		assert.Equal(t, 2, len(h.fillKeys))
+	})
+}
+
+func TestItem_Value_Without_Tags(t *testing.T) {
+	mc := fake.New()
+
+	fillCount := 0
+	filler := func(ctx context.Context, key productSlugKey) func() (productValue, []string, error) {
+		fillCount++
+		return func() (productValue, []string, error) {
+			return productValue{Slug: key.slug, ID: 21}, nil, nil
+		}
+	}
+
+	for n := 0; n < 2; n++ {
+		pipe := mc.Pipeline(context.Background())
+		it := New[productValue, productSlugKey](pipe, unmarshalProduct, filler)
+
+		p, err := it.Get(context.Background(), productSlugKey{slug: "sku-01"})()
+		pipe.Finish()
+
+		assert.Equal(t, nil, err)
+		assert.Equal(t, productValue{Slug: "sku-01", ID: 21}, p)
+	}
+
+	assert.Equal(t, 1, fillCount)
+}
@@ -0,0 +1,220 @@
+package warmer
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/QuangTung97/memproxy"
+	"github.com/QuangTung97/memproxy/item"
+)
+
+// KeySource returns the next keys to be warmed up, at most limit keys.
+// Returns an empty list when there are no more keys
+type KeySource[K any] func(ctx context.Context, limit int) ([]K, error)
+
+// NewSliceSource creates a KeySource from a list of keys (e.g. read from a key list file),
+// the first skip keys are ignored, often is the Progress.Count when resuming.
+// Returns an error if the limit is not positive
+func NewSliceSource[K any](keys []K, skip uint64) KeySource[K] {
+	if skip > uint64(len(keys)) {
+		skip = uint64(len(keys))
+	}
+	keys = keys[skip:]
+
+	return func(ctx context.Context, limit int) ([]K, error) {
+		if limit <= 0 {
+			return nil, errors.New("warmer: limit must be positive")
+		}
+
+		n := limit
+		if n > len(keys) {
+			n = len(keys)
+		}
+		result := keys[:n]
+		keys = keys[n:]
+		return result, nil
+	}
+}
+
+// Progress of warming up
+type Progress[K any] struct {
+	Count      uint64 // number of processed keys, including the keys processed before resuming
+	FillCount  uint64 // number of keys NOT found in the memcached server and filled from the filler
+	ErrorCount uint64 // number of keys failed to warm up
+
+	LastKey K    // the last processed key, for resuming a KeySource based on scanning the database
+	Done    bool // true when the KeySource has no more keys
+}
+
+type warmerConfig struct {
+	batchSize   int
+	rateLimit   float64
+	itemOptions []item.Option
+
+	nowFunc   func() time.Time
+	sleepFunc func(ctx context.Context, d time.Duration) error
+}
+
+// Option ...
+type Option func(conf *warmerConfig)
+
+// WithBatchSize configures the number of keys warmed up in one pipeline, default 100, must be positive
+func WithBatchSize(size int) Option {
+	return func(conf *warmerConfig) {
+		conf.batchSize = size
+	}
+}
+
+// WithRateLimit configures the maximum number of keys warmed up per second,
+// the warmer sleeps between batches to keep the rate, default zero (no limit)
+func WithRateLimit(keysPerSecond float64) Option {
+	return func(conf *warmerConfig) {
+		conf.rateLimit = keysPerSecond
+	}
+}
+
+// WithItemOptions configures the options of the item.Item used for filling
+func WithItemOptions(options ...item.Option) Option {
+	return func(conf *warmerConfig) {
+		conf.itemOptions = options
+	}
+}
+
+func defaultSleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func computeWarmerConfig(options []Option) *warmerConfig {
+	conf := &warmerConfig{
+		batchSize: 100,
+		rateLimit: 0,
+
+		nowFunc:   time.Now,
+		sleepFunc: defaultSleep,
+	}
+	for _, fn := range options {
+		fn(conf)
+	}
+	return conf
+}
+
+// Warmer fills the keys from a KeySource to a memcached server (or a group of servers) in paced batches.
+// Keys already existed in the memcached server are NOT filled again, because item.Item uses lease gets.
+//
+// For warming up a specific server of a proxy.Memcache (e.g. a restarted replica),
+// use the client returned from proxy.Memcache.ServerMemcache
+type Warmer[T item.Value, K item.Key] struct {
+	conf *warmerConfig
+
+	client      memproxy.Memcache
+	unmarshaler item.Unmarshaler[T]
+	filler      item.Filler[T, K]
+}
+
+// New creates a Warmer, params are similar to item.New,
+// filler should be created from item.NewMultiGetFiller for batching gets to the backing store
+func New[T item.Value, K item.Key](
+	client memproxy.Memcache,
+	unmarshaler item.Unmarshaler[T],
+	filler item.Filler[T, K],
+	options ...Option,
+) (*Warmer[T, K], error) {
+	conf := computeWarmerConfig(options)
+	if conf.batchSize <= 0 {
+		return nil, errors.New("warmer: batch size must be positive")
+	}
+
+	return &Warmer[T, K]{
+		conf: conf,
+
+		client:      client,
+		unmarshaler: unmarshaler,
+		filler:      filler,
+	}, nil
+}
+
+// Run warms up all the keys of the source, until there are no more keys, or error or the context is cancelled.
+// Param: progress is the progress returned from a previous Run for resuming, or the zero value.
+// Param: callback is called after every batch for reporting, can be nil.
+// Returns the progress at the time of stopping, errors of individual keys only increase Progress.ErrorCount
+func (w *Warmer[T, K]) Run(
+	ctx context.Context,
+	source KeySource[K],
+	progress Progress[K],
+	callback func(p Progress[K]),
+) (Progress[K], error) {
+	for {
+		if err := ctx.Err(); err != nil {
+			return progress, err
+		}
+
+		keys, err := source(ctx, w.conf.batchSize)
+		if err != nil {
+			return progress, err
+		}
+
+		if len(keys) == 0 {
+			progress.Done = true
+			if callback != nil {
+				callback(progress)
+			}
+			return progress, nil
+		}
+
+		startTime := w.conf.nowFunc()
+
+		w.warmBatch(ctx, keys, &progress)
+		if callback != nil {
+			callback(progress)
+		}
+
+		if err := w.pace(ctx, len(keys), startTime); err != nil {
+			return progress, err
+		}
+	}
+}
+
+func (w *Warmer[T, K]) warmBatch(ctx context.Context, keys []K, progress *Progress[K]) {
+	pipe := w.client.Pipeline(ctx)
+	defer pipe.Finish()
+
+	it := item.New[T, K](pipe, w.unmarshaler, w.filler, w.conf.itemOptions...)
+
+	fnList := make([]func() (T, error), 0, len(keys))
+	for _, k := range keys {
+		fnList = append(fnList, it.Get(ctx, k))
+	}
+
+	for _, fn := range fnList {
+		_, err := fn()
+		if err != nil {
+			progress.ErrorCount++
+		}
+	}
+
+	progress.Count += uint64(len(keys))
+	progress.FillCount += it.GetStats().FillCount
+	progress.LastKey = keys[len(keys)-1]
+}
+
+func (w *Warmer[T, K]) pace(ctx context.Context, numKeys int, startTime time.Time) error {
+	if w.conf.rateLimit <= 0 {
+		return nil
+	}
+
+	expected := time.Duration(float64(numKeys) / w.conf.rateLimit * float64(time.Second))
+	d := expected - w.conf.nowFunc().Sub(startTime)
+	if d <= 0 {
+		return nil
+	}
+	return w.conf.sleepFunc(ctx, d)
+}
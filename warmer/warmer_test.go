@@ -0,0 +1,308 @@
+package warmer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/QuangTung97/memproxy"
+	"github.com/QuangTung97/memproxy/fake"
+	"github.com/QuangTung97/memproxy/item"
+)
+
+type userKey struct {
+	ID int64
+}
+
+func (k userKey) String() string {
+	return "users:" + strconv.FormatInt(k.ID, 10)
+}
+
+type user struct {
+	ID   int64  `json:"id"`
+	Name string `json:"name"`
+}
+
+func (u user) Marshal() ([]byte, error) {
+	return json.Marshal(u)
+}
+
+func (u user) getKey() userKey {
+	return userKey{ID: u.ID}
+}
+
+func unmarshalUser(data []byte) (user, error) {
+	var u user
+	err := json.Unmarshal(data, &u)
+	return u, err
+}
+
+type warmerTest struct {
+	mc *fake.Memcache
+	w  *Warmer[user, userKey]
+
+	users    map[userKey]user
+	fillKeys [][]userKey
+	fillErr  error
+
+	now        time.Time
+	sleepCalls []time.Duration
+}
+
+func newWarmerTest(options ...Option) *warmerTest {
+	wt := &warmerTest{
+		mc:    fake.New(),
+		users: map[userKey]user{},
+		now:   time.Date(2023, 5, 1, 10, 0, 0, 0, time.UTC),
+	}
+
+	filler := item.NewMultiGetFiller[user, userKey](
+		func(ctx context.Context, keys []userKey) ([]user, error) {
+			wt.fillKeys = append(wt.fillKeys, keys)
+			if wt.fillErr != nil {
+				return nil, wt.fillErr
+			}
+
+			var result []user
+			for _, k := range keys {
+				u, ok := wt.users[k]
+				if ok {
+					result = append(result, u)
+				}
+			}
+			return result, nil
+		},
+		user.getKey,
+	)
+
+	options = append([]Option{
+		WithItemOptions(item.WithErrorLogger(func(err error) {})),
+	}, options...)
+
+	w, err := New[user, userKey](wt.mc, unmarshalUser, filler, options...)
+	if err != nil {
+		panic(err)
+	}
+	wt.w = w
+	wt.w.conf.nowFunc = func() time.Time {
+		return wt.now
+	}
+	wt.w.conf.sleepFunc = func(ctx context.Context, d time.Duration) error {
+		wt.sleepCalls = append(wt.sleepCalls, d)
+		return nil
+	}
+	return wt
+}
+
+func (wt *warmerTest) addUsers(n int) []userKey {
+	var keys []userKey
+	for i := 1; i <= n; i++ {
+		u := user{ID: int64(i), Name: "user " + strconv.Itoa(i)}
+		wt.users[u.getKey()] = u
+		keys = append(keys, u.getKey())
+	}
+	return keys
+}
+
+func (wt *warmerTest) getCached(key userKey) []byte {
+	pipe := wt.mc.Pipeline(context.Background())
+	defer pipe.Finish()
+
+	resp, err := pipe.LeaseGet(key.String(), memproxy.LeaseGetOptions{NoLease: true}).Result()
+	if err != nil {
+		panic(err)
+	}
+	return resp.Data
+}
+
+func TestWarmer(t *testing.T) {
+	t.Run("fill-all-keys-in-batches", func(t *testing.T) {
+		wt := newWarmerTest(WithBatchSize(2))
+		keys := wt.addUsers(5)
+
+		var progressList []Progress[userKey]
+		p, err := wt.w.Run(context.Background(), NewSliceSource(keys, 0), Progress[userKey]{},
+			func(p Progress[userKey]) {
+				progressList = append(progressList, p)
+			},
+		)
+		assert.Equal(t, nil, err)
+		assert.Equal(t, Progress[userKey]{
+			Count:     5,
+			FillCount: 5,
+			LastKey:   userKey{ID: 5},
+			Done:      true,
+		}, p)
+
+		assert.Equal(t, [][]userKey{
+			{{ID: 1}, {ID: 2}},
+			{{ID: 3}, {ID: 4}},
+			{{ID: 5}},
+		}, wt.fillKeys)
+
+		assert.Equal(t, 4, len(progressList))
+		assert.Equal(t, Progress[userKey]{
+			Count:     2,
+			FillCount: 2,
+			LastKey:   userKey{ID: 2},
+		}, progressList[0])
+
+		assert.Equal(t, `{"id":3,"name":"user 3"}`, string(wt.getCached(userKey{ID: 3})))
+		assert.Equal(t, 0, len(wt.sleepCalls))
+	})
+
+	t.Run("existing-keys-not-filled", func(t *testing.T) {
+		wt := newWarmerTest(WithBatchSize(2))
+		keys := wt.addUsers(3)
+
+		_, err := wt.w.Run(context.Background(), NewSliceSource(keys[:1], 0), Progress[userKey]{}, nil)
+		assert.Equal(t, nil, err)
+
+		p, err := wt.w.Run(context.Background(), NewSliceSource(keys, 0), Progress[userKey]{}, nil)
+		assert.Equal(t, nil, err)
+		assert.Equal(t, Progress[userKey]{
+			Count:     3,
+			FillCount: 2,
+			LastKey:   userKey{ID: 3},
+			Done:      true,
+		}, p)
+
+		assert.Equal(t, [][]userKey{
+			{{ID: 1}},
+			{{ID: 2}},
+			{{ID: 3}},
+		}, wt.fillKeys)
+	})
+
+	t.Run("resume-from-progress", func(t *testing.T) {
+		wt := newWarmerTest(WithBatchSize(2))
+		keys := wt.addUsers(5)
+
+		prev := Progress[userKey]{
+			Count:     3,
+			FillCount: 3,
+			LastKey:   userKey{ID: 3},
+		}
+
+		p, err := wt.w.Run(context.Background(), NewSliceSource(keys, prev.Count), prev, nil)
+		assert.Equal(t, nil, err)
+		assert.Equal(t, Progress[userKey]{
+			Count:     5,
+			FillCount: 5,
+			LastKey:   userKey{ID: 5},
+			Done:      true,
+		}, p)
+
+		assert.Equal(t, [][]userKey{
+			{{ID: 4}, {ID: 5}},
+		}, wt.fillKeys)
+	})
+
+	t.Run("rate-limit", func(t *testing.T) {
+		wt := newWarmerTest(WithBatchSize(2), WithRateLimit(10))
+		keys := wt.addUsers(4)
+
+		elapsedList := []time.Duration{50 * time.Millisecond, 250 * time.Millisecond, 0}
+		p, err := wt.w.Run(context.Background(), NewSliceSource(keys, 0), Progress[userKey]{},
+			func(p Progress[userKey]) {
+				wt.now = wt.now.Add(elapsedList[0])
+				elapsedList = elapsedList[1:]
+			},
+		)
+		assert.Equal(t, nil, err)
+		assert.Equal(t, uint64(4), p.Count)
+
+		assert.Equal(t, []time.Duration{
+			150 * time.Millisecond,
+		}, wt.sleepCalls)
+	})
+
+	t.Run("fill-error--count-errors", func(t *testing.T) {
+		wt := newWarmerTest(WithBatchSize(2))
+		keys := wt.addUsers(3)
+		wt.fillErr = errors.New("fill error")
+
+		p, err := wt.w.Run(context.Background(), NewSliceSource(keys, 0), Progress[userKey]{}, nil)
+		assert.Equal(t, nil, err)
+		assert.Equal(t, Progress[userKey]{
+			Count:      3,
+			FillCount:  3,
+			ErrorCount: 3,
+			LastKey:    userKey{ID: 3},
+			Done:       true,
+		}, p)
+	})
+
+	t.Run("source-error", func(t *testing.T) {
+		wt := newWarmerTest(WithBatchSize(2))
+		keys := wt.addUsers(3)
+
+		sourceErr := errors.New("source error")
+		source := NewSliceSource(keys, 0)
+		calls := 0
+
+		p, err := wt.w.Run(context.Background(), func(ctx context.Context, limit int) ([]userKey, error) {
+			calls++
+			if calls > 1 {
+				return nil, sourceErr
+			}
+			return source(ctx, limit)
+		}, Progress[userKey]{}, nil)
+		assert.Equal(t, sourceErr, err)
+		assert.Equal(t, Progress[userKey]{
+			Count:     2,
+			FillCount: 2,
+			LastKey:   userKey{ID: 2},
+		}, p)
+	})
+
+	t.Run("context-cancelled", func(t *testing.T) {
+		wt := newWarmerTest(WithBatchSize(2))
+		keys := wt.addUsers(3)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		p, err := wt.w.Run(ctx, NewSliceSource(keys, 0), Progress[userKey]{}, func(p Progress[userKey]) {
+			cancel()
+		})
+		assert.Equal(t, context.Canceled, err)
+		assert.Equal(t, uint64(2), p.Count)
+		assert.Equal(t, false, p.Done)
+	})
+}
+
+func TestNew_Invalid_Batch_Size(t *testing.T) {
+	for _, size := range []int{0, -1} {
+		w, err := New[user, userKey](fake.New(), unmarshalUser, nil, WithBatchSize(size))
+		assert.Nil(t, w)
+		assert.Equal(t, errors.New("warmer: batch size must be positive"), err)
+	}
+}
+
+func TestNewSliceSource(t *testing.T) {
+	source := NewSliceSource([]int{1, 2, 3, 4, 5}, 1)
+
+	keys, err := source(context.Background(), 3)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []int{2, 3, 4}, keys)
+
+	keys, err = source(context.Background(), 3)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []int{5}, keys)
+
+	keys, err = source(context.Background(), 3)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 0, len(keys))
+
+	keys, err = NewSliceSource([]int{1, 2}, 5)(context.Background(), 3)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 0, len(keys))
+
+	keys, err = NewSliceSource([]int{1, 2}, 0)(context.Background(), 0)
+	assert.Equal(t, errors.New("warmer: limit must be positive"), err)
+	assert.Nil(t, keys)
+}